@@ -1,31 +1,25 @@
 package main
 
 import (
-	"encoding/csv"
 	"errors"
 	"flag"
 	"fmt"
 	"image"
 	"image/draw"
-	gio "io"
 	"log"
-	"os"
 	"strconv"
 	"strings"
 
-	"github.com/joaowiciuk/matrix"
-
 	"github.com/joaowiciuk/vision"
+	"github.com/joaowiciuk/vision/kernel"
 
 	"github.com/anthonynsimon/bild/segment"
 
 	"github.com/anthonynsimon/bild/effect"
 
 	"github.com/anthonynsimon/bild/imgio"
-	"github.com/anthonynsimon/bild/transform"
 	"github.com/joaowiciuk/lenna/convolution"
 	"github.com/joaowiciuk/lenna/morphology"
-	o "github.com/joaowiciuk/lenna/threshold"
 	lt "github.com/joaowiciuk/lenna/transform"
 )
 
@@ -34,7 +28,7 @@ type params struct {
 }
 
 func (p *params) String() string {
-	return fmt.Sprintf("-lthres %.4f %.4f %.4f", p.a, p.b, p.c)
+	return fmt.Sprintf("-lthres %.0f,%.4f,%.4f", p.a, p.b, p.c)
 }
 
 func (p *params) Set(values string) (err error) {
@@ -54,7 +48,11 @@ func (p *params) Set(values string) (err error) {
 
 func main() {
 
-	var kernel string
+	var kernelFile string
+	var named string
+	var kernels bool
+	var pipe string
+	var pipeline string
 	var canny string
 	var resize string
 	var gray bool
@@ -72,6 +70,8 @@ func main() {
 	var morph string
 	var ccl int
 	var grad bool
+	var rotate float64
+	var flip string
 
 	var width int
 	var height int
@@ -80,9 +80,13 @@ func main() {
 	var img image.Image
 	var err error
 
-	flag.StringVar(&kernel, "kernel", "data.csv", "-kernel data.csv")
+	flag.StringVar(&kernelFile, "kernel", "data.csv", "-kernel <data.csv|data.json|data.txt>")
+	flag.StringVar(&named, "named", "sobelx", "-named <kernel name>")
+	flag.BoolVar(&kernels, "kernels", false, "List the named kernels")
+	flag.StringVar(&pipe, "pipe", "gray|gaussian:1.4|canny:auto", "-pipe \"<step>|<step>:<param>,<param>|...\"\n"+usage())
+	flag.StringVar(&pipeline, "pipeline", "pipeline.yaml", "-pipeline <pipeline.json|pipeline.yaml>")
 	flag.StringVar(&canny, "canny", "91:31:3:1.4", "-canny hi:lo:w:s")
-	flag.Var(&lthres, "lthres", "-lthres <stddev globmean locmean>")
+	flag.Var(&lthres, "lthres", "-lthres <window,k,r> (Sauvola)")
 	flag.BoolVar(&otsu, "otsu", false, "-otsu")
 	flag.StringVar(&resize, "r", "683x384", "-r <comprimento>x<largura>")
 	flag.BoolVar(&gray, "g", false, "-g")
@@ -98,6 +102,8 @@ func main() {
 	flag.StringVar(&morph, "morph", "erode,se.png", "-morph <operation> <kernel file>")
 	flag.IntVar(&ccl, "ccl", 8, "-ccl <connectivity>")
 	flag.BoolVar(&grad, "grad", false, "Image gradient magnitude")
+	flag.Float64Var(&rotate, "rot", 0, "-rot <graus anti-horário>")
+	flag.StringVar(&flip, "flip", "h", "-flip <h|v>")
 
 	flag.Parse()
 
@@ -106,11 +112,30 @@ func main() {
 
 	flag.Visit(func(f *flag.Flag) { flags[f.Name] = true })
 
+	if kernels {
+		for _, name := range kernel.Names() {
+			fmt.Println(name)
+		}
+		return
+	}
+
 	if !flags["in"] || !flags["out"] {
 		fmt.Printf("Arquivo de entrada ou saída não especificado\n")
 		return
 	}
 
+	var steps []step
+	switch {
+	case flags["pipe"]:
+		steps, err = parsePipe(pipe)
+	case flags["pipeline"]:
+		steps, err = loadPipeline(pipeline)
+	}
+	if err != nil {
+		fmt.Println("Pipeline inválido:", err)
+		return
+	}
+
 	img, err = imgio.Open(in)
 
 	if err != nil {
@@ -118,8 +143,31 @@ func main() {
 		return
 	}
 
+	if steps != nil {
+		img, err = runPipeline(img, steps)
+		if err != nil {
+			fmt.Println("Erro no pipeline:", err)
+			return
+		}
+		save(img, out)
+		return
+	}
+
 	if flags["r"] {
-		img = transform.Resize(img, width, height, transform.Linear)
+		img = vision.Resize(img, width, height, vision.InterpolationBilinear)
+	}
+
+	if flags["rot"] {
+		img = vision.Rotate(img, rotate, true, vision.WarpOptions{Interpolation: vision.InterpolationBilinear})
+	}
+
+	if flags["flip"] {
+		switch flip {
+		case "v":
+			img = vision.FlipV(img)
+		default:
+			img = vision.FlipH(img)
+		}
 	}
 
 	if flags["t"] {
@@ -139,7 +187,7 @@ func main() {
 	}
 
 	if flags["m"] {
-		img = effect.Median(img, median)
+		img = vision.Median(img, int(median))
 	}
 
 	if flags["i"] {
@@ -151,13 +199,15 @@ func main() {
 	}
 
 	if flags["otsu"] {
-		img = o.OtsuThreshold(&img)
+		gray := image.NewGray(img.Bounds())
+		draw.Draw(gray, img.Bounds(), img, img.Bounds().Min, draw.Src)
+		img, _ = vision.Otsu(gray)
 	}
 
 	if flags["lthres"] {
-		grayimg := effect.Grayscale(img)
-		img = o.Local(grayimg, o.Cumulative{Params: []float64{lthres.a, lthres.b, lthres.c}})
-		/* fmt.Printf("%.4f %.4f %.4f\n", lthres.a, lthres.b, lthres.c) */
+		gray := image.NewGray(img.Bounds())
+		draw.Draw(gray, img.Bounds(), img, img.Bounds().Min, draw.Src)
+		img, _ = vision.Sauvola(gray, int(lthres.a), lthres.b, lthres.c)
 	}
 
 	if flags["canny"] {
@@ -172,46 +222,21 @@ func main() {
 	}
 
 	if flags["kernel"] {
-		f, _ := os.Open(kernel)
-		r := csv.NewReader(f)
-		lines := make([][]string, 0)
-		m, n := 0, 0
-		for {
-			record, err := r.Read()
-			if err == gio.EOF {
-				break
-			}
-			if err != nil {
-				log.Fatal(err)
-			}
-			m++
-			if len(record) > n {
-				n = len(record)
-			}
-			lines = append(lines, record)
-		}
-		x := matrix.New(m, n)
-		for i0, v0 := range lines {
-			for i1, v1 := range v0 {
-				(*x)[i0][i1], _ = strconv.ParseFloat(v1, 64)
-			}
+		k, err := kernel.Load(kernelFile)
+		if err != nil {
+			fmt.Println("Erro ao carregar kernel", err)
+			return
 		}
-		/* fmt.Printf("%v\n", *x) */
-		tensor := vision.Im2Mat(img)
-		var src *matrix.Matrix
-		switch len(tensor) {
-		case 1:
-			src = vision.Im2Mat(img)[0]
-		case 3, 4:
-			src.Law(func(r, c int) float64 {
-				return 0.299*(*tensor[0])[r][c] + 0.587*(*tensor[1])[r][c] + 0.114*(*tensor[2])[r][c]
-			})
-		default:
+		img = vision.ApplyKernel(vision.Luma(img), k, vision.BorderReplicate).Gray()
+	}
+
+	if flags["named"] {
+		k, err := kernel.Named(named)
+		if err != nil {
+			fmt.Println(err)
 			return
 		}
-		c := x.Conv(src)
-		/* fmt.Printf("%v\n", *c) */
-		img = *vision.Mat2Im([]*matrix.Matrix{c})
+		img = vision.ApplyKernel(vision.Luma(img), k, vision.BorderReplicate).Gray()
 	}
 
 	if flags["conv"] {
@@ -289,6 +314,12 @@ func main() {
 		img, _ = vision.Grad(gray)
 	}
 
+	save(img, out)
+}
+
+// save writes the image to the PNG or JPEG file.
+func save(img image.Image, out string) {
+	var err error
 	fileName := out[:strings.LastIndex(out, ".")]
 	ext := out[strings.LastIndex(out, "."):]
 	switch ext {
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/anthonynsimon/bild/effect"
+	"github.com/joaowiciuk/vision"
+	"github.com/joaowiciuk/vision/kernel"
+)
+
+// kind is the type of a step parameter.
+type kind int
+
+const (
+	// kindInt is an integer.
+	kindInt kind = iota
+	// kindFloat is a real number.
+	kindFloat
+	// kindLevel is a gray level from 0 to 255, or auto to choose it from the
+	// image.
+	kindLevel
+	// kindWord is one of the choices of the parameter.
+	kindWord
+	// kindName is any name.
+	kindName
+)
+
+// auto is the value of levels given as auto.
+const auto = -1
+
+// param describes a parameter of an operation. Parameters without a default
+// value are required.
+type param struct {
+	name    string
+	kind    kind
+	def     string
+	choices []string
+}
+
+// args holds the parsed parameters of a step by name, as float64 for
+// numbers and string for words and names.
+type args map[string]interface{}
+
+func (a args) int(name string) int {
+	return int(a[name].(float64))
+}
+
+func (a args) float(name string) float64 {
+	return a[name].(float64)
+}
+
+func (a args) string(name string) string {
+	return a[name].(string)
+}
+
+// level returns the level and false if it is auto.
+func (a args) level(name string) (int, bool) {
+	v := a.int(name)
+	return v, v != auto
+}
+
+// operation is a processing step of the pipeline, applying a library
+// function to the image.
+type operation struct {
+	usage  string
+	params []param
+	apply  func(img image.Image, a args) (image.Image, error)
+}
+
+// toGray returns the image if it is gray or its luma otherwise.
+func toGray(img image.Image) *image.Gray {
+	if gray, ok := img.(*image.Gray); ok {
+		return gray
+	}
+	return vision.Luma(img).Gray()
+}
+
+// interpolations maps names to interpolation modes.
+var interpolations = map[string]vision.Interpolation{
+	"nearest":  vision.InterpolationNearest,
+	"bilinear": vision.InterpolationBilinear,
+	"bicubic":  vision.InterpolationBicubic,
+	"lanczos":  vision.InterpolationLanczos,
+	"area":     vision.InterpolationArea,
+}
+
+// operations maps the names of the steps to their operations.
+var operations = map[string]operation{
+	"gray": {
+		usage: "converts to gray levels",
+		apply: func(img image.Image, a args) (image.Image, error) {
+			return toGray(img), nil
+		},
+	},
+	"resize": {
+		usage:  "resizes to the given width and height",
+		params: []param{{"width", kindInt, "", nil}, {"height", kindInt, "", nil}, {"interpolation", kindWord, "bilinear", []string{"nearest", "bilinear", "bicubic", "lanczos", "area"}}},
+		apply: func(img image.Image, a args) (image.Image, error) {
+			if a.int("width") < 1 || a.int("height") < 1 {
+				return nil, errors.New("width and height must be positive")
+			}
+			return vision.Resize(img, a.int("width"), a.int("height"), interpolations[a.string("interpolation")]), nil
+		},
+	},
+	"rotate": {
+		usage:  "rotates counterclockwise by the angle in degrees",
+		params: []param{{"angle", kindFloat, "", nil}},
+		apply: func(img image.Image, a args) (image.Image, error) {
+			return vision.Rotate(img, a.float("angle"), true, vision.WarpOptions{Interpolation: vision.InterpolationBilinear}), nil
+		},
+	},
+	"flip": {
+		usage:  "flips horizontally or vertically",
+		params: []param{{"direction", kindWord, "h", []string{"h", "v"}}},
+		apply: func(img image.Image, a args) (image.Image, error) {
+			if a.string("direction") == "v" {
+				return vision.FlipV(img), nil
+			}
+			return vision.FlipH(img), nil
+		},
+	},
+	"invert": {
+		usage: "inverts the colors",
+		apply: func(img image.Image, a args) (image.Image, error) {
+			return effect.Invert(img), nil
+		},
+	},
+	"gaussian": {
+		usage:  "blurs the gray levels with a gaussian of the given standard deviation",
+		params: []param{{"sigma", kindFloat, "", nil}},
+		apply: func(img image.Image, a args) (image.Image, error) {
+			if a.float("sigma") <= 0 {
+				return nil, errors.New("sigma must be positive")
+			}
+			return vision.Gaussian(toGray(img), a.float("sigma")), nil
+		},
+	},
+	"median": {
+		usage:  "applies the median filter of the given radius",
+		params: []param{{"radius", kindInt, "1", nil}},
+		apply: func(img image.Image, a args) (image.Image, error) {
+			if a.int("radius") < 1 {
+				return nil, errors.New("radius must be positive")
+			}
+			return vision.Median(img, a.int("radius")), nil
+		},
+	},
+	"bilateral": {
+		usage:  "applies the bilateral filter",
+		params: []param{{"sigmaSpace", kindFloat, "", nil}, {"sigmaRange", kindFloat, "", nil}},
+		apply: func(img image.Image, a args) (image.Image, error) {
+			if a.float("sigmaSpace") <= 0 || a.float("sigmaRange") <= 0 {
+				return nil, errors.New("sigmas must be positive")
+			}
+			return vision.Bilateral(img, a.float("sigmaSpace"), a.float("sigmaRange")), nil
+		},
+	},
+	"kernel": {
+		usage:  "convolves the gray levels with a named kernel",
+		params: []param{{"name", kindName, "", nil}},
+		apply: func(img image.Image, a args) (image.Image, error) {
+			k, err := kernel.Named(a.string("name"))
+			if err != nil {
+				return nil, err
+			}
+			return vision.ApplyKernel(vision.Luma(img), k, vision.BorderReplicate).Gray(), nil
+		},
+	},
+	"threshold": {
+		usage:  "binarizes the gray levels at the level, or at Otsu's level if auto",
+		params: []param{{"level", kindLevel, "auto", nil}},
+		apply: func(img image.Image, a args) (image.Image, error) {
+			gray := toGray(img)
+			level, ok := a.level("level")
+			if !ok {
+				out, _ := vision.Otsu(gray)
+				return out, nil
+			}
+			var src image.Image = gray
+			return vision.Threshold(&src, uint8(level)), nil
+		},
+	},
+	"equalize": {
+		usage: "equalizes the histogram of the gray levels",
+		apply: func(img image.Image, a args) (image.Image, error) {
+			return vision.Equalize(toGray(img)), nil
+		},
+	},
+	"clahe": {
+		usage:  "equalizes the gray levels in tiles with limited contrast",
+		params: []param{{"tilesX", kindInt, "8", nil}, {"tilesY", kindInt, "8", nil}, {"clipLimit", kindFloat, "2", nil}},
+		apply: func(img image.Image, a args) (image.Image, error) {
+			if a.int("tilesX") < 1 || a.int("tilesY") < 1 {
+				return nil, errors.New("tiles must be positive")
+			}
+			return vision.CLAHE(toGray(img), image.Pt(a.int("tilesX"), a.int("tilesY")), a.float("clipLimit")), nil
+		},
+	},
+	"canny": {
+		usage:  "detects edges, with thresholds from Otsu's level if auto",
+		params: []param{{"high", kindLevel, "", nil}, {"low", kindLevel, "auto", nil}, {"k", kindInt, "3", nil}, {"sigma", kindFloat, "1.4", nil}},
+		apply: func(img image.Image, a args) (image.Image, error) {
+			gray := toGray(img)
+			high, highOk := a.level("high")
+			low, lowOk := a.level("low")
+			//Otsu's level of the image as the high threshold and half of it as
+			//the low one
+			if !highOk || !lowOk {
+				_, level := vision.Otsu(gray)
+				if !highOk {
+					high = int(level)
+				}
+				if !lowOk {
+					low = high / 2
+				}
+			}
+			if low > high {
+				return nil, fmt.Errorf("low threshold %d above high threshold %d", low, high)
+			}
+			return vision.Canny(gray, uint8(high), uint8(low), a.int("k"), a.float("sigma")), nil
+		},
+	},
+	"grad": {
+		usage: "computes the gradient magnitude of the gray levels",
+		apply: func(img image.Image, a args) (image.Image, error) {
+			mag, _ := vision.Grad(toGray(img))
+			return mag, nil
+		},
+	},
+	"hough": {
+		usage:  "computes the Hough accumulator, or plots its lines above the threshold if positive",
+		params: []param{{"thetaRes", kindInt, "180", nil}, {"rhoRes", kindInt, "400", nil}, {"threshold", kindInt, "0", nil}},
+		apply: func(img image.Image, a args) (image.Image, error) {
+			if a.int("thetaRes") < 2 || a.int("rhoRes") < 2 {
+				return nil, errors.New("resolutions must be at least 2")
+			}
+			threshold := a.int("threshold")
+			if threshold < 0 || threshold > 255 {
+				return nil, errors.New("threshold must be from 0 to 255")
+			}
+			hs := vision.NewHoughSpace(toGray(img), a.int("thetaRes"), a.int("rhoRes"))
+			if threshold == 0 {
+				return hs.HoughImage(), nil
+			}
+			return hs.FindCentroids(uint8(threshold)).PlotLines(), nil
+		},
+	},
+	"blobs": {
+		usage:  "colors the connected components",
+		params: []param{{"connectivity", kindWord, "8", []string{"4", "8"}}},
+		apply: func(img image.Image, a args) (image.Image, error) {
+			if a.string("connectivity") == "4" {
+				return vision.Blobs(&img, vision.Connectivity4), nil
+			}
+			return vision.Blobs(&img, vision.Connectivity8), nil
+		},
+	},
+}
+
+// step is an operation of the pipeline with its parameters.
+type step struct {
+	name string
+	args args
+}
+
+// parseValue parses the value of the parameter.
+func parseValue(p param, value string) (interface{}, error) {
+	switch p.kind {
+	case kindInt:
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: expected an integer, got %q", p.name, value)
+		}
+		return float64(v), nil
+	case kindFloat:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: expected a number, got %q", p.name, value)
+		}
+		return v, nil
+	case kindLevel:
+		if value == "auto" {
+			return float64(auto), nil
+		}
+		v, err := strconv.Atoi(value)
+		if err != nil || v < 0 || v > 255 {
+			return nil, fmt.Errorf("%s: expected a level from 0 to 255 or auto, got %q", p.name, value)
+		}
+		return float64(v), nil
+	case kindWord:
+		for _, choice := range p.choices {
+			if value == choice {
+				return value, nil
+			}
+		}
+		return nil, fmt.Errorf("%s: expected one of %s, got %q", p.name, strings.Join(p.choices, ", "), value)
+	}
+	if value == "" {
+		return nil, fmt.Errorf("%s: expected a name", p.name)
+	}
+	return value, nil
+}
+
+// newStep validates the values of the parameters of the named operation.
+func newStep(name string, values []string) (step, error) {
+	op, ok := operations[name]
+	if !ok {
+		return step{}, fmt.Errorf("unknown operation %q", name)
+	}
+	if len(values) > len(op.params) {
+		return step{}, fmt.Errorf("%s: expected at most %d parameters, got %d", name, len(op.params), len(values))
+	}
+	s := step{name: name, args: args{}}
+	for i, p := range op.params {
+		value := p.def
+		if i < len(values) {
+			value = values[i]
+		} else if value == "" {
+			return step{}, fmt.Errorf("%s: missing parameter %s", name, p.name)
+		}
+		v, err := parseValue(p, value)
+		if err != nil {
+			return step{}, fmt.Errorf("%s: %w", name, err)
+		}
+		s.args[p.name] = v
+	}
+	return s, nil
+}
+
+// parseStep parses a step written as name or name:value,value,... with
+// optional brackets around the values.
+func parseStep(text string) (step, error) {
+	text = strings.TrimSpace(text)
+	name, rest := text, ""
+	if i := strings.IndexByte(text, ':'); i >= 0 {
+		name, rest = strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:])
+	}
+	if name == "" {
+		return step{}, fmt.Errorf("missing operation in %q", text)
+	}
+	rest = strings.TrimSuffix(strings.TrimPrefix(rest, "["), "]")
+	var values []string
+	if strings.TrimSpace(rest) != "" {
+		for _, v := range strings.Split(rest, ",") {
+			values = append(values, strings.Trim(strings.TrimSpace(v), `"'`))
+		}
+	}
+	return newStep(name, values)
+}
+
+// parsePipe parses the steps of a pipeline separated by |.
+func parsePipe(pipe string) ([]step, error) {
+	var steps []step
+	for i, text := range strings.Split(pipe, "|") {
+		s, err := parseStep(text)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		steps = append(steps, s)
+	}
+	return steps, nil
+}
+
+// readPipelineJSON reads a pipeline as an array of steps, or an object with
+// the array in steps. Each step is a string as in parsePipe, or an object
+// with the operation in op and its parameters in args.
+func readPipelineJSON(r io.Reader) ([]step, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		var object struct {
+			Steps []json.RawMessage `json:"steps"`
+		}
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return nil, errors.New("expected an array of steps or an object with steps")
+		}
+		items = object.Steps
+	}
+	steps := make([]step, 0, len(items))
+	for i, item := range items {
+		var s step
+		var text string
+		var object struct {
+			Op   string        `json:"op"`
+			Args []interface{} `json:"args"`
+		}
+		var err error
+		if json.Unmarshal(item, &text) == nil {
+			s, err = parseStep(text)
+		} else if err = json.Unmarshal(item, &object); err == nil {
+			values := make([]string, len(object.Args))
+			for j, v := range object.Args {
+				values[j] = fmt.Sprint(v)
+			}
+			s, err = newStep(object.Op, values)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		steps = append(steps, s)
+	}
+	return steps, nil
+}
+
+// readPipelineYAML reads a pipeline from the minimal YAML list of steps
+//
+//	steps:
+//	  - gray
+//	  - gaussian: 1.4
+//	  - hough: [180, 400]
+//
+// where the steps key is optional and each item is written as in parsePipe.
+func readPipelineYAML(r io.Reader) ([]step, error) {
+	var steps []step
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+		switch {
+		case text == "" || text == "---" || (text == "steps:" && steps == nil):
+			continue
+		case strings.HasPrefix(text, "-"):
+			s, err := parseStep(strings.Trim(strings.TrimSpace(text[1:]), `"'`))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			steps = append(steps, s)
+		default:
+			return nil, fmt.Errorf("line %d: expected a list item, got %q", line, text)
+		}
+	}
+	return steps, scanner.Err()
+}
+
+// loadPipeline reads the pipeline in the JSON or YAML file.
+func loadPipeline(path string) ([]step, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var steps []step
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		steps, err = readPipelineJSON(f)
+	case ".yaml", ".yml":
+		steps, err = readPipelineYAML(f)
+	default:
+		return nil, fmt.Errorf("%s: expected a .json, .yaml or .yml file", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("%s: no steps", path)
+	}
+	return steps, nil
+}
+
+// runPipeline applies the steps to the image in order.
+func runPipeline(img image.Image, steps []step) (image.Image, error) {
+	for i, s := range steps {
+		out, err := operations[s.name].apply(img, s.args)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %s: %w", i+1, s.name, err)
+		}
+		img = out
+	}
+	return img, nil
+}
+
+// usage returns the syntax of every operation, one per line.
+func usage() string {
+	names := make([]string, 0, len(operations))
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		op := operations[name]
+		params := make([]string, len(op.params))
+		for i, p := range op.params {
+			params[i] = p.name
+			if p.def != "" {
+				params[i] += "=" + p.def
+			}
+		}
+		syntax := name
+		if len(params) > 0 {
+			syntax += ":" + strings.Join(params, ",")
+		}
+		fmt.Fprintf(&b, "%-44s %s\n", syntax, op.usage)
+	}
+	return b.String()
+}
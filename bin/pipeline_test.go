@@ -0,0 +1,112 @@
+package main
+
+import (
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestParsePipe(t *testing.T) {
+	steps, err := parsePipe("gray|gaussian:1.4|canny:auto|hough:180,400")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"gray", "gaussian", "canny", "hough"}
+	if len(steps) != len(expected) {
+		t.Fatalf("expected %d steps, got %d", len(expected), len(steps))
+	}
+	for i, s := range steps {
+		if s.name != expected[i] {
+			t.Errorf("step %d: expected %s, got %s", i+1, expected[i], s.name)
+		}
+	}
+	if steps[1].args.float("sigma") != 1.4 {
+		t.Errorf("expected sigma 1.4, got %v", steps[1].args["sigma"])
+	}
+	if high, ok := steps[2].args.level("high"); ok || high != auto {
+		t.Errorf("expected an automatic high threshold, got %v", high)
+	}
+	if steps[2].args.int("k") != 3 || steps[3].args.int("rhoRes") != 400 || steps[3].args.int("threshold") != 0 {
+		t.Errorf("unexpected parameters %v and %v", steps[2].args, steps[3].args)
+	}
+
+	for pipe, message := range map[string]string{
+		"gray|blur:2":         "step 2: unknown operation \"blur\"",
+		"gaussian:x":          "step 1: gaussian: sigma: expected a number, got \"x\"",
+		"gaussian":            "step 1: gaussian: missing parameter sigma",
+		"flip:d":              "step 1: flip: direction: expected one of h, v, got \"d\"",
+		"threshold:300":       "step 1: threshold: level: expected a level from 0 to 255 or auto, got \"300\"",
+		"gray:1":              "step 1: gray: expected at most 0 parameters, got 1",
+		"gray||median":        "step 2: missing operation in \"\"",
+		"resize:10,10,cubic2": "step 1: resize: interpolation: expected one of nearest, bilinear, bicubic, lanczos, area, got \"cubic2\"",
+	} {
+		if _, err := parsePipe(pipe); err == nil || err.Error() != message {
+			t.Errorf("%s: expected error %q, got %v", pipe, message, err)
+		}
+	}
+}
+
+func TestReadPipeline(t *testing.T) {
+	yaml := `# edges
+steps:
+  - gray
+  - gaussian: 1.4   # blur
+  - 'median: 2'
+  - hough: [180, 400]
+`
+	json := `{"steps": ["gray", {"op": "gaussian", "args": [1.4]}, "median:2", {"op": "hough", "args": [180, 400]}]}`
+	fromYAML, err := readPipelineYAML(strings.NewReader(yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON, err := readPipelineJSON(strings.NewReader(json))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fromYAML) != 4 || len(fromJSON) != 4 {
+		t.Fatalf("expected 4 steps, got %d and %d", len(fromYAML), len(fromJSON))
+	}
+	for i := range fromYAML {
+		a, b := fromYAML[i], fromJSON[i]
+		if a.name != b.name || len(a.args) != len(b.args) {
+			t.Fatalf("step %d differs: %v and %v", i+1, a, b)
+		}
+		for name, v := range a.args {
+			if b.args[name] != v {
+				t.Errorf("step %d: %s differs: %v and %v", i+1, name, v, b.args[name])
+			}
+		}
+	}
+
+	if _, err := readPipelineYAML(strings.NewReader("steps:\n  gray\n")); err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Errorf("expected an error at line 2, got %v", err)
+	}
+	if _, err := readPipelineJSON(strings.NewReader(`[{"op": "median", "args": ["x"]}]`)); err == nil {
+		t.Errorf("expected an error for an invalid radius")
+	}
+}
+
+func TestRunPipeline(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 16, 12))
+	for y := 0; y < 12; y++ {
+		for x := 8; x < 16; x++ {
+			gray.Pix[y*16+x] = 200
+		}
+	}
+	steps, err := parsePipe("gaussian:1|threshold:100|flip:h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := runPipeline(gray, steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, ok := out.(*image.Gray)
+	if !ok || result.GrayAt(1, 5).Y != 255 || result.GrayAt(14, 5).Y != 0 {
+		t.Errorf("expected the flipped binary step")
+	}
+	steps, _ = parsePipe("resize:0,4")
+	if _, err := runPipeline(gray, steps); err == nil || err.Error() != "step 1: resize: width and height must be positive" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
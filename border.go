@@ -0,0 +1,59 @@
+package vision
+
+// Border selects how an image is extended beyond its edges when a filter or
+// a warp reads pixels outside of it. With the image abcdefgh the modes give
+//
+//	BorderConstant		iiiiii|abcdefgh|iiiiiii, with a given value i
+//	BorderReplicate		aaaaaa|abcdefgh|hhhhhhh
+//	BorderReflect		fedcba|abcdefgh|hgfedcb
+//	BorderReflect101	gfedcb|abcdefgh|gfedcba
+//	BorderWrap		cdefgh|abcdefgh|abcdefg
+type Border int
+
+const (
+	BorderConstant Border = iota
+	BorderReplicate
+	BorderReflect
+	BorderReflect101
+	BorderWrap
+)
+
+// borderIndex maps the coordinate i to the range [0, n) according to the
+// border mode. It returns -1 for coordinates outside of the range under
+// BorderConstant.
+func borderIndex(i, n int, border Border) int {
+	if i >= 0 && i < n {
+		return i
+	}
+	switch border {
+	case BorderConstant:
+		return -1
+	case BorderReflect:
+		i = modulo(i, 2*n)
+		if i >= n {
+			i = 2*n - 1 - i
+		}
+		return i
+	case BorderReflect101:
+		if n == 1 {
+			return 0
+		}
+		i = modulo(i, 2*n-2)
+		if i >= n {
+			i = 2*n - 2 - i
+		}
+		return i
+	case BorderWrap:
+		return modulo(i, n)
+	}
+	return min(max(i, 0), n-1)
+}
+
+// modulo returns the non-negative remainder of i divided by n.
+func modulo(i, n int) int {
+	i %= n
+	if i < 0 {
+		i += n
+	}
+	return i
+}
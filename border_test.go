@@ -0,0 +1,24 @@
+package vision
+
+import "testing"
+
+func TestBorderIndex(t *testing.T) {
+	//abcdefgh extended by six pixels on each side, with -1 for constant
+	want := map[Border][]int{
+		BorderConstant:   {-1, -1, -1, -1, -1, -1, 0, 1, 2, 3, 4, 5, 6, 7, -1, -1, -1, -1, -1, -1},
+		BorderReplicate:  {0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4, 5, 6, 7, 7, 7, 7, 7, 7, 7},
+		BorderReflect:    {5, 4, 3, 2, 1, 0, 0, 1, 2, 3, 4, 5, 6, 7, 7, 6, 5, 4, 3, 2},
+		BorderReflect101: {6, 5, 4, 3, 2, 1, 0, 1, 2, 3, 4, 5, 6, 7, 6, 5, 4, 3, 2, 1},
+		BorderWrap:       {2, 3, 4, 5, 6, 7, 0, 1, 2, 3, 4, 5, 6, 7, 0, 1, 2, 3, 4, 5},
+	}
+	for border, indexes := range want {
+		for k, w := range indexes {
+			if got := borderIndex(k-6, 8, border); got != w {
+				t.Errorf("borderIndex(%d, 8, %d) = %d, want %d", k-6, border, got, w)
+			}
+		}
+	}
+	if got := borderIndex(-3, 1, BorderReflect101); got != 0 {
+		t.Errorf("borderIndex(-3, 1, BorderReflect101) = %d, want 0", got)
+	}
+}
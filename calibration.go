@@ -0,0 +1,493 @@
+package vision
+
+import (
+	"encoding/json"
+	"image"
+	"io"
+	"math"
+
+	"github.com/joaowiciuk/matrix"
+)
+
+// CameraMatrix holds the pinhole intrinsics of a camera: the focal lengths
+// and the principal point, in pixels.
+type CameraMatrix struct {
+	Fx float64 `json:"fx"`
+	Fy float64 `json:"fy"`
+	Cx float64 `json:"cx"`
+	Cy float64 `json:"cy"`
+}
+
+// Distortion holds the radial (K1, K2, K3) and tangential (P1, P2) lens
+// distortion coefficients of the Brown-Conrady model.
+type Distortion struct {
+	K1 float64 `json:"k1"`
+	K2 float64 `json:"k2"`
+	P1 float64 `json:"p1"`
+	P2 float64 `json:"p2"`
+	K3 float64 `json:"k3"`
+}
+
+// Pose is the rotation, as a Rodrigues vector, and the translation taking
+// points of a calibration target to the camera frame.
+type Pose struct {
+	Rotation    [3]float64 `json:"rotation"`
+	Translation [3]float64 `json:"translation"`
+}
+
+// Calibration is the result of CalibrateCamera. Error is the root mean
+// square reprojection error in pixels over all views and ViewErrors the
+// same error for each view.
+type Calibration struct {
+	Width      int          `json:"width"`
+	Height     int          `json:"height"`
+	Camera     CameraMatrix `json:"camera"`
+	Distortion Distortion   `json:"distortion"`
+	Poses      []Pose       `json:"poses,omitempty"`
+	Error      float64      `json:"error"`
+	ViewErrors []float64    `json:"view_errors,omitempty"`
+}
+
+// distort applies the lens distortion to normalized image coordinates.
+func (d Distortion) distort(x, y float64) (float64, float64) {
+	r2 := x*x + y*y
+	radial := 1 + r2*(d.K1+r2*(d.K2+r2*d.K3))
+	return x*radial + 2*d.P1*x*y + d.P2*(r2+2*x*x), y*radial + d.P1*(r2+2*y*y) + 2*d.P2*x*y
+}
+
+// rodrigues returns the rotation matrix of a Rodrigues vector.
+func rodrigues(r [3]float64) [9]float64 {
+	θ := math.Sqrt(r[0]*r[0] + r[1]*r[1] + r[2]*r[2])
+	if θ < 1e-12 {
+		return [9]float64{1, -r[2], r[1], r[2], 1, -r[0], -r[1], r[0], 1}
+	}
+	kx, ky, kz := r[0]/θ, r[1]/θ, r[2]/θ
+	c, s := math.Cos(θ), math.Sin(θ)
+	v := 1 - c
+	return [9]float64{
+		c + kx*kx*v, kx*ky*v - kz*s, kx*kz*v + ky*s,
+		ky*kx*v + kz*s, c + ky*ky*v, ky*kz*v - kx*s,
+		kz*kx*v - ky*s, kz*ky*v + kx*s, c + kz*kz*v,
+	}
+}
+
+// rodriguesVector returns the Rodrigues vector of a rotation matrix.
+func rodriguesVector(R [9]float64) [3]float64 {
+	axis := [3]float64{R[7] - R[5], R[2] - R[6], R[3] - R[1]}
+	s := math.Sqrt(axis[0]*axis[0]+axis[1]*axis[1]+axis[2]*axis[2]) / 2
+	θ := math.Atan2(s, (R[0]+R[4]+R[8]-1)/2)
+	switch {
+	case θ < 1e-9:
+		return [3]float64{axis[0] / 2, axis[1] / 2, axis[2] / 2}
+	case s > 1e-6:
+		f := θ / (2 * s)
+		return [3]float64{axis[0] * f, axis[1] * f, axis[2] * f}
+	}
+	//Half turn: the axis follows from R = 2kkᵀ - I
+	k := [3]float64{
+		math.Sqrt(math.Max((R[0]+1)/2, 0)),
+		math.Sqrt(math.Max((R[4]+1)/2, 0)),
+		math.Sqrt(math.Max((R[8]+1)/2, 0)),
+	}
+	switch {
+	case k[0] >= k[1] && k[0] >= k[2]:
+		k[1] = math.Copysign(k[1], R[1])
+		k[2] = math.Copysign(k[2], R[2])
+	case k[1] >= k[2]:
+		k[0] = math.Copysign(k[0], R[1])
+		k[2] = math.Copysign(k[2], R[5])
+	default:
+		k[0] = math.Copysign(k[0], R[2])
+		k[1] = math.Copysign(k[1], R[5])
+	}
+	return [3]float64{k[0] * θ, k[1] * θ, k[2] * θ}
+}
+
+// Project returns the image of points of the target plane seen with the
+// given pose.
+func (c *Calibration) Project(pose Pose, points []Point2D) []Point2D {
+	R := rodrigues(pose.Rotation)
+	t := pose.Translation
+	out := make([]Point2D, len(points))
+	for i, p := range points {
+		X := R[0]*p.X + R[1]*p.Y + t[0]
+		Y := R[3]*p.X + R[4]*p.Y + t[1]
+		Z := R[6]*p.X + R[7]*p.Y + t[2]
+		x, y := c.Distortion.distort(X/Z, Y/Z)
+		out[i] = Point2D{c.Camera.Fx*x + c.Camera.Cx, c.Camera.Fy*y + c.Camera.Cy}
+	}
+	return out
+}
+
+// ReprojectionError returns the root mean square distance in pixels between
+// the observed image points and the projection of the target points.
+func (c *Calibration) ReprojectionError(pose Pose, target, observed []Point2D) float64 {
+	if len(target) == 0 {
+		return 0
+	}
+	sum := 0.
+	for i, p := range c.Project(pose, target) {
+		dx, dy := p.X-observed[i].X, p.Y-observed[i].Y
+		sum += dx*dx + dy*dy
+	}
+	return math.Sqrt(sum / float64(len(target)))
+}
+
+// UndistortPoints removes the lens distortion from image points, returning
+// where an ideal pinhole camera would have seen them.
+func (c *Calibration) UndistortPoints(points []Point2D) []Point2D {
+	k := c.Camera
+	out := make([]Point2D, len(points))
+	for i, p := range points {
+		xd, yd := (p.X-k.Cx)/k.Fx, (p.Y-k.Cy)/k.Fy
+		x, y := xd, yd
+		//Fixed point iteration on the inverse of the distortion
+		for iteration := 0; iteration < 20; iteration++ {
+			dx, dy := c.Distortion.distort(x, y)
+			x, y = x+xd-dx, y+yd-dy
+		}
+		out[i] = Point2D{k.Fx*x + k.Cx, k.Fy*y + k.Cy}
+	}
+	return out
+}
+
+// UndistortMaps returns the maps that make Remap remove the lens distortion
+// from w-by-h images of the camera.
+func (c *Calibration) UndistortMaps(w, h int) (mapX, mapY *matrix.Matrix) {
+	k := c.Camera
+	mapX, mapY = matrix.New(h, w), matrix.New(h, w)
+	for v := 0; v < h; v++ {
+		for u := 0; u < w; u++ {
+			x, y := c.Distortion.distort((float64(u)-k.Cx)/k.Fx, (float64(v)-k.Cy)/k.Fy)
+			(*mapX)[v][u] = k.Fx*x + k.Cx
+			(*mapY)[v][u] = k.Fy*y + k.Cy
+		}
+	}
+	return
+}
+
+// Undistort removes the lens distortion from an image of the camera, keeping
+// its size and camera matrix.
+func Undistort(img image.Image, c *Calibration, opts WarpOptions) image.Image {
+	if img == nil || c == nil {
+		return nil
+	}
+	b := img.Bounds()
+	mapX, mapY := c.UndistortMaps(b.Dx(), b.Dy())
+	return Remap(img, mapX, mapY, opts)
+}
+
+// CalibrateCamera estimates the intrinsics and the lens distortion of a
+// camera from several views of a planar target, such as a chessboard with
+// ChessboardPoints as target and the corners of FindChessboardCorners as
+// views. The intrinsics are initialized with the closed form solution of
+// Z. Zhang, A flexible new technique for camera calibration,
+// IEEE Transactions on Pattern Analysis and Machine Intelligence, 22 (2000), pp. 1330–1334.
+// https://doi.org/10.1109/34.888718
+//
+// assuming square pixels without skew, and then refined together with the
+// distortion and the poses by Levenberg-Marquardt minimization of the
+// reprojection error. It needs at least two views and returns false if they
+// are degenerate.
+func CalibrateCamera(target []Point2D, views [][]Point2D, size image.Point) (*Calibration, bool) {
+	if len(views) < 2 || len(target) < 4 {
+		return nil, false
+	}
+	homographies := make([]Homography, len(views))
+	for v, view := range views {
+		if len(view) != len(target) {
+			return nil, false
+		}
+		h, ok := EstimateHomography(target, view)
+		if !ok {
+			return nil, false
+		}
+		homographies[v] = h
+	}
+	camera, ok := zhangIntrinsics(homographies, size)
+	if !ok {
+		return nil, false
+	}
+	c := &Calibration{Width: size.X, Height: size.Y, Camera: camera, Poses: make([]Pose, len(views))}
+	for v, h := range homographies {
+		c.Poses[v] = planePose(camera, h)
+	}
+	c.refine(target, views)
+	c.ViewErrors = make([]float64, len(views))
+	sum := 0.
+	for v, view := range views {
+		e := c.ReprojectionError(c.Poses[v], target, view)
+		c.ViewErrors[v] = e
+		sum += e * e
+	}
+	c.Error = math.Sqrt(sum / float64(len(views)))
+	return c, true
+}
+
+// zhangIntrinsics solves the camera matrix from the image of the absolute
+// conic B = K⁻ᵀK⁻¹ constrained by the homographies of the views and by zero
+// skew.
+func zhangIntrinsics(homographies []Homography, size image.Point) (CameraMatrix, bool) {
+	//Normalize the pixel coordinates for a well conditioned system
+	scale := float64(max(size.X, size.Y))
+	if scale <= 0 {
+		scale = 1
+	}
+	n := Homography{1 / scale, 0, 0, 0, 1 / scale, 0, 0, 0, 1}
+	vtv := make([][]float64, 6)
+	for i := range vtv {
+		vtv[i] = make([]float64, 6)
+	}
+	add := func(row [6]float64, weight float64) {
+		for r := range row {
+			for c := range row {
+				vtv[r][c] += weight * row[r] * row[c]
+			}
+		}
+	}
+	for _, h := range homographies {
+		h = n.Mul(h)
+		col := func(i int) [3]float64 { return [3]float64{h[i], h[3+i], h[6+i]} }
+		v := func(i, j int) [6]float64 {
+			a, b := col(i), col(j)
+			return [6]float64{
+				a[0] * b[0], a[0]*b[1] + a[1]*b[0], a[1] * b[1],
+				a[2]*b[0] + a[0]*b[2], a[2]*b[1] + a[1]*b[2], a[2] * b[2],
+			}
+		}
+		v01, v00, v11 := v(0, 1), v(0, 0), v(1, 1)
+		var d [6]float64
+		for k := range d {
+			d[k] = v00[k] - v11[k]
+		}
+		add(v01, 1)
+		add(d, 1)
+	}
+	add([6]float64{0, 1, 0, 0, 0, 0}, 1)
+	_, vectors := symmetricEigen(vtv)
+	b := vectors[0]
+	if b[0] < 0 {
+		for i := range b {
+			b[i] = -b[i]
+		}
+	}
+	B11, B12, B22, B13, B23, B33 := b[0], b[1], b[2], b[3], b[4], b[5]
+	den := B11*B22 - B12*B12
+	if B11 <= 0 || den <= 0 {
+		return CameraMatrix{}, false
+	}
+	v0 := (B12*B13 - B11*B23) / den
+	λ := B33 - (B13*B13+v0*(B12*B13-B11*B23))/B11
+	if λ <= 0 {
+		return CameraMatrix{}, false
+	}
+	α := math.Sqrt(λ / B11)
+	β := math.Sqrt(λ * B11 / den)
+	γ := -B12 * α * α * β / λ
+	u0 := γ*v0/β - B13*α*α/λ
+	return CameraMatrix{α * scale, β * scale, u0 * scale, v0 * scale}, true
+}
+
+// planePose recovers the pose of a target plane from its homography,
+// projecting the rotation to the nearest orthonormal matrix.
+func planePose(k CameraMatrix, h Homography) Pose {
+	inv := func(i int) [3]float64 {
+		x, y, z := h[i], h[3+i], h[6+i]
+		return [3]float64{(x - k.Cx*z) / k.Fx, (y - k.Cy*z) / k.Fy, z}
+	}
+	r1, r2, t := inv(0), inv(1), inv(2)
+	λ := 1 / math.Sqrt(r1[0]*r1[0]+r1[1]*r1[1]+r1[2]*r1[2])
+	if t[2] < 0 {
+		λ = -λ
+	}
+	for i := 0; i < 3; i++ {
+		r1[i] *= λ
+		r2[i] *= λ
+		t[i] *= λ
+	}
+	r3 := [3]float64{r1[1]*r2[2] - r1[2]*r2[1], r1[2]*r2[0] - r1[0]*r2[2], r1[0]*r2[1] - r1[1]*r2[0]}
+	q := [9]float64{r1[0], r2[0], r3[0], r1[1], r2[1], r3[1], r1[2], r2[2], r3[2]}
+
+	//R = Q (QᵀQ)^(-1/2)
+	qtq := make([][]float64, 3)
+	for i := range qtq {
+		qtq[i] = make([]float64, 3)
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				qtq[i][j] += q[3*k+i] * q[3*k+j]
+			}
+		}
+	}
+	values, vectors := symmetricEigen(qtq)
+	var root [9]float64
+	for e := range values {
+		f := 1 / math.Sqrt(math.Max(values[e], 1e-12))
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				root[3*i+j] += f * vectors[e][i] * vectors[e][j]
+			}
+		}
+	}
+	var R [9]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				R[3*i+j] += q[3*i+k] * root[3*k+j]
+			}
+		}
+	}
+	return Pose{Rotation: rodriguesVector(R), Translation: t}
+}
+
+// refine minimizes the reprojection error over the intrinsics, the
+// distortion and the poses with Levenberg-Marquardt iterations and a
+// numerical jacobian.
+func (c *Calibration) refine(target []Point2D, views [][]Point2D) {
+	const intrinsics = 9
+	params := make([]float64, intrinsics+6*len(views))
+	pack := func(c *Calibration) {
+		copy(params, []float64{
+			c.Camera.Fx, c.Camera.Fy, c.Camera.Cx, c.Camera.Cy,
+			c.Distortion.K1, c.Distortion.K2, c.Distortion.P1, c.Distortion.P2, c.Distortion.K3,
+		})
+		for v, pose := range c.Poses {
+			copy(params[intrinsics+6*v:], pose.Rotation[:])
+			copy(params[intrinsics+6*v+3:], pose.Translation[:])
+		}
+	}
+	unpack := func(p []float64) *Calibration {
+		u := &Calibration{
+			Camera:     CameraMatrix{p[0], p[1], p[2], p[3]},
+			Distortion: Distortion{p[4], p[5], p[6], p[7], p[8]},
+			Poses:      make([]Pose, len(views)),
+		}
+		for v := range views {
+			copy(u.Poses[v].Rotation[:], p[intrinsics+6*v:])
+			copy(u.Poses[v].Translation[:], p[intrinsics+6*v+3:])
+		}
+		return u
+	}
+	//residuals of a view depend only on the intrinsics and its pose
+	residuals := func(p []float64, v int, out []float64) {
+		u := unpack(p)
+		for i, q := range u.Project(u.Poses[v], target) {
+			out[2*i] = q.X - views[v][i].X
+			out[2*i+1] = q.Y - views[v][i].Y
+		}
+	}
+	cost := func(p []float64) float64 {
+		sum := 0.
+		r := make([]float64, 2*len(target))
+		for v := range views {
+			residuals(p, v, r)
+			for _, e := range r {
+				sum += e * e
+			}
+		}
+		return sum
+	}
+
+	pack(c)
+	n := len(params)
+	μ := 1e-3
+	current := cost(params)
+	r := make([]float64, 2*len(target))
+	rh := make([]float64, 2*len(target))
+	jacobian := make([][]float64, n)
+	for i := range jacobian {
+		jacobian[i] = make([]float64, 2*len(target))
+	}
+	converged := false
+	for iteration := 0; iteration < 100 && !converged; iteration++ {
+		jtj := make([][]float64, n)
+		for i := range jtj {
+			jtj[i] = make([]float64, n)
+		}
+		jtr := make([]float64, n)
+		for v := range views {
+			residuals(params, v, r)
+			pose := intrinsics + 6*v
+			columns := make([]int, 0, intrinsics+6)
+			for i := 0; i < intrinsics; i++ {
+				columns = append(columns, i)
+			}
+			for i := pose; i < pose+6; i++ {
+				columns = append(columns, i)
+			}
+			for _, i := range columns {
+				step := 1e-6 * math.Max(math.Abs(params[i]), 1e-2)
+				saved := params[i]
+				params[i] += step
+				residuals(params, v, rh)
+				params[i] = saved
+				for k := range rh {
+					jacobian[i][k] = (rh[k] - r[k]) / step
+				}
+			}
+			for _, i := range columns {
+				for _, j := range columns {
+					s := 0.
+					for k := range r {
+						s += jacobian[i][k] * jacobian[j][k]
+					}
+					jtj[i][j] += s
+				}
+				s := 0.
+				for k := range r {
+					s += jacobian[i][k] * r[k]
+				}
+				jtr[i] += s
+			}
+		}
+
+		improved := false
+		for attempt := 0; attempt < 10 && !improved; attempt++ {
+			a := make([][]float64, n)
+			b := make([]float64, n)
+			for i := range a {
+				a[i] = append([]float64(nil), jtj[i]...)
+				a[i][i] += μ * math.Max(jtj[i][i], 1e-12)
+				b[i] = -jtr[i]
+			}
+			δ, ok := solveLinear(a, b)
+			if !ok {
+				μ *= 10
+				continue
+			}
+			next := make([]float64, n)
+			for i := range next {
+				next[i] = params[i] + δ[i]
+			}
+			if e := cost(next); e < current {
+				relative := (current - e) / current
+				params, current = next, e
+				μ = math.Max(μ/10, 1e-9)
+				improved = true
+				converged = relative < 1e-12
+			} else {
+				μ *= 10
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+	u := unpack(params)
+	c.Camera, c.Distortion, c.Poses = u.Camera, u.Distortion, u.Poses
+}
+
+// WriteCalibration writes the calibration as indented JSON.
+func WriteCalibration(w io.Writer, c *Calibration) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(c)
+}
+
+// ReadCalibration reads a calibration written by WriteCalibration.
+func ReadCalibration(r io.Reader) (*Calibration, error) {
+	c := &Calibration{}
+	if err := json.NewDecoder(r).Decode(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,116 @@
+package vision
+
+import (
+	"bytes"
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// testCamera is a camera with noticeable lens distortion.
+var testCamera = Calibration{
+	Width: 640, Height: 480,
+	Camera:     CameraMatrix{Fx: 800, Fy: 790, Cx: 322, Cy: 236},
+	Distortion: Distortion{K1: -0.25, K2: 0.08, P1: 0.001, P2: -0.0008},
+}
+
+// testPoses returns views of a 9x6 board of 25 units squares from several
+// directions.
+func testPoses() []Pose {
+	return []Pose{
+		{[3]float64{0.1, -0.2, 0.05}, [3]float64{-100, -60, 500}},
+		{[3]float64{-0.3, 0.1, -0.1}, [3]float64{-90, -70, 450}},
+		{[3]float64{0.25, 0.3, 0.2}, [3]float64{-120, -50, 550}},
+		{[3]float64{-0.1, -0.35, -0.3}, [3]float64{-80, -80, 480}},
+		{[3]float64{0.4, 0.05, 0.0}, [3]float64{-100, -40, 520}},
+		{[3]float64{0.0, 0.45, 0.1}, [3]float64{-110, -65, 470}},
+	}
+}
+
+func TestCalibrateCamera(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	target := ChessboardPoints(9, 6, 25)
+	var views [][]Point2D
+	for _, pose := range testPoses() {
+		view := testCamera.Project(pose, target)
+		for i := range view {
+			view[i].X += rnd.NormFloat64() * 0.05
+			view[i].Y += rnd.NormFloat64() * 0.05
+		}
+		views = append(views, view)
+	}
+	c, ok := CalibrateCamera(target, views, image.Pt(640, 480))
+	if !ok {
+		t.Fatal("CalibrateCamera() failed")
+	}
+	if c.Error > 0.08 {
+		t.Errorf("reprojection error = %g, want about 0.05", c.Error)
+	}
+	k, want := c.Camera, testCamera.Camera
+	if math.Abs(k.Fx-want.Fx) > 3 || math.Abs(k.Fy-want.Fy) > 3 || math.Abs(k.Cx-want.Cx) > 3 || math.Abs(k.Cy-want.Cy) > 3 {
+		t.Errorf("camera = %+v, want %+v", k, want)
+	}
+	if d := c.Distortion; math.Abs(d.K1-testCamera.Distortion.K1) > 0.02 || math.Abs(d.P1-testCamera.Distortion.P1) > 0.001 {
+		t.Errorf("distortion = %+v, want %+v", d, testCamera.Distortion)
+	}
+	if len(c.ViewErrors) != len(views) {
+		t.Errorf("%d view errors, want %d", len(c.ViewErrors), len(views))
+	}
+
+	var buffer bytes.Buffer
+	if err := WriteCalibration(&buffer, c); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadCalibration(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Camera != c.Camera || read.Distortion != c.Distortion || read.Error != c.Error {
+		t.Errorf("ReadCalibration() = %+v, want %+v", read, c)
+	}
+	if _, ok := CalibrateCamera(target, views[:1], image.Pt(640, 480)); ok {
+		t.Errorf("CalibrateCamera() with a single view succeeded")
+	}
+}
+
+func TestRodrigues(t *testing.T) {
+	for _, r := range [][3]float64{{0, 0, 0}, {0.1, -0.2, 0.3}, {math.Pi, 0, 0}, {0, -math.Pi / math.Sqrt2, math.Pi / math.Sqrt2}} {
+		got := rodriguesVector(rodrigues(r))
+		R1, R2 := rodrigues(r), rodrigues(got)
+		for i := range R1 {
+			if math.Abs(R1[i]-R2[i]) > 1e-9 {
+				t.Errorf("rodriguesVector(%v) = %v", r, got)
+				break
+			}
+		}
+	}
+}
+
+func TestUndistort(t *testing.T) {
+	pinhole := testCamera
+	pinhole.Distortion = Distortion{}
+	pose := testPoses()[0]
+	target := ChessboardPoints(9, 6, 25)
+	ideal := pinhole.Project(pose, target)
+	for i, p := range testCamera.UndistortPoints(testCamera.Project(pose, target)) {
+		if d := math.Hypot(p.X-ideal[i].X, p.Y-ideal[i].Y); d > 1e-6 {
+			t.Fatalf("UndistortPoints() point %d is %g pixels away", i, d)
+		}
+	}
+
+	//Without distortion the maps are the identity
+	gray := noiseGray(32, 24)
+	out := Undistort(gray, &pinhole, WarpOptions{}).(*image.Gray)
+	if !bytes.Equal(out.Pix, gray.Pix) {
+		t.Errorf("Undistort() without distortion changed the image")
+	}
+	mapX, mapY := testCamera.UndistortMaps(640, 480)
+	p := ideal[0]
+	q := testCamera.Project(pose, target[:1])[0]
+	u, v := int(math.Floor(p.X+0.5)), int(math.Floor(p.Y+0.5))
+	//The map at the ideal position points near the distorted one
+	if d := math.Hypot((*mapX)[v][u]-q.X, (*mapY)[v][u]-q.Y); d > 1.5 {
+		t.Errorf("UndistortMaps() is %g pixels away from the distorted point", d)
+	}
+}
@@ -3,7 +3,6 @@ package vision
 
 import (
 	"image"
-	"image/draw"
 	"math"
 
 	"github.com/joaowiciuk/vision/kernel"
@@ -16,19 +15,15 @@ func Canny(img image.Image, upperThreshold, lowerThreshold uint8, k int, σ floa
 	lowT := float64(lowerThreshold)
 	uppT := float64(upperThreshold)
 	//Convert the input image to a single src matrix
-	tensor := Im2Mat(img)
-	var src *matrix.Matrix
-	switch len(tensor) {
-	case 1:
-		src = Im2Mat(img)[0]
-	case 3, 4:
-		src = matrix.New(img.Bounds().Dy(), img.Bounds().Dx())
-		src.Law(func(r, c int) float64 {
-			return 0.299*(*tensor[0])[r][c] + 0.587*(*tensor[1])[r][c] + 0.114*(*tensor[2])[r][c]
-		})
-	default:
+	if img == nil {
 		return nil
 	}
+	//Gaussian smoothing
+	smoothed := Convolve(ImageToFloat64(img).luma(), kernel.Gaussian(k, σ), BorderReplicate)
+	if smoothed == nil {
+		return nil
+	}
+	src := smoothed.Matrix()
 
 	m, n := src.Size()
 
@@ -38,7 +33,7 @@ func Canny(img image.Image, upperThreshold, lowerThreshold uint8, k int, σ floa
 	//Preprocessing to obtain magnitude and angle from source matrix
 	ang := matrix.New(m, n)
 	mag := matrix.New(m, n)
-	preProc(m, n, mag, ang, src, k, σ)
+	preProc(m, n, mag, ang, src, k)
 	/* mag0, ang0 := grad(src, k)
 	fmt.Println(mag.Dist(mag0, matrix.Norm1))
 	fmt.Println(ang.Dist(ang0, matrix.Norm1)) */
@@ -49,9 +44,7 @@ func Canny(img image.Image, upperThreshold, lowerThreshold uint8, k int, σ floa
 	//Hysterysis threshold
 	hystThresh(m, n, out, mag, ang, lowT)
 
-	aux := *Mat2Im([]*matrix.Matrix{out})
-	j = image.NewGray(aux.Bounds())
-	draw.Draw(j, j.Bounds(), aux, j.Bounds().Min, draw.Src)
+	j = Mat2Gray(out)
 	return
 }
 
@@ -385,12 +378,9 @@ func hystThresh(m, n int, out, mag, ang *matrix.Matrix, lowT float64) {
 	}
 }
 
-func preProc(m, n int, mag, ang, src *matrix.Matrix, k int, σ float64) {
-	srcFuture := kernel.Gaussian(k, σ).ConvFast(src)
-	/* srcFuture := make(chan *matrix.Matrix, 1)
-	srcFuture <- src */
+func preProc(m, n int, mag, ang, src *matrix.Matrix, k int) {
 	c := make(chan func() (*matrix.Matrix, *matrix.Matrix))
-	go sobelFast(k, <-srcFuture, c)
+	go sobelFast(k, src, c)
 
 	magX, magY := (<-c)()
 
@@ -0,0 +1,334 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// chessboardSigmas are the scales at which FindChessboardCorners looks for
+// the board, from fine to coarse.
+var chessboardSigmas = []float64{1, 2, 3.5}
+
+// ChessboardPoints returns the inner corners of a chessboard with cols by
+// rows inner corners and the given square size on its own plane, in the
+// order returned by FindChessboardCorners.
+func ChessboardPoints(cols, rows int, square float64) []Point2D {
+	points := make([]Point2D, 0, cols*rows)
+	for j := 0; j < rows; j++ {
+		for i := 0; i < cols; i++ {
+			points = append(points, Point2D{float64(i) * square, float64(j) * square})
+		}
+	}
+	return points
+}
+
+// FindChessboardCorners finds the cols by rows inner corners of a
+// chessboard. The corners are the saddle points of the smoothed image, which
+// are grown into a grid from their nearest neighbors as in
+// A. Geiger, F. Moosmann, Ö. Car and B. Schuster, Automatic camera and range sensor calibration using a single shot,
+// IEEE International Conference on Robotics and Automation (2012), pp. 3936–3943.
+// https://doi.org/10.1109/ICRA.2012.6224570
+//
+// and refined with CornerSubPix. They are returned row by row, with rows
+// running left to right on the image and following rows below them. It
+// returns false if the board is not found.
+func FindChessboardCorners(gray *image.Gray, cols, rows int) ([]Point2D, bool) {
+	if cols < 2 || rows < 2 {
+		return nil, false
+	}
+	base := grayPlane(gray)
+	for _, σ := range chessboardSigmas {
+		smooth := plane{gaussianBlur(base.values, base.w, base.h, σ), base.w, base.h}
+		corners, ok := growChessboard(smooth.saddles(2*σ+1), cols, rows)
+		if !ok {
+			continue
+		}
+		origin := gray.Bounds().Min
+		for i := range corners {
+			corners[i].X += float64(origin.X)
+			corners[i].Y += float64(origin.Y)
+		}
+		radius := max(int(math.Ceil(2*σ)), 3)
+		return CornerSubPix(gray, corners, radius), true
+	}
+	return nil, false
+}
+
+// saddle is a candidate chessboard corner.
+type saddle struct {
+	Point2D
+	response float64
+}
+
+// saddles returns the local maxima of the saddle response lxy² - lxx lyy
+// stronger than a fraction of the strongest one. The inner corners of a
+// chessboard respond sixteen times stronger than its outer corners, which
+// are also rejected because a circle of the given radius around them does
+// not cross four edges.
+func (p plane) saddles(radius float64) []saddle {
+	scores := make([]float64, p.w*p.h)
+	strongest := 0.
+	for y := 1; y < p.h-1; y++ {
+		for x := 1; x < p.w-1; x++ {
+			lxx, lyy, lxy := p.hessian(x, y)
+			s := lxy*lxy - lxx*lyy
+			if s > 0 {
+				scores[y*p.w+x] = s
+				strongest = math.Max(strongest, s)
+			}
+		}
+	}
+	var out []saddle
+	for _, q := range suppressScores(scores, p.w, p.h) {
+		s := scores[q.Y*p.w+q.X]
+		if s > 0.15*strongest && p.crossings(float64(q.X), float64(q.Y), radius) == 4 {
+			out = append(out, saddle{Point2D{float64(q.X), float64(q.Y)}, s})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].response > out[j].response })
+	return out
+}
+
+// crossings counts how many times a circle around (x, y) crosses the mean
+// of the values sampled on it, which is four for a chessboard corner.
+func (p plane) crossings(x, y, radius float64) int {
+	if x < radius || y < radius || x >= float64(p.w-1)-radius || y >= float64(p.h-1)-radius {
+		return 0
+	}
+	const n = 24
+	var samples [n]float64
+	mean := 0.
+	for k := range samples {
+		θ := 2 * math.Pi * float64(k) / n
+		samples[k] = bilinearAt(p.values, p.w, p.h, x+radius*math.Cos(θ), y+radius*math.Sin(θ))
+		mean += samples[k] / n
+	}
+	count := 0
+	for k := range samples {
+		if (samples[k] > mean) != (samples[(k+1)%n] > mean) {
+			count++
+		}
+	}
+	return count
+}
+
+// growChessboard grows grids of saddles from the strongest ones and returns
+// the first that contains a complete cols by rows board.
+func growChessboard(candidates []saddle, cols, rows int) ([]Point2D, bool) {
+	const seeds = 20
+	for s := 0; s < len(candidates) && s < seeds; s++ {
+		grid, ok := growGrid(candidates, s)
+		if !ok {
+			continue
+		}
+		if corners, ok := grid.board(candidates, cols, rows); ok {
+			return corners, true
+		}
+	}
+	return nil, false
+}
+
+// saddleGrid maps grid coordinates to candidate indexes.
+type saddleGrid map[image.Point]int
+
+// growGrid grows a grid of candidates from the seed along the directions to
+// its two nearest non collinear neighbors.
+func growGrid(candidates []saddle, seed int) (saddleGrid, bool) {
+	origin := candidates[seed].Point2D
+	neighbors := make([]int, 0, len(candidates))
+	for i := range candidates {
+		if i != seed {
+			neighbors = append(neighbors, i)
+		}
+	}
+	dist := func(i int) float64 {
+		return math.Hypot(candidates[i].X-origin.X, candidates[i].Y-origin.Y)
+	}
+	sort.Slice(neighbors, func(a, b int) bool { return dist(neighbors[a]) < dist(neighbors[b]) })
+	if len(neighbors) < 2 {
+		return nil, false
+	}
+	u := Point2D{candidates[neighbors[0]].X - origin.X, candidates[neighbors[0]].Y - origin.Y}
+	var v Point2D
+	found := false
+	for _, i := range neighbors[1:min(len(neighbors), 8)] {
+		d := Point2D{candidates[i].X - origin.X, candidates[i].Y - origin.Y}
+		cos := (u.X*d.X + u.Y*d.Y) / (math.Hypot(u.X, u.Y) * math.Hypot(d.X, d.Y))
+		if math.Abs(cos) < 0.5 {
+			v, found = d, true
+			break
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	grid := saddleGrid{image.Pt(0, 0): seed}
+	used := map[int]bool{seed: true}
+	at := func(g image.Point) (Point2D, bool) {
+		i, ok := grid[g]
+		if !ok {
+			return Point2D{}, false
+		}
+		return candidates[i].Point2D, true
+	}
+	queue := []image.Point{{0, 0}}
+	for len(queue) > 0 {
+		g := queue[0]
+		queue = queue[1:]
+		p, _ := at(g)
+		for _, d := range []image.Point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			n := g.Add(d)
+			if _, ok := grid[n]; ok {
+				continue
+			}
+			//Extrapolate the step from the grid when possible, which follows
+			//the perspective, or take it from the seed
+			step := Point2D{float64(d.X)*u.X + float64(d.Y)*v.X, float64(d.X)*u.Y + float64(d.Y)*v.Y}
+			if q, ok := at(g.Sub(d)); ok {
+				step = Point2D{p.X - q.X, p.Y - q.Y}
+			} else if q, ok := at(n.Add(image.Pt(d.Y, d.X))); ok {
+				if r, ok := at(g.Add(image.Pt(d.Y, d.X))); ok {
+					step = Point2D{q.X - r.X, q.Y - r.Y}
+				}
+			}
+			predicted := Point2D{p.X + step.X, p.Y + step.Y}
+			tolerance := 0.35 * math.Hypot(step.X, step.Y)
+			best, bestDist := -1, tolerance
+			for i, c := range candidates {
+				if used[i] {
+					continue
+				}
+				if d := math.Hypot(c.X-predicted.X, c.Y-predicted.Y); d < bestDist {
+					best, bestDist = i, d
+				}
+			}
+			if best >= 0 {
+				grid[n] = best
+				used[best] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+	return grid, len(grid) >= 4
+}
+
+// board returns the corners of the grid in the order of
+// FindChessboardCorners if it is a complete cols by rows board, in either
+// orientation. Grids with extra corners are rejected so a smaller board is
+// not found inside a larger one.
+func (grid saddleGrid) board(candidates []saddle, cols, rows int) ([]Point2D, bool) {
+	if len(grid) != cols*rows {
+		return nil, false
+	}
+	lo, hi := image.Pt(math.MaxInt32, math.MaxInt32), image.Pt(math.MinInt32, math.MinInt32)
+	for g := range grid {
+		lo = image.Pt(min(lo.X, g.X), min(lo.Y, g.Y))
+		hi = image.Pt(max(hi.X, g.X), max(hi.Y, g.Y))
+	}
+	size := hi.Sub(lo).Add(image.Pt(1, 1))
+	if !size.Eq(image.Pt(cols, rows)) && !size.Eq(image.Pt(rows, cols)) {
+		return nil, false
+	}
+	window := make([]Point2D, 0, cols*rows)
+	for y := lo.Y; y <= hi.Y; y++ {
+		for x := lo.X; x <= hi.X; x++ {
+			window = append(window, candidates[grid[image.Pt(x, y)]].Point2D)
+		}
+	}
+	return orientBoard(window, size.X, size.Y, cols, rows), true
+}
+
+// orientBoard reorders the w by h window of corners into cols by rows,
+// running left to right and then downwards on the image.
+func orientBoard(window []Point2D, w, h, cols, rows int) []Point2D {
+	at := func(i, j int) Point2D { return window[j*w+i] }
+	if w != cols {
+		//Transpose
+		t := make([]Point2D, 0, len(window))
+		for i := 0; i < w; i++ {
+			for j := 0; j < h; j++ {
+				t = append(t, at(i, j))
+			}
+		}
+		window, w, h = t, h, w
+	}
+	u := Point2D{at(w-1, 0).X - at(0, 0).X, at(w-1, 0).Y - at(0, 0).Y}
+	flipCols := u.X < 0
+	if flipCols {
+		u = Point2D{-u.X, -u.Y}
+	}
+	v := Point2D{at(0, h-1).X - at(0, 0).X, at(0, h-1).Y - at(0, 0).Y}
+	flipRows := u.X*v.Y-u.Y*v.X < 0
+	out := make([]Point2D, 0, len(window))
+	for j := 0; j < h; j++ {
+		for i := 0; i < w; i++ {
+			x, y := i, j
+			if flipCols {
+				x = w - 1 - i
+			}
+			if flipRows {
+				y = h - 1 - j
+			}
+			out = append(out, at(x, y))
+		}
+	}
+	return out
+}
+
+// CornerSubPix refines corners to subpixel accuracy by finding, within the
+// given radius, the point to which the image gradients are orthogonal, as
+// described in
+// W. Förstner and E. Gülch, A fast operator for detection and precise location of distinct points, corners and centres of circular features,
+// ISPRS Intercommission Conference on Fast Processing of Photogrammetric Data (1987), pp. 281–305.
+//
+// The corners are in the coordinates of the image, whose bounds need not
+// start at the origin.
+func CornerSubPix(gray *image.Gray, corners []Point2D, radius int) []Point2D {
+	p := grayPlane(gray)
+	origin := gray.Bounds().Min
+	at := func(x, y int) float64 {
+		return p.values[borderIndex(y, p.h, BorderReplicate)*p.w+borderIndex(x, p.w, BorderReplicate)]
+	}
+	σ := float64(radius) / 2
+	refined := make([]Point2D, len(corners))
+	for k, c := range corners {
+		//Refine in the coordinates of the plane
+		start := Point2D{c.X - float64(origin.X), c.Y - float64(origin.Y)}
+		q := start
+		for iteration := 0; iteration < 20; iteration++ {
+			cx, cy := int(math.Floor(q.X+0.5)), int(math.Floor(q.Y+0.5))
+			var a00, a01, a11, b0, b1 float64
+			for y := cy - radius; y <= cy+radius; y++ {
+				for x := cx - radius; x <= cx+radius; x++ {
+					gx := (at(x+1, y) - at(x-1, y)) / 2
+					gy := (at(x, y+1) - at(x, y-1)) / 2
+					dx, dy := float64(x)-q.X, float64(y)-q.Y
+					w := math.Exp(-(dx*dx + dy*dy) / (2 * σ * σ))
+					a00 += w * gx * gx
+					a01 += w * gx * gy
+					a11 += w * gy * gy
+					b0 += w * (gx*gx*float64(x) + gx*gy*float64(y))
+					b1 += w * (gx*gy*float64(x) + gy*gy*float64(y))
+				}
+			}
+			det := a00*a11 - a01*a01
+			if det <= 1e-9*(a00+a11)*(a00+a11) {
+				break
+			}
+			next := Point2D{(a11*b0 - a01*b1) / det, (a00*b1 - a01*b0) / det}
+			//Stay within the window
+			if math.Hypot(next.X-start.X, next.Y-start.Y) > float64(radius) {
+				break
+			}
+			moved := math.Hypot(next.X-q.X, next.Y-q.Y)
+			q = next
+			if moved < 0.005 {
+				break
+			}
+		}
+		refined[k] = Point2D{q.X + float64(origin.X), q.Y + float64(origin.Y)}
+	}
+	return refined
+}
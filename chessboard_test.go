@@ -0,0 +1,82 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+// renderChessboard draws a chessboard with cols by rows inner corners and
+// squares of the given size surrounded by a white margin, and returns the
+// position of its inner corners.
+func renderChessboard(cols, rows, square, margin int) (*image.Gray, []Point2D) {
+	w, h := (cols+1)*square+2*margin, (rows+1)*square+2*margin
+	gray := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i, j := (x-margin)/square, (y-margin)/square
+			inside := x >= margin && y >= margin && i <= cols && j <= rows
+			if !inside || (i+j)%2 == 1 {
+				gray.Pix[y*w+x] = 255
+			}
+		}
+	}
+	corners := make([]Point2D, 0, cols*rows)
+	for j := 1; j <= rows; j++ {
+		for i := 1; i <= cols; i++ {
+			corners = append(corners, Point2D{float64(margin+i*square) - 0.5, float64(margin+j*square) - 0.5})
+		}
+	}
+	return gray, corners
+}
+
+func TestFindChessboardCorners(t *testing.T) {
+	board, corners := renderChessboard(7, 5, 20, 20)
+	h := Homography{0.9, 0.25, 40, -0.15, 0.85, 60, 0.0006, 0.0009, 1}
+	photo := WarpPerspective(board, h, image.Pt(300, 260), WarpOptions{Interpolation: InterpolationBilinear, Fill: board.GrayAt(0, 0)}).(*image.Gray)
+
+	found, ok := FindChessboardCorners(photo, 7, 5)
+	if !ok {
+		t.Fatal("FindChessboardCorners() failed")
+	}
+	worst := 0.
+	for i, c := range corners {
+		want := h.Apply(c)
+		worst = math.Max(worst, math.Hypot(found[i].X-want.X, found[i].Y-want.Y))
+	}
+	if worst > 0.25 {
+		t.Errorf("worst corner error = %g pixels", worst)
+	}
+	//A subimage reports and refines corners in the coordinates of the image
+	sub := photo.SubImage(image.Rect(10, 20, 300, 260)).(*image.Gray)
+	inner, ok := FindChessboardCorners(sub, 7, 5)
+	if !ok {
+		t.Fatal("FindChessboardCorners() failed on a subimage")
+	}
+	refined := CornerSubPix(sub, found, 3)
+	for i := range found {
+		if d := math.Hypot(inner[i].X-found[i].X, inner[i].Y-found[i].Y); d > 0.05 {
+			t.Errorf("corner %d of the subimage moved by %g pixels", i, d)
+			break
+		}
+		if d := math.Hypot(refined[i].X-found[i].X, refined[i].Y-found[i].Y); d > 0.05 {
+			t.Errorf("CornerSubPix() on the subimage moved corner %d by %g pixels", i, d)
+			break
+		}
+	}
+	if _, ok := FindChessboardCorners(photo, 8, 5); ok {
+		t.Errorf("FindChessboardCorners() found a larger board")
+	}
+	if _, ok := FindChessboardCorners(photo, 6, 5); ok {
+		t.Errorf("FindChessboardCorners() found a smaller board")
+	}
+	if _, ok := FindChessboardCorners(photo, 5, 7); !ok {
+		t.Errorf("FindChessboardCorners() did not find the transposed board")
+	}
+
+	//Boards are reported left to right whatever their orientation
+	flipped, ok := FindChessboardCorners(Rotate180(photo).(*image.Gray), 7, 5)
+	if !ok || flipped[0].X > flipped[6].X || flipped[0].Y > flipped[7*4].Y {
+		t.Errorf("FindChessboardCorners() of the rotated board is not oriented")
+	}
+}
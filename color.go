@@ -0,0 +1,434 @@
+package vision
+
+import (
+	"image"
+	"math"
+)
+
+// ColorSpace identifies the color space of a three channel Float64Image.
+// The channels of each space hold the following values:
+//
+//	ColorRGB         R, G, B in 0..255, gamma encoded sRGB
+//	ColorLinearRGB   R, G, B in 0..1, linear sRGB
+//	ColorHSV         H in 0..360 degrees, S and V in 0..1
+//	ColorHSL         H in 0..360 degrees, S and L in 0..1
+//	ColorYCbCr601    Y, Cb, Cr in 0..255, full range BT.601
+//	ColorYCbCr709    Y, Cb, Cr in 0..255, full range BT.709
+//	ColorXYZ         X, Y, Z with Y in 0..1, D65 white
+//	ColorLab         L* in 0..100, a* and b*, D65 white
+//	ColorLuv         L* in 0..100, u* and v*, D65 white
+type ColorSpace int
+
+const (
+	ColorRGB ColorSpace = iota
+	ColorLinearRGB
+	ColorHSV
+	ColorHSL
+	ColorYCbCr601
+	ColorYCbCr709
+	ColorXYZ
+	ColorLab
+	ColorLuv
+)
+
+// Luma weights of the red, green and blue channels.
+var (
+	luma601 = [3]float64{0.299, 0.587, 0.114}
+	luma709 = [3]float64{0.2126, 0.7152, 0.0722}
+)
+
+// D65 reference white in CIE XYZ.
+var whiteD65 = [3]float64{0.95047, 1, 1.08883}
+
+// Constants of the CIE L* function.
+const (
+	cieEpsilon = 216. / 24389
+	cieKappa   = 24389. / 27
+)
+
+// Luma returns the BT.601 luma of an image in a single channel
+// Float64Image, in the 0..255 range. The luma of gray images is their value.
+func Luma(img image.Image) *Float64Image {
+	f := ImageToFloat64(img)
+	if f == nil {
+		return nil
+	}
+	return f.luma()
+}
+
+// ConvertColor converts an image from one color space to another and returns
+// a new three channel image. The channels of four channel images are
+// divided by their alpha, which is dropped, and single channel images are
+// taken as gray values in every channel. It returns nil for images with
+// another number of channels.
+func ConvertColor(f *Float64Image, from, to ColorSpace) *Float64Image {
+	if f == nil || f.Channels != 1 && f.Channels != 3 && f.Channels != 4 {
+		return nil
+	}
+	out := NewFloat64Image(f.Rect, 3)
+	w := f.Rect.Dx()
+	for y := f.Rect.Min.Y; y < f.Rect.Max.Y; y++ {
+		row, dst := f.row(y), out.row(y)
+		for x := 0; x < w; x++ {
+			s := row[x*f.Channels : (x+1)*f.Channels]
+			var c [3]float64
+			switch f.Channels {
+			case 1:
+				c = [3]float64{s[0], s[0], s[0]}
+			case 3:
+				c = [3]float64{s[0], s[1], s[2]}
+			case 4:
+				if s[3] > 0 {
+					k := 255 / s[3]
+					c = [3]float64{s[0] * k, s[1] * k, s[2] * k}
+				}
+			}
+			c = ConvertColorValue(c, from, to)
+			copy(dst[3*x:3*x+3], c[:])
+		}
+	}
+	return out
+}
+
+// ConvertColorValue converts a color from one color space to another.
+func ConvertColorValue(c [3]float64, from, to ColorSpace) [3]float64 {
+	if from == to {
+		return c
+	}
+	return fromRGB(toRGB(c, from), to)
+}
+
+// toRGB converts a color to sRGB with channels in 0..1.
+func toRGB(c [3]float64, from ColorSpace) [3]float64 {
+	switch from {
+	case ColorLinearRGB:
+		return [3]float64{gammaEncode(c[0]), gammaEncode(c[1]), gammaEncode(c[2])}
+	case ColorHSV:
+		return hsvToRGB(c)
+	case ColorHSL:
+		return hslToRGB(c)
+	case ColorYCbCr601:
+		return ycbcrToRGB(c, luma601)
+	case ColorYCbCr709:
+		return ycbcrToRGB(c, luma709)
+	case ColorXYZ:
+		return toRGB(xyzToLinear(c), ColorLinearRGB)
+	case ColorLab:
+		return toRGB(labToXYZ(c), ColorXYZ)
+	case ColorLuv:
+		return toRGB(luvToXYZ(c), ColorXYZ)
+	}
+	return [3]float64{c[0] / 255, c[1] / 255, c[2] / 255}
+}
+
+// fromRGB converts a color from sRGB with channels in 0..1.
+func fromRGB(c [3]float64, to ColorSpace) [3]float64 {
+	switch to {
+	case ColorLinearRGB:
+		return [3]float64{gammaDecode(c[0]), gammaDecode(c[1]), gammaDecode(c[2])}
+	case ColorHSV:
+		return rgbToHSV(c)
+	case ColorHSL:
+		return rgbToHSL(c)
+	case ColorYCbCr601:
+		return rgbToYCbCr(c, luma601)
+	case ColorYCbCr709:
+		return rgbToYCbCr(c, luma709)
+	case ColorXYZ:
+		return linearToXYZ(fromRGB(c, ColorLinearRGB))
+	case ColorLab:
+		return xyzToLab(fromRGB(c, ColorXYZ))
+	case ColorLuv:
+		return xyzToLuv(fromRGB(c, ColorXYZ))
+	}
+	return [3]float64{c[0] * 255, c[1] * 255, c[2] * 255}
+}
+
+// gammaDecode applies the inverse sRGB transfer function.
+func gammaDecode(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// gammaEncode applies the sRGB transfer function.
+func gammaEncode(v float64) float64 {
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+func rgbToHSV(c [3]float64) [3]float64 {
+	hi := math.Max(c[0], math.Max(c[1], c[2]))
+	lo := math.Min(c[0], math.Min(c[1], c[2]))
+	s := 0.
+	if hi > 0 {
+		s = (hi - lo) / hi
+	}
+	return [3]float64{hue(c, hi, lo), s, hi}
+}
+
+func hsvToRGB(c [3]float64) [3]float64 {
+	chroma := c[2] * c[1]
+	return hueToRGB(c[0], chroma, c[2]-chroma)
+}
+
+func rgbToHSL(c [3]float64) [3]float64 {
+	hi := math.Max(c[0], math.Max(c[1], c[2]))
+	lo := math.Min(c[0], math.Min(c[1], c[2]))
+	l := (hi + lo) / 2
+	s := 0.
+	if hi > lo {
+		s = (hi - lo) / (1 - math.Abs(2*l-1))
+	}
+	return [3]float64{hue(c, hi, lo), s, l}
+}
+
+func hslToRGB(c [3]float64) [3]float64 {
+	chroma := (1 - math.Abs(2*c[2]-1)) * c[1]
+	return hueToRGB(c[0], chroma, c[2]-chroma/2)
+}
+
+// hue returns the hue in degrees of a color with the given maximum and
+// minimum channels, which is zero for grays.
+func hue(c [3]float64, hi, lo float64) float64 {
+	d := hi - lo
+	if d == 0 {
+		return 0
+	}
+	var h float64
+	switch hi {
+	case c[0]:
+		h = math.Mod((c[1]-c[2])/d, 6)
+	case c[1]:
+		h = (c[2]-c[0])/d + 2
+	default:
+		h = (c[0]-c[1])/d + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// hueToRGB returns the color with the given hue, chroma and lightness offset
+// m, which is added to every channel.
+func hueToRGB(h, chroma, m float64) [3]float64 {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	h /= 60
+	x := chroma * (1 - math.Abs(math.Mod(h, 2)-1))
+	var r, g, b float64
+	switch {
+	case h < 1:
+		r, g = chroma, x
+	case h < 2:
+		r, g = x, chroma
+	case h < 3:
+		g, b = chroma, x
+	case h < 4:
+		g, b = x, chroma
+	case h < 5:
+		r, b = x, chroma
+	default:
+		r, b = chroma, x
+	}
+	return [3]float64{r + m, g + m, b + m}
+}
+
+// rgbToYCbCr converts to full range YCbCr with the given luma weights.
+func rgbToYCbCr(c [3]float64, k [3]float64) [3]float64 {
+	y := k[0]*c[0] + k[1]*c[1] + k[2]*c[2]
+	cb := (c[2] - y) / (2 * (1 - k[2]))
+	cr := (c[0] - y) / (2 * (1 - k[0]))
+	return [3]float64{255 * y, 255*cb + 128, 255*cr + 128}
+}
+
+func ycbcrToRGB(c [3]float64, k [3]float64) [3]float64 {
+	y, cb, cr := c[0]/255, (c[1]-128)/255, (c[2]-128)/255
+	r := y + 2*(1-k[0])*cr
+	b := y + 2*(1-k[2])*cb
+	g := (y - k[0]*r - k[2]*b) / k[1]
+	return [3]float64{r, g, b}
+}
+
+// sRGB primaries in CIE XYZ, and their inverse.
+var (
+	linearXYZ = [3][3]float64{
+		{0.4124564, 0.3575761, 0.1804375},
+		{0.2126729, 0.7151522, 0.0721750},
+		{0.0193339, 0.1191920, 0.9503041},
+	}
+	xyzLinear = invert3(linearXYZ)
+)
+
+// invert3 inverts a 3 by 3 matrix by its adjugate.
+func invert3(m [3][3]float64) [3][3]float64 {
+	var inv [3][3]float64
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			r0, r1 := (c+1)%3, (c+2)%3
+			c0, c1 := (r+1)%3, (r+2)%3
+			inv[r][c] = m[r0][c0]*m[r1][c1] - m[r0][c1]*m[r1][c0]
+		}
+	}
+	det := m[0][0]*inv[0][0] + m[0][1]*inv[1][0] + m[0][2]*inv[2][0]
+	for r := range inv {
+		for c := range inv[r] {
+			inv[r][c] /= det
+		}
+	}
+	return inv
+}
+
+// mul3 multiplies a 3 by 3 matrix and a vector.
+func mul3(m [3][3]float64, v [3]float64) [3]float64 {
+	var out [3]float64
+	for r := range out {
+		out[r] = m[r][0]*v[0] + m[r][1]*v[1] + m[r][2]*v[2]
+	}
+	return out
+}
+
+func linearToXYZ(c [3]float64) [3]float64 {
+	return mul3(linearXYZ, c)
+}
+
+func xyzToLinear(c [3]float64) [3]float64 {
+	return mul3(xyzLinear, c)
+}
+
+// labF is the CIE L* function, normalized to 0..1.
+func labF(t float64) float64 {
+	if t > cieEpsilon {
+		return math.Cbrt(t)
+	}
+	return (cieKappa*t + 16) / 116
+}
+
+// labFInv inverts labF.
+func labFInv(f float64) float64 {
+	if t := f * f * f; t > cieEpsilon {
+		return t
+	}
+	return (116*f - 16) / cieKappa
+}
+
+func xyzToLab(c [3]float64) [3]float64 {
+	fx := labF(c[0] / whiteD65[0])
+	fy := labF(c[1] / whiteD65[1])
+	fz := labF(c[2] / whiteD65[2])
+	return [3]float64{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)}
+}
+
+func labToXYZ(c [3]float64) [3]float64 {
+	fy := (c[0] + 16) / 116
+	fx := fy + c[1]/500
+	fz := fy - c[2]/200
+	return [3]float64{whiteD65[0] * labFInv(fx), whiteD65[1] * labFInv(fy), whiteD65[2] * labFInv(fz)}
+}
+
+// uv returns the CIE 1976 u' and v' chromaticity of a color.
+func uv(c [3]float64) (u, v float64) {
+	d := c[0] + 15*c[1] + 3*c[2]
+	if d == 0 {
+		return 0, 0
+	}
+	return 4 * c[0] / d, 9 * c[1] / d
+}
+
+func xyzToLuv(c [3]float64) [3]float64 {
+	l := 116*labF(c[1]/whiteD65[1]) - 16
+	if l <= 0 {
+		return [3]float64{}
+	}
+	u, v := uv(c)
+	un, vn := uv(whiteD65)
+	return [3]float64{l, 13 * l * (u - un), 13 * l * (v - vn)}
+}
+
+func luvToXYZ(c [3]float64) [3]float64 {
+	if c[0] <= 0 {
+		return [3]float64{}
+	}
+	un, vn := uv(whiteD65)
+	u := c[1]/(13*c[0]) + un
+	v := c[2]/(13*c[0]) + vn
+	y := whiteD65[1] * labFInv((c[0]+16)/116)
+	return [3]float64{y * 9 * u / (4 * v), y, y * (12 - 3*u - 20*v) / (4 * v)}
+}
+
+// DeltaE returns the CIE76 color difference of two L*a*b* colors, which is
+// their Euclidean distance.
+func DeltaE(lab1, lab2 [3]float64) float64 {
+	dl, da, db := lab1[0]-lab2[0], lab1[1]-lab2[1], lab1[2]-lab2[2]
+	return math.Sqrt(dl*dl + da*da + db*db)
+}
+
+// DeltaE2000 returns the CIEDE2000 color difference of two L*a*b* colors,
+// which follows perceived differences more closely than DeltaE, as given in
+// G. Sharma, W. Wu and E. N. Dalal, The CIEDE2000 color-difference formula: Implementation notes, supplementary test data, and mathematical observations,
+// Color Research & Application, 30 (2005), pp. 21–30.
+// https://doi.org/10.1002/col.20070
+func DeltaE2000(lab1, lab2 [3]float64) float64 {
+	const deg = math.Pi / 180
+	pow7 := func(x float64) float64 { return x * x * x * x * x * x * x }
+	c1, c2 := math.Hypot(lab1[1], lab1[2]), math.Hypot(lab2[1], lab2[2])
+	cm := (c1 + c2) / 2
+	g := 0.5 * (1 - math.Sqrt(pow7(cm)/(pow7(cm)+pow7(25))))
+	a1, a2 := (1+g)*lab1[1], (1+g)*lab2[1]
+	c1, c2 = math.Hypot(a1, lab1[2]), math.Hypot(a2, lab2[2])
+	angle := func(b, a float64) float64 {
+		if a == 0 && b == 0 {
+			return 0
+		}
+		h := math.Atan2(b, a) / deg
+		if h < 0 {
+			h += 360
+		}
+		return h
+	}
+	h1, h2 := angle(lab1[2], a1), angle(lab2[2], a2)
+
+	dl := lab2[0] - lab1[0]
+	dc := c2 - c1
+	dh := 0.
+	if c1*c2 != 0 {
+		dh = h2 - h1
+		if dh > 180 {
+			dh -= 360
+		} else if dh < -180 {
+			dh += 360
+		}
+	}
+	dH := 2 * math.Sqrt(c1*c2) * math.Sin(dh/2*deg)
+
+	lm := (lab1[0] + lab2[0]) / 2
+	cm = (c1 + c2) / 2
+	hm := h1 + h2
+	if c1*c2 != 0 {
+		switch {
+		case math.Abs(h1-h2) <= 180:
+			hm /= 2
+		case hm < 360:
+			hm = (hm + 360) / 2
+		default:
+			hm = (hm - 360) / 2
+		}
+	}
+	t := 1 - 0.17*math.Cos((hm-30)*deg) + 0.24*math.Cos(2*hm*deg) +
+		0.32*math.Cos((3*hm+6)*deg) - 0.20*math.Cos((4*hm-63)*deg)
+	dθ := 30 * math.Exp(-((hm-275)/25)*((hm-275)/25))
+	rc := 2 * math.Sqrt(pow7(cm)/(pow7(cm)+pow7(25)))
+	sl := 1 + 0.015*(lm-50)*(lm-50)/math.Sqrt(20+(lm-50)*(lm-50))
+	sc := 1 + 0.045*cm
+	sh := 1 + 0.015*cm*t
+	rt := -math.Sin(2*dθ*deg) * rc
+	l, c, h := dl/sl, dc/sc, dH/sh
+	return math.Sqrt(l*l + c*c + h*h + rt*c*h)
+}
@@ -0,0 +1,87 @@
+package vision
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestConvertColorValue(t *testing.T) {
+	cases := []struct {
+		space    ColorSpace
+		rgb      [3]float64
+		expected [3]float64
+	}{
+		{ColorHSV, [3]float64{255, 0, 0}, [3]float64{0, 1, 1}},
+		{ColorHSV, [3]float64{0, 127.5, 255}, [3]float64{210, 1, 1}},
+		{ColorHSL, [3]float64{0, 255, 0}, [3]float64{120, 1, 0.5}},
+		{ColorHSL, [3]float64{191.25, 63.75, 191.25}, [3]float64{300, 0.5, 0.5}},
+		{ColorYCbCr601, [3]float64{255, 255, 255}, [3]float64{255, 128, 128}},
+		{ColorYCbCr709, [3]float64{0, 0, 255}, [3]float64{18.411, 255.5, 116.2605}},
+		{ColorLinearRGB, [3]float64{255, 127.5, 0}, [3]float64{1, 0.21404, 0}},
+		{ColorXYZ, [3]float64{255, 255, 255}, whiteD65},
+		{ColorLab, [3]float64{255, 0, 0}, [3]float64{53.2408, 80.0925, 67.2032}},
+		{ColorLab, [3]float64{128, 128, 128}, [3]float64{53.5850, 0, 0}},
+		{ColorLuv, [3]float64{0, 0, 255}, [3]float64{32.3026, -9.4046, -130.3423}},
+	}
+	for _, c := range cases {
+		actual := ConvertColorValue(c.rgb, ColorRGB, c.space)
+		for i := range actual {
+			if math.Abs(actual[i]-c.expected[i]) > 1e-3*math.Max(1, math.Abs(c.expected[i])) {
+				t.Errorf("%v in space %d: expected %v, got %v", c.rgb, c.space, c.expected, actual)
+				break
+			}
+		}
+		back := ConvertColorValue(actual, c.space, ColorRGB)
+		for i := range back {
+			if math.Abs(back[i]-c.rgb[i]) > 1e-6 {
+				t.Errorf("%v in space %d: round trip gave %v", c.rgb, c.space, back)
+				break
+			}
+		}
+	}
+}
+
+func TestConvertColor(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.SetRGBA(0, 0, color.RGBA{255, 0, 0, 255})
+	img.SetRGBA(1, 0, color.RGBA{64, 0, 0, 128})
+	lab := ConvertColor(ImageToFloat64(img), ColorRGB, ColorLab)
+	if lab.Channels != 3 {
+		t.Fatalf("expected 3 channels, got %d", lab.Channels)
+	}
+	expected := ConvertColorValue([3]float64{64 * 255. / 128, 0, 0}, ColorRGB, ColorLab)
+	for i := range expected {
+		if actual := lab.FloatAt(1, 0, i); math.Abs(actual-expected[i]) > 1e-9 {
+			t.Errorf("translucent pixel: expected %v, got %v in channel %d", expected, actual, i)
+		}
+	}
+
+	luma := Luma(img)
+	if luma.Channels != 1 || math.Abs(luma.FloatAt(0, 0, 0)-0.299*255) > 1e-9 {
+		t.Errorf("luma: got %v", luma.FloatAt(0, 0, 0))
+	}
+}
+
+func TestDeltaE2000(t *testing.T) {
+	//Test data of Sharma, Wu and Dalal
+	cases := []struct {
+		lab1, lab2 [3]float64
+		expected   float64
+	}{
+		{[3]float64{50, 2.6772, -79.7751}, [3]float64{50, 0, -82.7485}, 2.0425},
+		{[3]float64{50, 3.1571, -77.2803}, [3]float64{50, 0, -82.7485}, 2.8615},
+		{[3]float64{50, -1, 2}, [3]float64{50, 0, 0}, 2.3669},
+		{[3]float64{50, 2.5, 0}, [3]float64{73, 25, -18}, 27.1492},
+		{[3]float64{2.0776, 0.0795, -1.1350}, [3]float64{0.9033, -0.0636, -0.5514}, 0.9082},
+	}
+	for _, c := range cases {
+		if actual := DeltaE2000(c.lab1, c.lab2); math.Abs(actual-c.expected) > 1e-4 {
+			t.Errorf("%v and %v: expected %v, got %v", c.lab1, c.lab2, c.expected, actual)
+		}
+	}
+	if d := DeltaE([3]float64{50, 3, 4}, [3]float64{50, 0, 0}); d != 5 {
+		t.Errorf("DeltaE: expected 5, got %v", d)
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
+	"math"
 
-	"github.com/anthonynsimon/bild/math/f64"
 	"github.com/joaowiciuk/matrix"
 )
 
@@ -17,43 +17,103 @@ import (
 //		GREEN 	-> 1
 //		BLUE 	-> 2
 //		ALPHA 	-> 3
+// The values keep the native range of the samples: images with 16-bit color
+// models, such as Gray16, RGBA64 and NRGBA64, are converted losslessly to
+// values from 0 to 65535 and any other image to values from 0 to 255.
+// Float64Image and Float32Image keep their values, which use the 0..255
+// range of ImageToFloat64, so for every image other than a 16-bit one the
+// matrices hold the channels of ImageToFloat64. Color images are
+// alpha-premultiplied; see Im2MatWith to keep the samples of NRGBA and
+// NRGBA64 images.
 // If the image pointer is nil the function produces nothing and returns a nil pointer, so remember always
 // checking for nil when using it.
 func Im2Mat(i image.Image) (array []*matrix.Matrix) {
-	if i == nil {
+	return Im2MatWith(i, Im2MatOptions{})
+}
+
+// Im2MatOptions configures the conversion of an image into matrices.
+type Im2MatOptions struct {
+	// NonPremultiplied keeps the non alpha-premultiplied samples of NRGBA and
+	// NRGBA64 images, the inverse of Mat2ImOptions.NonPremultiplied.
+	NonPremultiplied bool
+}
+
+// Im2MatWith converts an image to an array of matrices like Im2Mat, with
+// the given options.
+func Im2MatWith(i image.Image, opts Im2MatOptions) (array []*matrix.Matrix) {
+	switch f := i.(type) {
+	case nil:
 		return
+	case *Float64Image:
+		return f.Matrices()
+	case *Float32Image:
+		return f.Float64().Matrices()
+	}
+	model := i.ColorModel()
+	if !opts.NonPremultiplied {
+		//Premultiplied samples come from the paths of RGBA64 and RGBA images
+		switch model {
+		case color.NRGBA64Model:
+			model = color.RGBA64Model
+		case color.NRGBAModel:
+			model = color.RGBAModel
+		}
 	}
 	b := i.Bounds()
-	switch i.ColorModel() {
-	case color.GrayModel, color.Gray16Model:
-		array = make([]*matrix.Matrix, 1)
-		array[0] = matrix.New(b.Dy(), b.Dx())
-		imgGRAY := image.NewGray(b)
-		draw.Draw(imgGRAY, b, i, b.Min, draw.Src)
-		for x := 0; x < b.Dx(); x++ {
-			for y := 0; y < b.Dy(); y++ {
-				c := imgGRAY.GrayAt(x, y)
-				(*array[0])[y][x] = float64(c.Y)
-			}
+	switch model {
+	case color.Gray16Model:
+		img, ok := i.(*image.Gray16)
+		if !ok {
+			img = image.NewGray16(b)
+			draw.Draw(img, b, i, b.Min, draw.Src)
 		}
-	default:
-		array = make([]*matrix.Matrix, 4)
-		for i := range array {
-			array[i] = matrix.New(b.Dy(), b.Dx())
+		return pixMatrices(img.Pix[img.PixOffset(b.Min.X, b.Min.Y):], img.Stride, b, 1, 2)
+	case color.RGBA64Model:
+		img, ok := i.(*image.RGBA64)
+		if !ok {
+			img = image.NewRGBA64(b)
+			draw.Draw(img, b, i, b.Min, draw.Src)
+		}
+		return pixMatrices(img.Pix[img.PixOffset(b.Min.X, b.Min.Y):], img.Stride, b, 4, 2)
+	case color.NRGBA64Model:
+		img, ok := i.(*image.NRGBA64)
+		if !ok {
+			img = image.NewNRGBA64(b)
+			draw.Draw(img, b, i, b.Min, draw.Src)
 		}
-		imgRGBA := image.NewRGBA(b)
-		draw.Draw(imgRGBA, b, i, b.Min, draw.Src)
+		return pixMatrices(img.Pix[img.PixOffset(b.Min.X, b.Min.Y):], img.Stride, b, 4, 2)
+	case color.NRGBAModel:
+		img, ok := i.(*image.NRGBA)
+		if !ok {
+			img = image.NewNRGBA(b)
+			draw.Draw(img, b, i, b.Min, draw.Src)
+		}
+		return pixMatrices(img.Pix[img.PixOffset(b.Min.X, b.Min.Y):], img.Stride, b, 4, 1)
+	}
+	return ImageToFloat64(i).Matrices()
+}
+
+// pixMatrices splits the interleaved samples of a w by h image, stored in
+// big-endian with the given bytes per sample, into one matrix per channel.
+func pixMatrices(pix []uint8, stride int, b image.Rectangle, channels, bytes int) []*matrix.Matrix {
+	array := make([]*matrix.Matrix, channels)
+	for k := range array {
+		array[k] = matrix.New(b.Dy(), b.Dx())
+	}
+	for y := 0; y < b.Dy(); y++ {
+		row := pix[y*stride:]
 		for x := 0; x < b.Dx(); x++ {
-			for y := 0; y < b.Dy(); y++ {
-				C := imgRGBA.RGBAAt(x, y)
-				(*array[0])[y][x] = float64(C.R) //RED
-				(*array[1])[y][x] = float64(C.G) //GREEN
-				(*array[2])[y][x] = float64(C.B) //BLUE
-				(*array[3])[y][x] = float64(C.A) //ALPHA
+			for k, mat := range array {
+				i := (x*channels + k) * bytes
+				v := uint16(row[i])
+				if bytes == 2 {
+					v = v<<8 | uint16(row[i+1])
+				}
+				(*mat)[y][x] = float64(v)
 			}
 		}
 	}
-	return
+	return array
 }
 
 // Gray2Mat converts a gray scale image to matrix.
@@ -61,32 +121,57 @@ func Gray2Mat(i *image.Gray) *matrix.Matrix {
 	if i == nil {
 		return nil
 	}
-	b := i.Bounds()
-	mat := matrix.New(b.Dy(), b.Dx())
-	for x := 0; x < b.Dx(); x++ {
-		for y := 0; y < b.Dy(); y++ {
-			c := i.GrayAt(x, y)
-			mat.Set(y, x, float64(c.Y))
-		}
-	}
-	return mat
+	return ImageToFloat64(i).Matrix()
 }
 
 // Mat2Gray converts a matrix into a gray scale image.
 func Mat2Gray(mat *matrix.Matrix) (gray *image.Gray) {
 	rows, cols := mat.Size()
 	gray = image.NewGray(image.Rect(0, 0, cols, rows))
-	for i := range gray.Pix {
-		row, col := mat.ToPoint(i)
-		gray.Pix[i] = uint8(clamp(mat.At(row, col), 0., 255.)) //GRAY
+	for y := 0; y < rows; y++ {
+		pix := gray.Pix[y*gray.Stride : y*gray.Stride+cols]
+		for x, v := range (*mat)[y] {
+			pix[x] = uint8(clamp(v, 0., 255.)) //GRAY
+		}
 	}
 	return
 }
 
+// ValueRange selects how matrix values are mapped to the samples of an image.
+type ValueRange int
+
+const (
+	// RangeClamp clamps the values to the range of the samples.
+	RangeClamp ValueRange = iota
+	// RangeNormalize maps the minimum and maximum of the color values to the
+	// range of the samples. Alpha values are clamped.
+	RangeNormalize
+	// RangeScale multiplies the values by a factor, as from 12 to 16 bits,
+	// and clamps them.
+	RangeScale
+)
+
+// Mat2ImOptions configures the conversion of matrices into an image.
+type Mat2ImOptions struct {
+	// Depth is the number of bits per sample, 8 or 16. Zero means 8.
+	Depth int
+	// Range selects the mapping of values to samples.
+	Range ValueRange
+	// Scale is the factor of RangeScale.
+	Scale float64
+	// NonPremultiplied produces NRGBA or NRGBA64 images from four matrices
+	// instead of RGBA or RGBA64 images.
+	NonPremultiplied bool
+	// Round rounds the mapped values to the nearest sample instead of
+	// truncating them, as Mat2Im and Mat2Gray do.
+	Round bool
+}
+
 // Mat2Im converts an valid array of matrices to an 8-bit color depth image and returns a pointer to it.
 // A valid array contains matrices of same size.
 // The function produces a grayscale image if the array has a single matrix and
-// a RGBA image if it has four matrices.
+// a RGBA image if it has three or four matrices, where three matrices produce
+// an opaque image. The values are clamped to the 0..255 range and truncated.
 // When converting to RGBA, the index to channel correspondence is the following:
 //		0 -> RED
 //		1 -> GREEN
@@ -95,62 +180,115 @@ func Mat2Gray(mat *matrix.Matrix) (gray *image.Gray) {
 // If the array is not valid the function produces nothing and returns a nil pointer, so remember always
 // checking for nil when using it.
 func Mat2Im(array []*matrix.Matrix) (ptr *image.Image) {
-	c := len(array)
-
-	//Checks if the input array is valid in lenght
-	if c != 1 && c != 4 {
-		ptr = nil
-		return
+	img := Mat2ImWith(array, Mat2ImOptions{})
+	if img == nil {
+		return nil
 	}
+	return &img
+}
 
-	//Checks if the input array is valid in elements dimensions
-	m, n := make([]int, c), make([]int, c)
-	for i, v := range array {
-		m[i], n[i] = v.Size()
-		if i == 0 {
-			continue
+// Mat2ImWith converts a valid array of one, three or four matrices to an
+// image like Mat2Im, with the depth and value mapping of the options. It
+// produces Gray or Gray16 images from a single matrix and RGBA, RGBA64, NRGBA
+// or NRGBA64 images otherwise, or nil if the array is not valid.
+func Mat2ImWith(array []*matrix.Matrix, opts Mat2ImOptions) image.Image {
+	c := len(array)
+	if c != 1 && c != 3 && c != 4 {
+		return nil
+	}
+	for _, mat := range array {
+		if mat == nil {
+			return nil
 		}
-		if m[i] != m[i-1] || n[i] != n[i-1] {
-			ptr = nil
-			return
+	}
+	m, n := array[0].Size()
+	for _, mat := range array[1:] {
+		if rows, cols := mat.Size(); rows != m || cols != n {
+			return nil
 		}
 	}
+	//Note the difference between rows and columns in an matrix and (x, y)
+	//coordinates in an image
+	b := image.Rect(0, 0, n, m)
 
-	//Since here its checked that all elements have same dimension, any element
-	//can be used to calculate the produced image bounds. Below the last
-	//element is used. Note the difference between rows and columns in an
-	//matrix and (x, y) coordinates in an image
-	b := image.Rect(0, 0, n[c-1], m[c-1])
+	bytes, top := 1, 255.
+	if opts.Depth == 16 {
+		bytes, top = 2, 65535.
+	}
+	channels := 4
+	if c == 1 {
+		channels = 1
+	}
+	var img image.Image
+	var pix []uint8
+	var stride int
+	switch {
+	case c == 1 && bytes == 1:
+		gray := image.NewGray(b)
+		img, pix, stride = gray, gray.Pix, gray.Stride
+	case c == 1:
+		gray := image.NewGray16(b)
+		img, pix, stride = gray, gray.Pix, gray.Stride
+	case bytes == 1 && opts.NonPremultiplied:
+		nrgba := image.NewNRGBA(b)
+		img, pix, stride = nrgba, nrgba.Pix, nrgba.Stride
+	case bytes == 1:
+		rgba := image.NewRGBA(b)
+		img, pix, stride = rgba, rgba.Pix, rgba.Stride
+	case opts.NonPremultiplied:
+		nrgba := image.NewNRGBA64(b)
+		img, pix, stride = nrgba, nrgba.Pix, nrgba.Stride
+	default:
+		rgba := image.NewRGBA64(b)
+		img, pix, stride = rgba, rgba.Pix, rgba.Stride
+	}
 
-	switch c {
-	case 4:
-		imgRGBA := image.NewRGBA(b)
-		n := 0
-		for i := range imgRGBA.Pix {
-			if i%4 == 0 {
-				//Here a for loop could be used, but for lucidity purposes the values
-				//are written explicitly. Ctrl + c, Ctrl + v helped a lot
-				//A matrix can pass for some operations like Laplacian, Gaussian, etc,
-				//thus it values should be numericaly "rearranged" for matching a 8-bit depth color,
-				//thus the use of Clamp function
-				row, col := array[0].ToPoint(n)
-				imgRGBA.Pix[i+0] = uint8(f64.Clamp(array[0].At(row, col), 0., 255.)) //RED
-				imgRGBA.Pix[i+1] = uint8(f64.Clamp(array[1].At(row, col), 0., 255.)) //GREEN
-				imgRGBA.Pix[i+2] = uint8(f64.Clamp(array[2].At(row, col), 0., 255.)) //BLUE
-				imgRGBA.Pix[i+3] = uint8(f64.Clamp(array[3].At(row, col), 0., 255.)) //ALPHA
-				n++
+	//A matrix can pass for some operations like Laplacian, Gaussian, etc,
+	//thus it values should be numericaly "rearranged" for matching the
+	//color depth
+	scale, offset := 1., 0.
+	switch opts.Range {
+	case RangeScale:
+		scale = opts.Scale
+	case RangeNormalize:
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, mat := range array[:min(c, 3)] {
+			for _, row := range *mat {
+				for _, v := range row {
+					lo, hi = math.Min(lo, v), math.Max(hi, v)
+				}
 			}
 		}
-		aux := image.Image(imgRGBA)
-		ptr = &aux
-	case 1:
-		imgGray := image.NewGray(b)
-		for i := range imgGray.Pix {
-			row, col := array[0].ToPoint(i)
-			imgGray.Pix[i] = uint8(f64.Clamp(array[0].At(row, col), 0., 255.)) //GRAY
+		if hi > lo {
+			scale, offset = top/(hi-lo), -lo*top/(hi-lo)
+		} else {
+			scale, offset = 0, 0
 		}
-		aux := image.Image(imgGray)
-		ptr = &aux
 	}
-	return
+	for y := 0; y < m; y++ {
+		row := pix[y*stride:]
+		for x := 0; x < n; x++ {
+			for k := 0; k < channels; k++ {
+				v := top
+				if k < c {
+					v = (*array[k])[y][x]
+					if k < 3 || opts.Range == RangeScale {
+						v = v*scale + offset
+					}
+				}
+				v = clamp(v, 0, top)
+				if opts.Round {
+					v = math.Floor(v + 0.5)
+				}
+				sample := uint16(v)
+				i := (x*channels + k) * bytes
+				if bytes == 2 {
+					row[i], row[i+1] = uint8(sample>>8), uint8(sample)
+				} else {
+					row[i] = uint8(sample)
+				}
+			}
+		}
+	}
+	return img
 }
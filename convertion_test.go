@@ -2,6 +2,7 @@ package vision_test
 
 import (
 	"image"
+	"image/color"
 	"testing"
 
 	"github.com/joaowiciuk/matrix"
@@ -119,3 +120,114 @@ func TestMat2Im(t *testing.T) {
 		}
 	}
 }
+
+func TestIm2Mat16(t *testing.T) {
+	b := image.Rect(1, 2, 4, 4)
+	gray := image.NewGray16(b)
+	nrgba := image.NewNRGBA64(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			v := uint16(x*4093 + y*517)
+			gray.SetGray16(x, y, color.Gray16{Y: v})
+			nrgba.SetNRGBA64(x, y, color.NRGBA64{R: v, G: v / 3, B: 4095 - v%4096, A: uint16(x * 9000)})
+		}
+	}
+	for _, c := range []struct {
+		desc string
+		img  image.Image
+		in   vision.Im2MatOptions
+		opts vision.Mat2ImOptions
+	}{
+		{"Gray16", gray, vision.Im2MatOptions{}, vision.Mat2ImOptions{Depth: 16}},
+		{"NRGBA64", nrgba, vision.Im2MatOptions{NonPremultiplied: true}, vision.Mat2ImOptions{Depth: 16, NonPremultiplied: true}},
+	} {
+		array := vision.Im2MatWith(c.img, c.in)
+		if v := (*array[0])[1][2]; v != float64(3*4093+3*517) {
+			t.Errorf("%s: expected lossless value %d, got %v", c.desc, 3*4093+3*517, v)
+		}
+		actual := vision.Mat2ImWith(array, c.opts)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				expected := c.img.At(x, y)
+				if got := actual.At(x-b.Min.X, y-b.Min.Y); got != expected {
+					t.Errorf("%s: at (%d, %d) expected %v, got %v", c.desc, x, y, expected, got)
+				}
+			}
+		}
+	}
+}
+
+func TestIm2MatNRGBA(t *testing.T) {
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	nrgba.SetNRGBA(0, 0, color.NRGBA{200, 100, 50, 128})
+	nrgba.SetNRGBA(1, 0, color.NRGBA{10, 20, 30, 255})
+
+	//The default round trip keeps the colors of decoded PNGs with alpha
+	back := *vision.Mat2Im(vision.Im2Mat(nrgba))
+	for x := 0; x < 2; x++ {
+		r0, g0, b0, a0 := nrgba.At(x, 0).RGBA()
+		r1, g1, b1, a1 := back.At(x, 0).RGBA()
+		if r0>>8 != r1>>8 || g0>>8 != g1>>8 || b0>>8 != b1>>8 || a0>>8 != a1>>8 {
+			t.Errorf("at %d expected %v, got %v", x, nrgba.At(x, 0), back.At(x, 0))
+		}
+	}
+	if r := (*vision.Im2Mat(nrgba)[0])[0][0]; r != 100 {
+		t.Errorf("expected the premultiplied red 100, got %v", r)
+	}
+
+	array := vision.Im2MatWith(nrgba, vision.Im2MatOptions{NonPremultiplied: true})
+	if r := (*array[0])[0][0]; r != 200 {
+		t.Errorf("expected the non-premultiplied red 200, got %v", r)
+	}
+	same := vision.Mat2ImWith(array, vision.Mat2ImOptions{NonPremultiplied: true})
+	if c := same.(*image.NRGBA).NRGBAAt(0, 0); c != (color.NRGBA{200, 100, 50, 128}) {
+		t.Errorf("expected the same samples, got %v", c)
+	}
+
+	//Float images keep their values
+	f := vision.ImageToFloat64(image.NewGray(image.Rect(0, 0, 1, 1)))
+	f.Pix[0] = 100
+	if a, b := (*vision.Im2Mat(f)[0])[0][0], (*vision.Im2Mat(f.Float32())[0])[0][0]; a != 100 || b != 100 {
+		t.Errorf("expected 100 from both float images, got %v and %v", a, b)
+	}
+}
+
+func TestMat2ImWith(t *testing.T) {
+	r := &matrix.Matrix{{-10, 30}, {70, 110}}
+	g := &matrix.Matrix{{0, 1}, {2, 3}}
+	b := &matrix.Matrix{{300, 200}, {100, 0}}
+
+	rgb := vision.Mat2ImWith([]*matrix.Matrix{r, g, b}, vision.Mat2ImOptions{})
+	if c := rgb.(*image.RGBA).RGBAAt(0, 0); c != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("clamp: expected opaque clamped color, got %v", c)
+	}
+
+	normalized := vision.Mat2ImWith([]*matrix.Matrix{r}, vision.Mat2ImOptions{Range: vision.RangeNormalize})
+	for i, expected := range []uint8{0, 85, 170, 255} {
+		if got := normalized.(*image.Gray).Pix[i]; got != expected {
+			t.Errorf("normalize: sample %d expected %d, got %d", i, expected, got)
+		}
+	}
+
+	twelve := &matrix.Matrix{{0, 4095}}
+	scaled := vision.Mat2ImWith([]*matrix.Matrix{twelve}, vision.Mat2ImOptions{Depth: 16, Range: vision.RangeScale, Scale: 16})
+	if c := scaled.(*image.Gray16).Gray16At(1, 0); c.Y != 65520 {
+		t.Errorf("scale: expected 65520, got %d", c.Y)
+	}
+
+	//Values are truncated like Mat2Gray unless rounding is asked
+	fractions := &matrix.Matrix{{1.7, 254.6}}
+	for round, expected := range map[bool][2]uint8{false: {1, 254}, true: {2, 255}} {
+		gray := vision.Mat2ImWith([]*matrix.Matrix{fractions}, vision.Mat2ImOptions{Round: round}).(*image.Gray)
+		if gray.Pix[0] != expected[0] || gray.Pix[1] != expected[1] {
+			t.Errorf("round %v: expected %v, got %v", round, expected, gray.Pix)
+		}
+	}
+	if p := vision.Mat2Gray(fractions).Pix; p[0] != 1 || p[1] != 254 {
+		t.Errorf("Mat2Gray: expected truncation, got %v", p)
+	}
+
+	if vision.Mat2ImWith([]*matrix.Matrix{r, g}, vision.Mat2ImOptions{}) != nil {
+		t.Errorf("two matrices should not be converted")
+	}
+}
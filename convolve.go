@@ -0,0 +1,275 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"runtime"
+	"sync"
+
+	"github.com/joaowiciuk/matrix"
+	"github.com/joaowiciuk/vision/kernel"
+)
+
+// rowTiles calls f concurrently on tiles of consecutive rows covering the h
+// rows, a few per processor.
+func rowTiles(h int, f func(y0, y1 int)) {
+	n := min(4*runtime.GOMAXPROCS(0), h)
+	if n < 1 {
+		return
+	}
+	size := (h + n - 1) / n
+	wg := sync.WaitGroup{}
+	for y0 := 0; y0 < h; y0 += size {
+		wg.Add(1)
+		go func(y0, y1 int) {
+			f(y0, y1)
+			wg.Done()
+		}(y0, min(y0+size, h))
+	}
+	wg.Wait()
+}
+
+// separable factors the kernel as the outer product col·rowᵀ if it has rank
+// one. The factorization is the leading singular pair, found as the leading
+// eigenvector of KᵀK, and the kernel is separable when the other singular
+// values are negligible.
+func separable(k [][]float64) (col, row []float64, ok bool) {
+	m, n := len(k), len(k[0])
+	ktk := make([][]float64, n)
+	for i := range ktk {
+		ktk[i] = make([]float64, n)
+		for j := range ktk[i] {
+			for r := 0; r < m; r++ {
+				ktk[i][j] += k[r][i] * k[r][j]
+			}
+		}
+	}
+	values, vectors := symmetricEigen(ktk)
+	total := 0.
+	for _, v := range values {
+		total += math.Abs(v)
+	}
+	if total == 0 || total-values[n-1] > 1e-10*total {
+		return nil, nil, false
+	}
+	row = vectors[n-1]
+	col = make([]float64, m)
+	for r := range col {
+		for c := range row {
+			col[r] += k[r][c] * row[c]
+		}
+	}
+	return col, row, true
+}
+
+// convolveRows convolves each row of the w by h values with the kernel k
+// anchored at c.
+func convolveRows(values []float64, w, h int, k []float64, c int, border Border) []float64 {
+	out := make([]float64, w*h)
+	index := make([]int, w*len(k))
+	for x := 0; x < w; x++ {
+		for i := range k {
+			index[x*len(k)+i] = borderIndex(x+c-i, w, border)
+		}
+	}
+	rowTiles(h, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			row := values[y*w : (y+1)*w]
+			for x := 0; x < w; x++ {
+				sum := 0.
+				for i, v := range k {
+					if j := index[x*len(k)+i]; j >= 0 {
+						sum += v * row[j]
+					}
+				}
+				out[y*w+x] = sum
+			}
+		}
+	})
+	return out
+}
+
+// convolveCols convolves each column of the w by h values with the kernel k
+// anchored at c.
+func convolveCols(values []float64, w, h int, k []float64, c int, border Border) []float64 {
+	out := make([]float64, w*h)
+	rowTiles(h, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			dst := out[y*w : (y+1)*w]
+			for j, v := range k {
+				r := borderIndex(y+c-j, h, border)
+				if r < 0 || v == 0 {
+					continue
+				}
+				for x, s := range values[r*w : (r+1)*w] {
+					dst[x] += v * s
+				}
+			}
+		}
+	})
+	return out
+}
+
+// convolveDirect convolves the w by h values with the kernel anchored at
+// (cx, cy) by direct summation.
+func convolveDirect(values []float64, w, h int, k [][]float64, cx, cy int, border Border) []float64 {
+	n := len(k[0])
+	index := make([]int, w*n)
+	for x := 0; x < w; x++ {
+		for i := 0; i < n; i++ {
+			index[x*n+i] = borderIndex(x+cx-i, w, border)
+		}
+	}
+	out := make([]float64, w*h)
+	rowTiles(h, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			dst := out[y*w : (y+1)*w]
+			for j, kr := range k {
+				r := borderIndex(y+cy-j, h, border)
+				if r < 0 {
+					continue
+				}
+				row := values[r*w : (r+1)*w]
+				for x := range dst {
+					sum := 0.
+					for i, v := range kr {
+						if c := index[x*n+i]; c >= 0 {
+							sum += v * row[c]
+						}
+					}
+					dst[x] += sum
+				}
+			}
+		}
+	})
+	return out
+}
+
+// fftConvolver convolves planes of the same size with one kernel through
+// the FFT of the image extended at its borders, which must be at least as
+// large as the linear convolution so the circular one does not wrap.
+type fftConvolver struct {
+	w, h, cx, cy int
+	border       Border
+	m, n, fw, fh int
+	spectrum     []complex128
+}
+
+func newFFTConvolver(k [][]float64, w, h, cx, cy int, border Border) *fftConvolver {
+	m, n := len(k), len(k[0])
+	fc := &fftConvolver{w: w, h: h, cx: cx, cy: cy, border: border, m: m, n: n}
+	fc.fw, fc.fh = nextPow2(w+n-1), nextPow2(h+m-1)
+	fc.spectrum = make([]complex128, fc.fw*fc.fh)
+	for j, row := range k {
+		for i, v := range row {
+			fc.spectrum[j*fc.fw+i] = complex(v, 0)
+		}
+	}
+	fft2(fc.spectrum, fc.fw, fc.fh, false)
+	return fc
+}
+
+func (fc *fftConvolver) convolve(values []float64) []float64 {
+	//Extend the image so that output (x, y) is the full convolution at
+	//(x + n - 1, y + m - 1)
+	ox, oy := fc.n-1-fc.cx, fc.m-1-fc.cy
+	data := make([]complex128, fc.fw*fc.fh)
+	for v := 0; v < fc.h+fc.m-1; v++ {
+		r := borderIndex(v-oy, fc.h, fc.border)
+		if r < 0 {
+			continue
+		}
+		for u := 0; u < fc.w+fc.n-1; u++ {
+			if c := borderIndex(u-ox, fc.w, fc.border); c >= 0 {
+				data[v*fc.fw+u] = complex(values[r*fc.w+c], 0)
+			}
+		}
+	}
+	fft2(data, fc.fw, fc.fh, false)
+	for i := range data {
+		data[i] *= fc.spectrum[i]
+	}
+	fft2(data, fc.fw, fc.fh, true)
+	out := make([]float64, fc.w*fc.h)
+	for y := 0; y < fc.h; y++ {
+		for x := 0; x < fc.w; x++ {
+			out[y*fc.w+x] = real(data[(y+fc.m-1)*fc.fw+x+fc.n-1])
+		}
+	}
+	return out
+}
+
+// Convolve convolves each channel of the image with the kernel, anchored at
+// its center element (k.Center()), extending the image with the given
+// border mode. The output keeps the values of ImageToFloat64 without
+// clamping, so kernels with negative weights can be applied.
+//
+// Kernels of rank one, such as gaussians, box filters and Sobel operators,
+// are detected and applied as a vertical and a horizontal 1D pass. Other
+// kernels are applied directly when small and through the FFT when their
+// area exceeds a few times the logarithm of the transform size. Rows are
+// processed concurrently in tiles. It returns nil if the image or the kernel
+// are empty.
+func Convolve(img image.Image, k *matrix.Matrix, border Border) *Float64Image {
+	if k == nil {
+		return nil
+	}
+	m, n := k.Size()
+	if m == 0 || n == 0 {
+		return nil
+	}
+	cx, cy := k.Center()
+	col, row, ok := separable(*k)
+	return convolve(ImageToFloat64(img), *k, image.Pt(cx, cy), col, row, ok, border)
+}
+
+// ApplyKernel convolves each channel of the image with the kernel like
+// Convolve, aligning its anchor with each output pixel and using its 1D
+// factors when it is separable.
+func ApplyKernel(img image.Image, k *kernel.Kernel, border Border) *Float64Image {
+	if k == nil || k.Weights == nil {
+		return nil
+	}
+	m, n := k.Weights.Size()
+	if m == 0 || n == 0 || !k.Anchor.In(image.Rect(0, 0, n, m)) {
+		return nil
+	}
+	for _, row := range *k.Weights {
+		if len(row) != n {
+			return nil
+		}
+	}
+	col, row, ok := k.Factors()
+	return convolve(ImageToFloat64(img), *k.Weights, k.Anchor, col, row, ok, border)
+}
+
+// convolve convolves each channel of the image with the kernel anchored at
+// the given element, as the vertical and horizontal factors col and row if
+// it is separable.
+func convolve(f *Float64Image, k [][]float64, anchor image.Point, col, row []float64, separable bool, border Border) *Float64Image {
+	if f == nil || f.Rect.Empty() {
+		return nil
+	}
+	m, n := len(k), len(k[0])
+	cx, cy := anchor.X, anchor.Y
+	w, h := f.Rect.Dx(), f.Rect.Dy()
+	out := NewFloat64Image(f.Rect, f.Channels)
+
+	var apply func([]float64) []float64
+	if separable {
+		apply = func(values []float64) []float64 {
+			return convolveCols(convolveRows(values, w, h, row, cx, border), w, h, col, cy, border)
+		}
+	} else if fw, fh := nextPow2(w+n-1), nextPow2(h+m-1); float64(m*n) > 4*math.Log2(float64(fw*fh)) {
+		fc := newFFTConvolver(k, w, h, cx, cy, border)
+		apply = fc.convolve
+	} else {
+		apply = func(values []float64) []float64 {
+			return convolveDirect(values, w, h, k, cx, cy, border)
+		}
+	}
+	for c := 0; c < f.Channels; c++ {
+		out.setChannel(c, apply(f.channel(c)))
+	}
+	return out
+}
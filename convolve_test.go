@@ -0,0 +1,128 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/joaowiciuk/matrix"
+	"github.com/joaowiciuk/vision/kernel"
+)
+
+// referenceConvolve convolves the single channel image by the definition.
+func referenceConvolve(f *Float64Image, k *matrix.Matrix, border Border) []float64 {
+	m, n := k.Size()
+	cx, cy := k.Center()
+	w, h := f.Rect.Dx(), f.Rect.Dy()
+	out := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			for j := 0; j < m; j++ {
+				for i := 0; i < n; i++ {
+					xx, yy := borderIndex(x+cx-i, w, border), borderIndex(y+cy-j, h, border)
+					if xx >= 0 && yy >= 0 {
+						out[y*w+x] += (*k)[j][i] * f.Pix[yy*w+xx]
+					}
+				}
+			}
+		}
+	}
+	return out
+}
+
+func TestConvolve(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	gray := image.NewGray(image.Rect(0, 0, 37, 23))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(rng.Intn(256))
+	}
+	f := ImageToFloat64(gray)
+	random := func(m, n int) *matrix.Matrix {
+		k := matrix.New(m, n)
+		for r := range *k {
+			for c := range (*k)[r] {
+				(*k)[r][c] = rng.Float64() - 0.5
+			}
+		}
+		return k
+	}
+	cases := []struct {
+		name      string
+		k         *matrix.Matrix
+		separable bool
+	}{
+		{"sobel", &matrix.Matrix{{1, 0, -1}, {2, 0, -2}, {1, 0, -1}}, true},
+		{"row", &matrix.Matrix{{1, 2, 3, 4}}, true},
+		{"laplacian", &matrix.Matrix{{0, 1, 0}, {1, -4, 1}, {0, 1, 0}}, false},
+		{"direct", random(3, 4), false},
+		{"fft", random(9, 12), false},
+	}
+	for _, c := range cases {
+		if _, _, ok := separable(*c.k); ok != c.separable {
+			t.Errorf("%s: expected separable %v", c.name, c.separable)
+		}
+		for _, border := range []Border{BorderConstant, BorderReplicate, BorderReflect101, BorderWrap} {
+			expected := referenceConvolve(f, c.k, border)
+			actual := Convolve(gray, c.k, border)
+			for i, v := range expected {
+				if math.Abs(actual.Pix[i]-v) > 1e-6 {
+					t.Errorf("%s with border %d: sample %d expected %v, got %v", c.name, border, i, v, actual.Pix[i])
+					break
+				}
+			}
+		}
+	}
+
+	rgba := image.NewRGBA(image.Rect(2, 3, 10, 9))
+	for i := range rgba.Pix {
+		rgba.Pix[i] = uint8(rng.Intn(256))
+	}
+	out := Convolve(rgba, &matrix.Matrix{{1}}, BorderReplicate)
+	if out.Channels != 4 || out.Rect != rgba.Rect || out.FloatAt(5, 5, 2) != float64(rgba.RGBAAt(5, 5).B) {
+		t.Errorf("identity kernel should keep the image")
+	}
+}
+
+func TestApplyKernel(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	gray := image.NewGray(image.Rect(0, 0, 19, 13))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(rng.Intn(256))
+	}
+	for _, k := range []*kernel.Kernel{kernel.Sobel(5, 1, 0), kernel.LaplacianOfGaussian(1), kernel.Kirsch()[1]} {
+		expected := Convolve(gray, k.Weights, BorderReflect101)
+		actual := ApplyKernel(gray, k, BorderReflect101)
+		for i, v := range expected.Pix {
+			if math.Abs(actual.Pix[i]-v) > 1e-6 {
+				t.Fatalf("sample %d expected %v, got %v", i, v, actual.Pix[i])
+			}
+		}
+	}
+	//A kernel anchored at its first element shifts the image
+	k := kernel.New(&matrix.Matrix{{0, 0, 1}})
+	k.Anchor = image.Pt(0, 0)
+	out := ApplyKernel(gray, k, BorderConstant)
+	if out.FloatAt(5, 5, 0) != float64(gray.GrayAt(3, 5).Y) {
+		t.Errorf("expected the pixel two columns left, got %v", out.FloatAt(5, 5, 0))
+	}
+	k.Anchor = image.Pt(3, 0)
+	if ApplyKernel(gray, k, BorderConstant) != nil {
+		t.Errorf("an anchor outside the kernel should give nil")
+	}
+
+	//Kernels built without factors, or whose weights changed, are still
+	//applied correctly
+	sobel := &matrix.Matrix{{1, 0, -1}, {2, 0, -2}, {1, 0, -1}}
+	expected := Convolve(gray, sobel, BorderReplicate)
+	changed := kernel.NewSeparable([]float64{1}, []float64{1, 2, 3})
+	changed.Weights, changed.Anchor = sobel, image.Pt(1, 1)
+	for _, k := range []*kernel.Kernel{{Weights: sobel, Anchor: image.Pt(1, 1)}, changed} {
+		actual := ApplyKernel(gray, k, BorderReplicate)
+		for i, v := range expected.Pix {
+			if math.Abs(actual.Pix[i]-v) > 1e-6 {
+				t.Fatalf("sample %d expected %v, got %v", i, v, actual.Pix[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"sync"
+)
+
+// standard converts the image to a Gray image if it has a single channel and
+// to an RGBA image otherwise, which are the outputs of the denoising filters.
+func (f *Float64Image) standard() image.Image {
+	if f.Channels == 1 {
+		return f.Gray()
+	}
+	return f.RGBA()
+}
+
+// channel returns the samples of channel c as a w by h row-major slice.
+func (f *Float64Image) channel(c int) []float64 {
+	w := f.Rect.Dx()
+	values := make([]float64, w*f.Rect.Dy())
+	for y := 0; y < f.Rect.Dy(); y++ {
+		row := f.row(f.Rect.Min.Y + y)
+		for x := 0; x < w; x++ {
+			values[y*w+x] = row[x*f.Channels+c]
+		}
+	}
+	return values
+}
+
+// setChannel replaces the samples of channel c with a w by h row-major slice.
+func (f *Float64Image) setChannel(c int, values []float64) {
+	w := f.Rect.Dx()
+	for y := 0; y < f.Rect.Dy(); y++ {
+		row := f.row(f.Rect.Min.Y + y)
+		for x := 0; x < w; x++ {
+			row[x*f.Channels+c] = values[y*w+x]
+		}
+	}
+}
+
+// boxMean returns the mean of the w by h values over the square window of
+// the given radius around each pixel, clipped to the image, computed with a
+// summed-area table in constant time per pixel.
+func boxMean(values []float64, w, h, r int) []float64 {
+	table := make([]float64, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		rs := 0.
+		for x := 0; x < w; x++ {
+			rs += values[y*w+x]
+			table[(y+1)*(w+1)+x+1] = table[y*(w+1)+x+1] + rs
+		}
+	}
+	out := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		y0, y1 := max(y-r, 0), min(y+r+1, h)
+		for x := 0; x < w; x++ {
+			x0, x1 := max(x-r, 0), min(x+r+1, w)
+			sum := table[y1*(w+1)+x1] - table[y0*(w+1)+x1] - table[y1*(w+1)+x0] + table[y0*(w+1)+x0]
+			out[y*w+x] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+	return out
+}
+
+// Median replaces each pixel by the median of the square window of the given
+// radius around it, extending the image at its borders. Gray images give a
+// Gray image and other images an RGBA image filtered on each channel. The
+// running histograms of
+// S. Perreault and P. Hébert, Median filtering in constant time,
+// IEEE Transactions on Image Processing, 16 (2007), pp. 2389–2394.
+// https://doi.org/10.1109/TIP.2007.902329
+//
+// make its cost independent of the radius.
+func Median(img image.Image, radius int) image.Image {
+	f := ImageToFloat64(img)
+	if f == nil {
+		return nil
+	}
+	if radius < 1 {
+		return f.standard()
+	}
+	w, h := f.Rect.Dx(), f.Rect.Dy()
+	half := (2*radius+1)*(2*radius+1)/2 + 1
+	for c := 0; c < f.Channels; c++ {
+		values := f.channel(c)
+		levels := make([]uint8, len(values))
+		for i, v := range values {
+			levels[i] = uint8(clamp(math.Floor(v+0.5), 0, 255))
+		}
+		at := func(x, y int) uint8 {
+			return levels[borderIndex(y, h, BorderReplicate)*w+borderIndex(x, w, BorderReplicate)]
+		}
+		//Column histograms of the window rows, one per image column
+		columns := make([][256]int, w)
+		for x := range columns {
+			for y := -radius; y <= radius; y++ {
+				columns[x][at(x, y)]++
+			}
+		}
+		for y := 0; y < h; y++ {
+			if y > 0 {
+				for x := range columns {
+					columns[x][at(x, y-radius-1)]--
+					columns[x][at(x, y+radius)]++
+				}
+			}
+			var kernel [256]int
+			for i := -radius; i <= radius; i++ {
+				column := &columns[borderIndex(i, w, BorderReplicate)]
+				for v, n := range column {
+					kernel[v] += n
+				}
+			}
+			for x := 0; x < w; x++ {
+				if x > 0 {
+					out := &columns[borderIndex(x-radius-1, w, BorderReplicate)]
+					in := &columns[borderIndex(x+radius, w, BorderReplicate)]
+					for v := range kernel {
+						kernel[v] += in[v] - out[v]
+					}
+				}
+				sum, v := 0, 0
+				for ; v < 255; v++ {
+					if sum += kernel[v]; sum >= half {
+						break
+					}
+				}
+				values[y*w+x] = float64(v)
+			}
+		}
+		f.setChannel(c, values)
+	}
+	return f.standard()
+}
+
+// Bilateral smooths the image while preserving its edges by weighting the
+// neighbors of each pixel by a gaussian of standard deviation sigmaSpace of
+// their distance and a gaussian of standard deviation sigmaRange of their
+// color difference, in the 0..255 range of the samples, as described in
+// C. Tomasi and R. Manduchi, Bilateral filtering for gray and color images,
+// Sixth International Conference on Computer Vision (1998), pp. 839–846.
+// https://doi.org/10.1109/ICCV.1998.710815
+//
+// Gray images give a Gray image and other images an RGBA image.
+func Bilateral(img image.Image, sigmaSpace, sigmaRange float64) image.Image {
+	f := ImageToFloat64(img)
+	if f == nil || sigmaSpace <= 0 || sigmaRange <= 0 {
+		return nil
+	}
+	w, h, n := f.Rect.Dx(), f.Rect.Dy(), f.Channels
+	//The color difference ignores alpha
+	colors := min(n, 3)
+	r := int(math.Ceil(2 * sigmaSpace))
+	spatial := make([]float64, (2*r+1)*(2*r+1))
+	for j := -r; j <= r; j++ {
+		for i := -r; i <= r; i++ {
+			spatial[(j+r)*(2*r+1)+i+r] = math.Exp(-float64(i*i+j*j) / (2 * sigmaSpace * sigmaSpace))
+		}
+	}
+	out := NewFloat64Image(f.Rect, n)
+	wg := sync.WaitGroup{}
+	wg.Add(h)
+	for y := 0; y < h; y++ {
+		go func(y int) {
+			defer wg.Done()
+			dst := out.Pix[y*out.Stride:]
+			sum := make([]float64, n)
+			for x := 0; x < w; x++ {
+				center := f.Pix[f.PixOffset(f.Rect.Min.X+x, f.Rect.Min.Y+y):]
+				for k := range sum {
+					sum[k] = 0
+				}
+				total := 0.
+				for j := -r; j <= r; j++ {
+					yy := borderIndex(y+j, h, BorderReplicate)
+					for i := -r; i <= r; i++ {
+						xx := borderIndex(x+i, w, BorderReplicate)
+						p := f.Pix[f.PixOffset(f.Rect.Min.X+xx, f.Rect.Min.Y+yy):]
+						d := 0.
+						for k := 0; k < colors; k++ {
+							d += (p[k] - center[k]) * (p[k] - center[k])
+						}
+						weight := spatial[(j+r)*(2*r+1)+i+r] * math.Exp(-d/(2*sigmaRange*sigmaRange))
+						for k := range sum {
+							sum[k] += weight * p[k]
+						}
+						total += weight
+					}
+				}
+				for k := range sum {
+					dst[x*n+k] = sum[k] / total
+				}
+			}
+		}(y)
+	}
+	wg.Wait()
+	return out.standard()
+}
+
+// GuidedFilter smooths the image with the edges of a grayscale guide, as the
+// local linear model of
+// K. He, J. Sun and X. Tang, Guided image filtering,
+// IEEE Transactions on Pattern Analysis and Machine Intelligence, 35 (2013), pp. 1397–1409.
+// https://doi.org/10.1109/TPAMI.2012.213
+//
+// over square windows of the given radius. The regularization eps is given
+// for intensities in 0..1, so 0.01 smooths edges of contrast below about 25
+// levels. Each channel is its own guide when guide is nil. The guide must
+// have the size of the image. Gray images give a Gray image and other images
+// an RGBA image.
+func GuidedFilter(img image.Image, guide *image.Gray, radius int, eps float64) image.Image {
+	f := ImageToFloat64(img)
+	if f == nil {
+		return nil
+	}
+	w, h := f.Rect.Dx(), f.Rect.Dy()
+	var g []float64
+	if guide != nil {
+		if guide.Bounds().Dx() != w || guide.Bounds().Dy() != h {
+			return nil
+		}
+		g = grayPlane(guide).values
+	}
+	eps *= 255 * 255
+	for c := 0; c < f.Channels; c++ {
+		p := f.channel(c)
+		guide := g
+		if guide == nil {
+			guide = p
+		}
+		gp, gg := make([]float64, w*h), make([]float64, w*h)
+		for i := range p {
+			gp[i] = guide[i] * p[i]
+			gg[i] = guide[i] * guide[i]
+		}
+		meanG, meanP := boxMean(guide, w, h, radius), boxMean(p, w, h, radius)
+		corrGP, corrGG := boxMean(gp, w, h, radius), boxMean(gg, w, h, radius)
+		a, b := make([]float64, w*h), make([]float64, w*h)
+		for i := range a {
+			variance := corrGG[i] - meanG[i]*meanG[i]
+			a[i] = (corrGP[i] - meanG[i]*meanP[i]) / (variance + eps)
+			b[i] = meanP[i] - a[i]*meanG[i]
+		}
+		meanA, meanB := boxMean(a, w, h, radius), boxMean(b, w, h, radius)
+		for i := range p {
+			p[i] = meanA[i]*guide[i] + meanB[i]
+		}
+		f.setChannel(c, p)
+	}
+	return f.standard()
+}
+
+// Conductance is the edge-stopping function of AnisotropicDiffusion.
+type Conductance int
+
+const (
+	// ConductanceExponential is exp(-(|∇I|/κ)²), which favours high
+	// contrast edges over low contrast ones.
+	ConductanceExponential Conductance = iota
+	// ConductanceQuadratic is 1/(1 + (|∇I|/κ)²), which favours wide regions
+	// over smaller ones.
+	ConductanceQuadratic
+)
+
+// AnisotropicDiffusion smooths the image by diffusion that stops at edges,
+// as described in
+// P. Perona and J. Malik, Scale-space and edge detection using anisotropic diffusion,
+// IEEE Transactions on Pattern Analysis and Machine Intelligence, 12 (1990), pp. 629–639.
+// https://doi.org/10.1109/34.56205
+//
+// Differences to the four neighbors of each pixel diffuse with the given
+// conductance of their magnitude relative to kappa, in the 0..255 range, and
+// the integration constant lambda, which is limited to 0.25 for stability.
+// The color channels of color images share the conductance of their joint
+// difference. Gray images give a Gray image and other images an RGBA image.
+func AnisotropicDiffusion(img image.Image, iterations int, kappa, lambda float64, conductance Conductance) image.Image {
+	f := ImageToFloat64(img)
+	if f == nil || kappa <= 0 {
+		return nil
+	}
+	lambda = clamp(lambda, 0, 0.25)
+	w, h, n := f.Rect.Dx(), f.Rect.Dy(), f.Channels
+	colors := min(n, 3)
+	g := func(d float64) float64 {
+		d /= kappa * kappa
+		if conductance == ConductanceQuadratic {
+			return 1 / (1 + d)
+		}
+		return math.Exp(-d)
+	}
+	//ImageToFloat64 returns contiguous samples
+	cur := f.Pix
+	next := make([]float64, len(cur))
+	neighbors := [4]image.Point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	for it := 0; it < iterations; it++ {
+		wg := sync.WaitGroup{}
+		wg.Add(h)
+		for y := 0; y < h; y++ {
+			go func(y int) {
+				defer wg.Done()
+				for x := 0; x < w; x++ {
+					i := (y*w + x) * n
+					copy(next[i:i+n], cur[i:i+n])
+					for _, d := range neighbors {
+						xx, yy := x+d.X, y+d.Y
+						//The borders are insulated
+						if xx < 0 || xx >= w || yy < 0 || yy >= h {
+							continue
+						}
+						j := (yy*w + xx) * n
+						mag := 0.
+						for k := 0; k < colors; k++ {
+							mag += (cur[j+k] - cur[i+k]) * (cur[j+k] - cur[i+k])
+						}
+						c := lambda * g(mag)
+						for k := 0; k < n; k++ {
+							next[i+k] += c * (cur[j+k] - cur[i+k])
+						}
+					}
+				}
+			}(y)
+		}
+		wg.Wait()
+		cur, next = next, cur
+	}
+	out := NewFloat64Image(f.Rect, n)
+	copy(out.Pix, cur)
+	return out.standard()
+}
+
+// NLMeans denoises the image with non-local means, as described in
+// A. Buades, B. Coll and J.-M. Morel, A non-local algorithm for image denoising,
+// IEEE Computer Society Conference on Computer Vision and Pattern Recognition, 2 (2005), pp. 60–65.
+// https://doi.org/10.1109/CVPR.2005.38
+//
+// Each pixel becomes the mean of the pixels within searchRadius, weighted by
+// exp(-d²/h²), where d² is the mean squared color difference of the patches
+// of patchRadius around both, in the 0..255 range. The patch distances of
+// every pixel for one displacement are box filtered at once, so the cost
+// does not depend on the patch size. Gray images give a Gray image and other
+// images an RGBA image.
+func NLMeans(img image.Image, h float64, patchRadius, searchRadius int) image.Image {
+	f := ImageToFloat64(img)
+	if f == nil || h <= 0 {
+		return nil
+	}
+	w, ht, n := f.Rect.Dx(), f.Rect.Dy(), f.Channels
+	colors := min(n, 3)
+	//ImageToFloat64 returns contiguous samples
+	at := func(x, y int) []float64 {
+		i := (borderIndex(y, ht, BorderReplicate)*w + borderIndex(x, w, BorderReplicate)) * n
+		return f.Pix[i : i+n]
+	}
+	sum := make([]float64, w*ht*n)
+	total := make([]float64, w*ht)
+	diff := make([]float64, w*ht)
+	for dy := -searchRadius; dy <= searchRadius; dy++ {
+		for dx := -searchRadius; dx <= searchRadius; dx++ {
+			for y := 0; y < ht; y++ {
+				for x := 0; x < w; x++ {
+					p, q := at(x, y), at(x+dx, y+dy)
+					d := 0.
+					for k := 0; k < colors; k++ {
+						d += (p[k] - q[k]) * (p[k] - q[k])
+					}
+					diff[y*w+x] = d / float64(colors)
+				}
+			}
+			dist := boxMean(diff, w, ht, patchRadius)
+			for y := 0; y < ht; y++ {
+				for x := 0; x < w; x++ {
+					i := y*w + x
+					weight := math.Exp(-dist[i] / (h * h))
+					q := at(x+dx, y+dy)
+					for k := 0; k < n; k++ {
+						sum[i*n+k] += weight * q[k]
+					}
+					total[i] += weight
+				}
+			}
+		}
+	}
+	out := NewFloat64Image(f.Rect, n)
+	for i, t := range total {
+		for k := 0; k < n; k++ {
+			out.Pix[i*n+k] = sum[i*n+k] / t
+		}
+	}
+	return out.standard()
+}
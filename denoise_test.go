@@ -0,0 +1,94 @@
+package vision
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// noisyStep returns a w by h image whose left half is dark and right half
+// bright, with gaussian noise of the given standard deviation, and the clean
+// image.
+func noisyStep(w, h int, σ float64) (noisy, clean *image.Gray) {
+	rng := rand.New(rand.NewSource(1))
+	noisy, clean = image.NewGray(image.Rect(0, 0, w, h)), image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := 60.
+			if x >= w/2 {
+				v = 190
+			}
+			clean.Pix[y*w+x] = uint8(v)
+			noisy.Pix[y*w+x] = uint8(clamp(math.Floor(v+σ*rng.NormFloat64()+0.5), 0, 255))
+		}
+	}
+	return
+}
+
+// rmse returns the root mean squared difference of two grayscale images of
+// the same size.
+func rmse(a, b *image.Gray) float64 {
+	va, vb := grayValues(a), grayValues(b)
+	sum := 0.
+	for i := range va {
+		d := float64(va[i]) - float64(vb[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum / float64(len(va)))
+}
+
+func TestDenoise(t *testing.T) {
+	noisy, clean := noisyStep(48, 32, 15)
+	before := rmse(noisy, clean)
+	cases := []struct {
+		name string
+		out  image.Image
+	}{
+		{"Median", Median(noisy, 2)},
+		{"Bilateral", Bilateral(noisy, 2, 40)},
+		{"GuidedFilter", GuidedFilter(noisy, nil, 3, 0.01)},
+		{"AnisotropicDiffusion", AnisotropicDiffusion(noisy, 20, 30, 0.25, ConductanceExponential)},
+		{"NLMeans", NLMeans(noisy, 20, 2, 5)},
+	}
+	for _, c := range cases {
+		gray, ok := c.out.(*image.Gray)
+		if !ok {
+			t.Errorf("%s: expected a Gray image, got %T", c.name, c.out)
+			continue
+		}
+		if after := rmse(gray, clean); after > before/2 {
+			t.Errorf("%s: error %.2f not halved from %.2f", c.name, after, before)
+		}
+		//The step must stay sharp
+		if l, r := gray.GrayAt(21, 16).Y, gray.GrayAt(26, 16).Y; l > 100 || r < 150 {
+			t.Errorf("%s: step blurred to %d and %d", c.name, l, r)
+		}
+	}
+}
+
+func TestMedian(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 5, 5))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i)
+	}
+	gray.Pix[12] = 255
+	out := Median(gray, 1).(*image.Gray)
+	if v := out.GrayAt(2, 2).Y; v != 13 {
+		t.Errorf("expected the median 13 of the neighbors, got %d", v)
+	}
+	if v := out.GrayAt(0, 0).Y; v != 1 {
+		t.Errorf("expected the median 1 at the replicated corner, got %d", v)
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, 3, 3))
+	for i := 0; i < 9; i++ {
+		rgba.SetRGBA(i%3, i/3, color.RGBA{uint8(10 * i), 100, uint8(200 - 10*i), 255})
+	}
+	rgba.SetRGBA(1, 1, color.RGBA{255, 0, 255, 255})
+	c := Median(rgba, 1).(*image.RGBA).RGBAAt(1, 1)
+	if c != (color.RGBA{50, 100, 170, 255}) {
+		t.Errorf("expected the channel medians, got %v", c)
+	}
+}
@@ -13,25 +13,7 @@ func EdgeDrawing(gray *image.Gray) *image.Gray {
 
 	//Extend image signal at borders
 	signal := func(x, y int) float64 {
-		if x >= 0 && x <= nb-1 && y >= 0 && y <= mb-1 {
-			return float64(gray.Pix[y*nb+x]) //Inside
-		} else if x < 0 && y >= 0 && y <= mb-1 {
-			return float64(gray.Pix[y*nb]) //Left
-		} else if y < 0 && x >= 0 && x <= nb-1 {
-			return float64(gray.Pix[x]) //Top
-		} else if x > nb-1 && y >= 0 && y <= mb-1 {
-			return float64(gray.Pix[y*nb+(nb-1)]) //Right
-		} else if y > mb-1 && x >= 0 && x <= nb-1 {
-			return float64(gray.Pix[(mb-1)*nb+x]) //Bottom
-		} else if x < 0 && y > mb-1 {
-			return float64(gray.Pix[(mb-1)*nb]) //Bottom left corner
-		} else if x < 0 && y < 0 {
-			return float64(gray.Pix[0]) //Top left corner
-		} else if x > nb-1 && y < 0 {
-			return float64(gray.Pix[nb-1]) //Top right corner
-		} else {
-			return float64(gray.Pix[(mb-1)*nb+(nb-1)]) //Bottom right corner
-		}
+		return float64(gray.Pix[borderIndex(y, mb, BorderReplicate)*nb+borderIndex(x, nb, BorderReplicate)])
 	}
 	sobel := func(x, y int) (sobelX float64, sobelY float64) {
 		m, n := -y+1, -x+1
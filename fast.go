@@ -0,0 +1,157 @@
+package vision
+
+import "image"
+
+// fastCircle holds the offsets of the 16 pixels of the Bresenham circle of
+// radius 3 used by the segment test.
+var fastCircle = [16]image.Point{
+	{0, -3}, {1, -3}, {2, -2}, {3, -1}, {3, 0}, {3, 1}, {2, 2}, {1, 3},
+	{0, 3}, {-1, 3}, {-2, 2}, {-3, 1}, {-3, 0}, {-3, -1}, {-2, -2}, {-1, -3},
+}
+
+// FAST detects corners with the segment test, as described in
+// E. Rosten and T. Drummond, Machine learning for high-speed corner detection,
+// European Conference on Computer Vision (2006), pp. 430–443.
+// https://doi.org/10.1007/11744023_34
+//
+// A pixel is a corner when at least arc contiguous pixels of the circle of
+// radius 3 around it are all brighter than it plus the threshold or all
+// darker than it minus the threshold. Use an arc of 9 for FAST-9 and 12 for
+// FAST-12; other values are clamped to [9, 16]. The response of each corner
+// is the sum of the absolute differences exceeding the threshold over the
+// brighter or darker pixels of the circle, whichever is larger. If nonmax is
+// true, only the corners whose response is maximal over their 3x3
+// neighborhood are kept.
+func FAST(gray *image.Gray, threshold uint8, arc int, nonmax bool) (keypoints []Keypoint) {
+	b := gray.Bounds()
+	w, h := b.Dx(), b.Dy()
+	scores := fastScores(gray, int(threshold), arc, 3)
+	var corners []image.Point
+	if nonmax {
+		corners = suppressScores(scores, w, h)
+	} else {
+		for i, s := range scores {
+			if s > 0 {
+				corners = append(corners, image.Pt(i%w, i/w))
+			}
+		}
+	}
+	keypoints = make([]Keypoint, len(corners))
+	for i, c := range corners {
+		keypoints[i] = Keypoint{
+			X:        float64(c.X + b.Min.X),
+			Y:        float64(c.Y + b.Min.Y),
+			Size:     7,
+			Response: scores[c.Y*w+c.X],
+		}
+	}
+	return
+}
+
+// fastScores returns the corner response of every pixel of the image, in
+// row-major order from its top left corner, which is zero for pixels failing
+// the segment test and for pixels closer than border to the edges.
+func fastScores(gray *image.Gray, t, arc, border int) []float64 {
+	arc = min(max(arc, 9), 16)
+	border = max(border, 3)
+	w, h := gray.Bounds().Dx(), gray.Bounds().Dy()
+	scores := make([]float64, w*h)
+	var circle [16]int
+	for i, o := range fastCircle {
+		circle[i] = o.Y*gray.Stride + o.X
+	}
+	rowTiles(h, func(y0, y1 int) {
+		for y := max(y0, border); y < min(y1, h-border); y++ {
+			for x := border; x < w-border; x++ {
+				scores[y*w+x] = segmentTest(gray.Pix, y*gray.Stride+x, &circle, t, arc)
+			}
+		}
+	})
+	return scores
+}
+
+// segmentTest returns the corner response of the pixel at index i of pix, or
+// zero if less than arc contiguous pixels of the circle around it are all
+// brighter than the center plus t or all darker than the center minus t.
+// The circle holds the offsets of its pixels from the center, and must lie
+// inside pix.
+func segmentTest(pix []uint8, i int, circle *[16]int, t, arc int) float64 {
+	c := int(pix[i])
+
+	//Quick rejection: a contiguous arc of n pixels covers at least n/4 of
+	//the four compass points, all on the same side of the center
+	brighter, darker := 0, 0
+	for k := 0; k < 16; k += 4 {
+		v := int(pix[i+circle[k]])
+		if v > c+t {
+			brighter++
+		} else if v < c-t {
+			darker++
+		}
+	}
+	if brighter < arc/4 && darker < arc/4 {
+		return 0
+	}
+
+	var values [16]int
+	for k, o := range circle {
+		values[k] = int(pix[i+o])
+	}
+	brighter, darker = 0, 0
+	corner := false
+	for k := 0; k < 16+arc-1 && !corner; k++ {
+		v := values[k%16]
+		switch {
+		case v > c+t:
+			brighter++
+			darker = 0
+		case v < c-t:
+			darker++
+			brighter = 0
+		default:
+			brighter, darker = 0, 0
+		}
+		corner = brighter >= arc || darker >= arc
+	}
+	if !corner {
+		return 0
+	}
+	bright, dark := 0, 0
+	for _, v := range values {
+		if v > c+t {
+			bright += v - c - t
+		} else if v < c-t {
+			dark += c - v - t
+		}
+	}
+	return float64(max(bright, dark))
+}
+
+// suppressScores returns the pixels with a positive score that is maximal
+// over their 3x3 neighborhood. Ties are resolved in favor of the first pixel
+// in row-major order.
+func suppressScores(scores []float64, w, h int) (points []image.Point) {
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			s := scores[y*w+x]
+			if s <= 0 {
+				continue
+			}
+			maximum := true
+			for j := max(y-1, 0); j <= min(y+1, h-1) && maximum; j++ {
+				for i := max(x-1, 0); i <= min(x+1, w-1); i++ {
+					n := scores[j*w+i]
+					before := j < y || (j == y && i < x)
+					if n > s || (before && n == s) {
+						maximum = false
+						break
+					}
+				}
+			}
+			if maximum {
+				points = append(points, image.Pt(x, y))
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,56 @@
+package vision
+
+import (
+	"image"
+	"testing"
+)
+
+func TestFAST(t *testing.T) {
+	//A bright square on a dark background has a corner at each vertex
+	gray := image.NewGray(image.Rect(0, 0, 40, 40))
+	for y := 10; y < 30; y++ {
+		for x := 10; x < 30; x++ {
+			gray.Pix[y*40+x] = 200
+		}
+	}
+	vertices := []image.Point{{10, 10}, {29, 10}, {10, 29}, {29, 29}}
+	keypoints := FAST(gray, 50, 9, true)
+	if len(keypoints) != 4 {
+		t.Fatalf("FAST() found %d corners: %v", len(keypoints), keypoints)
+	}
+	for _, v := range vertices {
+		found := false
+		for _, kp := range keypoints {
+			if dx, dy := kp.X-float64(v.X), kp.Y-float64(v.Y); dx*dx+dy*dy <= 2 {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("FAST() missed the vertex %v: %v", v, keypoints)
+		}
+	}
+	//Without suppression more pixels around the vertices pass the test
+	if n := len(FAST(gray, 50, 9, false)); n <= 4 {
+		t.Errorf("FAST() without suppression found %d corners", n)
+	}
+	//Nothing passes a threshold above the contrast of the square
+	if n := len(FAST(gray, 250, 9, true)); n != 0 {
+		t.Errorf("FAST() with a high threshold found %d corners", n)
+	}
+	//A subimage keeps the coordinates of its parent
+	sub := gray.SubImage(image.Rect(5, 5, 35, 35)).(*image.Gray)
+	if n := len(FAST(sub, 50, 9, true)); n != 4 {
+		t.Errorf("FAST() found %d corners in the subimage", n)
+	}
+	for _, kp := range FAST(sub, 50, 9, true) {
+		if gray.Pix[int(kp.Y)*40+int(kp.X)] != 200 {
+			t.Errorf("FAST() found %v off the square in the subimage", kp)
+		}
+	}
+	//Right angles are too wide for FAST-12, which only finds the isolated dot
+	gray.Pix[5*40+34] = 255
+	keypoints = FAST(gray, 50, 12, true)
+	if len(keypoints) != 1 || keypoints[0].X != 34 || keypoints[0].Y != 5 {
+		t.Errorf("FAST-12 found %v, want the dot at (34, 5)", keypoints)
+	}
+}
@@ -0,0 +1,141 @@
+package vision
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// nextPow2 returns the smallest power of two not less than n.
+func nextPow2(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// fft computes in place the discrete Fourier transform of a, whose length
+// must be a power of two, with the iterative radix-2 Cooley-Tukey algorithm.
+// The inverse transform is not scaled.
+func fft(a []complex128, inverse bool) {
+	n := len(a)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			a[i], a[j] = a[j], a[i]
+		}
+	}
+	sign := -1.
+	if inverse {
+		sign = 1
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Rect(1, sign*2*math.Pi/float64(size))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				u := a[start+k]
+				v := a[start+k+size/2] * w
+				a[start+k] = u + v
+				a[start+k+size/2] = u - v
+				w *= step
+			}
+		}
+	}
+}
+
+// dftPlan computes discrete Fourier transforms of one length. Powers of two
+// use fft directly and other lengths the chirp z-transform of
+// L. Bluestein, A linear filtering approach to the computation of discrete Fourier transform,
+// IEEE Transactions on Audio and Electroacoustics, 18 (1970), pp. 451–455.
+// https://doi.org/10.1109/TAU.1970.1162132
+//
+// which writes the transform as a convolution computed with power of two
+// transforms.
+type dftPlan struct {
+	n, m   int
+	chirp  []complex128
+	kernel []complex128
+}
+
+func newDFTPlan(n int) *dftPlan {
+	p := &dftPlan{n: n}
+	if n == nextPow2(n) {
+		return p
+	}
+	p.m = nextPow2(2*n - 1)
+	p.chirp = make([]complex128, n)
+	p.kernel = make([]complex128, p.m)
+	for k := range p.chirp {
+		//k² modulo 2n keeps the angle accurate for long transforms
+		p.chirp[k] = cmplx.Rect(1, -math.Pi*float64(k*k%(2*n))/float64(n))
+		p.kernel[k] = cmplx.Conj(p.chirp[k])
+		if k > 0 {
+			p.kernel[p.m-k] = p.kernel[k]
+		}
+	}
+	fft(p.kernel, false)
+	return p
+}
+
+// transform computes in place the discrete Fourier transform of a, whose
+// length must be the length of the plan. The inverse transform is not
+// scaled.
+func (p *dftPlan) transform(a []complex128, inverse bool) {
+	if p.chirp == nil {
+		fft(a, inverse)
+		return
+	}
+	//The inverse transform is the conjugate of the transform of the
+	//conjugate
+	if inverse {
+		for i := range a {
+			a[i] = cmplx.Conj(a[i])
+		}
+	}
+	buf := make([]complex128, p.m)
+	for k, v := range a {
+		buf[k] = v * p.chirp[k]
+	}
+	fft(buf, false)
+	for i := range buf {
+		buf[i] *= p.kernel[i]
+	}
+	fft(buf, true)
+	scale := complex(1/float64(p.m), 0)
+	for k := range a {
+		a[k] = buf[k] * scale * p.chirp[k]
+		if inverse {
+			a[k] = cmplx.Conj(a[k])
+		}
+	}
+}
+
+// fft2 computes in place the 2D discrete Fourier transform of the w-by-h
+// row-major data, of any size. The inverse transform is scaled by 1/(w*h).
+func fft2(data []complex128, w, h int, inverse bool) {
+	rows, cols := newDFTPlan(w), newDFTPlan(h)
+	for y := 0; y < h; y++ {
+		rows.transform(data[y*w:(y+1)*w], inverse)
+	}
+	col := make([]complex128, h)
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			col[y] = data[y*w+x]
+		}
+		cols.transform(col, inverse)
+		for y := 0; y < h; y++ {
+			data[y*w+x] = col[y]
+		}
+	}
+	if inverse {
+		s := complex(1/float64(w*h), 0)
+		for i := range data {
+			data[i] *= s
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package vision
 
 import (
+	"image"
+	"math"
 	"sort"
+	"sync"
 
 	"github.com/joaowiciuk/matrix"
 )
@@ -125,6 +128,9 @@ func (R *Region) Swap(i, j int) {
 } */
 
 // Find finds O in I
+//
+// Deprecated: Find allocates a submatrix for every position of I. Use
+// FindTemplate, which is accelerated with integral images and the FFT.
 func Find(O, I *matrix.Matrix, dist float64) (R *Region) {
 	xc, yc := O.Center()
 	mo, no := O.Size()
@@ -161,3 +167,602 @@ func Find(O, I *matrix.Matrix, dist float64) (R *Region) {
 	sort.Sort(R)
 	return
 }
+
+// MatchMethod is a template matching score.
+type MatchMethod int
+
+const (
+	// MatchSSD is the sum of squared differences. Lower is better.
+	MatchSSD MatchMethod = iota
+
+	// MatchSSDNormed is the sum of squared differences divided by the
+	// square root of the product of the window and template energies.
+	// Lower is better.
+	MatchSSDNormed
+
+	// MatchCCorr is the cross-correlation. Higher is better.
+	MatchCCorr
+
+	// MatchZNCC is the zero-mean normalized cross-correlation, which lies
+	// in [-1, 1] and is invariant to affine changes of brightness. Higher
+	// is better.
+	MatchZNCC
+)
+
+// better reports whether the score a is better than b under the method.
+func (m MatchMethod) better(a, b float64) bool {
+	if m == MatchSSD || m == MatchSSDNormed {
+		return a < b
+	}
+	return a > b
+}
+
+// TemplateMatch is a location of a template found by FindTemplate.
+type TemplateMatch struct {
+	Rect  image.Rectangle
+	Score float64
+}
+
+// fftMinArea is the template area from which the cross-correlation is
+// computed in the frequency domain.
+const fftMinArea = 64
+
+// MatchTemplate slides the template over the image and returns the score map
+// of the given method. The entry (r, c) of the map is the score of the
+// template placed with its top left corner at the image point (c, r) relative
+// to the image origin, so the map has (H-h+1) rows and (W-w+1) columns. It
+// returns nil if the template is larger than the image.
+//
+// The window sums are taken from an IntegralImage and the cross-correlation
+// term is computed with the FFT for templates of more than 64 pixels.
+func MatchTemplate(img, tmpl *image.Gray, method MatchMethod) *matrix.Matrix {
+	b, tb := img.Bounds(), tmpl.Bounds()
+	W, H := b.Dx(), b.Dy()
+	w, h := tb.Dx(), tb.Dy()
+	if w == 0 || h == 0 || w > W || h > H {
+		return nil
+	}
+	rows, cols := H-h+1, W-w+1
+	values, tvalues := grayValues(img), grayValues(tmpl)
+	var tsum, tsqsum float64
+	for _, v := range tvalues {
+		tsum += float64(v)
+		tsqsum += float64(v) * float64(v)
+	}
+	n := float64(w * h)
+
+	ccorr := crossCorrelation(values, W, H, tvalues, w, h)
+	ii := NewIntegralImage(img)
+	scores := matrix.New(rows, cols)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			win := image.Rect(c, r, c+w, r+h).Add(b.Min)
+			cc := ccorr[r*cols+c]
+			var score float64
+			switch method {
+			case MatchSSD:
+				score = math.Max(ii.SqSum(win)-2*cc+tsqsum, 0)
+			case MatchSSDNormed:
+				if d := math.Sqrt(ii.SqSum(win) * tsqsum); d > 0 {
+					score = math.Max(ii.SqSum(win)-2*cc+tsqsum, 0) / d
+				}
+			case MatchCCorr:
+				score = cc
+			case MatchZNCC:
+				sum := ii.Sum(win)
+				d := (ii.SqSum(win) - sum*sum/n) * (tsqsum - tsum*tsum/n)
+				if d > 0 {
+					score = clamp((cc-sum*tsum/n)/math.Sqrt(d), -1, 1)
+				}
+			}
+			(*scores)[r][c] = score
+		}
+	}
+	return scores
+}
+
+// crossCorrelation returns the valid cross-correlation of the W-by-H values
+// with the w-by-h template as a (W-w+1)-by-(H-h+1) row-major slice.
+func crossCorrelation(values []uint8, W, H int, tvalues []uint8, w, h int) []float64 {
+	rows, cols := H-h+1, W-w+1
+	out := make([]float64, rows*cols)
+	if w*h < fftMinArea {
+		for r := 0; r < rows; r++ {
+			for c := 0; c < cols; c++ {
+				sum := 0.
+				for j := 0; j < h; j++ {
+					row := values[(r+j)*W+c : (r+j)*W+c+w]
+					trow := tvalues[j*w : (j+1)*w]
+					for i, v := range trow {
+						sum += float64(v) * float64(row[i])
+					}
+				}
+				out[r*cols+c] = sum
+			}
+		}
+		return out
+	}
+
+	//Circular correlation does not wrap over the valid region as long as the
+	//transforms are at least as large as the image
+	fw, fh := nextPow2(W), nextPow2(H)
+	a := make([]complex128, fw*fh)
+	t := make([]complex128, fw*fh)
+	for y := 0; y < H; y++ {
+		for x := 0; x < W; x++ {
+			a[y*fw+x] = complex(float64(values[y*W+x]), 0)
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			t[y*fw+x] = complex(float64(tvalues[y*w+x]), 0)
+		}
+	}
+	fft2(a, fw, fh, false)
+	fft2(t, fw, fh, false)
+	for i := range a {
+		a[i] *= complex(real(t[i]), -imag(t[i]))
+	}
+	fft2(a, fw, fh, true)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			out[r*cols+c] = real(a[r*fw+c])
+		}
+	}
+	return out
+}
+
+// FindTemplate computes the score map of the template over the image with
+// MatchTemplate and returns it along with the matches whose score is at least
+// as good as the threshold, best first. Matches are local optima of the map
+// and any candidate overlapping a better match by more than half of the
+// template area is suppressed. If maxMatches is positive, at most that many
+// matches are returned.
+func FindTemplate(img, tmpl *image.Gray, method MatchMethod, threshold float64, maxMatches int) (scores *matrix.Matrix, matches []TemplateMatch) {
+	scores = MatchTemplate(img, tmpl, method)
+	if scores == nil {
+		return nil, nil
+	}
+	rows, cols := scores.Size()
+	w, h := tmpl.Bounds().Dx(), tmpl.Bounds().Dy()
+	origin := img.Bounds().Min
+
+	//Local optima passing the threshold
+	candidates := make([]TemplateMatch, 0)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			s := (*scores)[r][c]
+			if method.better(threshold, s) {
+				continue
+			}
+			optimum := true
+			for j := max(r-1, 0); j <= min(r+1, rows-1) && optimum; j++ {
+				for i := max(c-1, 0); i <= min(c+1, cols-1); i++ {
+					if method.better((*scores)[j][i], s) {
+						optimum = false
+						break
+					}
+				}
+			}
+			if optimum {
+				candidates = append(candidates, TemplateMatch{
+					Rect:  image.Rect(c, r, c+w, r+h).Add(origin),
+					Score: s,
+				})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return method.better(candidates[i].Score, candidates[j].Score)
+	})
+
+	//Non-maximum suppression
+	area := w * h
+	for _, c := range candidates {
+		if maxMatches > 0 && len(matches) == maxMatches {
+			break
+		}
+		suppressed := false
+		for _, m := range matches {
+			o := m.Rect.Intersect(c.Rect).Size()
+			if 2*o.X*o.Y > area {
+				suppressed = true
+				break
+			}
+		}
+		if !suppressed {
+			matches = append(matches, c)
+		}
+	}
+	return
+}
+
+// SearchOptions configures FindTemplateMultiScale. Angles are in degrees.
+// A zero step searches the range in steps of 1 degree and 0.05 of scale. A
+// zero scale range searches only the original size.
+type SearchOptions struct {
+	MinScale, MaxScale, ScaleStep float64
+	MinAngle, MaxAngle, AngleStep float64
+
+	// Levels is the number of pyramid levels above the original
+	// resolution. If it is zero, it is chosen so that the template is at
+	// least 8 pixels wide at the coarsest level.
+	Levels int
+
+	// Threshold is the minimum zero-mean normalized cross-correlation of
+	// a match.
+	Threshold float64
+
+	// MaxMatches limits the number of matches if it is positive.
+	MaxMatches int
+
+	// CoarseMargin lowers Threshold for the candidates of the coarsest
+	// level, where the correlation drops since the detail of the template
+	// is lost. If it is zero, 0.25 is used, and a negative margin keeps
+	// Threshold.
+	CoarseMargin float64
+
+	// Candidates is the number of the best coarse candidates refined to
+	// the original resolution. If it is zero, it is the larger of 16 and
+	// four times MaxMatches.
+	Candidates int
+}
+
+// ScaledMatch is a location of a template found by FindTemplateMultiScale.
+// X and Y are the coordinates of the template center in the image, Angle is
+// the counter-clockwise rotation of the template in degrees and Score is the
+// zero-mean normalized cross-correlation.
+type ScaledMatch struct {
+	X, Y  float64
+	Scale float64
+	Angle float64
+	Score float64
+}
+
+// searchLevel is one level of the coarse-to-fine template search.
+type searchLevel struct {
+	img        []float64
+	w, h       int
+	tmpl       []float64
+	tw, th     int
+	tmean, tsd float64
+}
+
+// newSearchLevel returns a search level, precomputing the template mean and
+// deviation used by the correlation score.
+func newSearchLevel(img []float64, w, h int, tmpl []float64, tw, th int) *searchLevel {
+	lv := &searchLevel{img: img, w: w, h: h, tmpl: tmpl, tw: tw, th: th}
+	for _, v := range tmpl {
+		lv.tmean += v
+	}
+	lv.tmean /= float64(len(tmpl))
+	for _, v := range tmpl {
+		lv.tsd += (v - lv.tmean) * (v - lv.tmean)
+	}
+	lv.tsd = math.Sqrt(lv.tsd)
+	return lv
+}
+
+// score returns the zero-mean normalized cross-correlation between the
+// template, scaled and rotated by the angle in degrees, and the image with
+// the template centered at (x, y). It returns -1 if the template does not fit
+// in the image.
+func (lv *searchLevel) score(x, y, scale, angle float64) float64 {
+	cx, cy := float64(lv.tw-1)/2, float64(lv.th-1)/2
+	r := scale * math.Hypot(cx, cy)
+	if x-r < 0 || y-r < 0 || x+r > float64(lv.w-1) || y+r > float64(lv.h-1) || lv.tsd == 0 {
+		return -1
+	}
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	var cross, sum, sqsum float64
+	for j := 0; j < lv.th; j++ {
+		v := (float64(j) - cy) * scale
+		for i := 0; i < lv.tw; i++ {
+			u := (float64(i) - cx) * scale
+			s := bilinearAt(lv.img, lv.w, lv.h, x+u*cos+v*sin, y-u*sin+v*cos)
+			cross += (lv.tmpl[j*lv.tw+i] - lv.tmean) * s
+			sum += s
+			sqsum += s * s
+		}
+	}
+	d := sqsum - sum*sum/float64(lv.tw*lv.th)
+	if d <= 0 {
+		return 0
+	}
+	return cross / (lv.tsd * math.Sqrt(d))
+}
+
+// search scores every position of the level for each scale and angle with
+// the zero-mean normalized cross-correlation of MatchTemplate and returns the
+// best scale and angle of each pixel, with the exact position of the template
+// center. The image is rotated instead of the template, so the windows hold
+// only template pixels, and the template is resized to each scale.
+func (lv *searchLevel) search(scales, angles []float64) []ScaledMatch {
+	best := make([]ScaledMatch, lv.w*lv.h)
+	for i := range best {
+		best[i].Score = -1
+	}
+	if lv.tsd == 0 {
+		return best
+	}
+	ox, oy := float64(lv.w-1)/2, float64(lv.h-1)/2
+	results := make([][]ScaledMatch, len(scales)*len(angles))
+	wg := sync.WaitGroup{}
+	for i, s := range scales {
+		stw := max(int(math.Floor(float64(lv.tw)*s+0.5)), 1)
+		sth := max(int(math.Floor(float64(lv.th)*s+0.5)), 1)
+		scaled := plane{values: lv.tmpl, w: lv.tw, h: lv.th}.resample(stw, sth).gray()
+		for j, a := range angles {
+			//Proccess scales and angles concurrently
+			wg.Add(1)
+			go func(k int, s, a float64) {
+				defer wg.Done()
+				sin, cos := math.Sincos(a * math.Pi / 180)
+				rw := int(math.Ceil(math.Abs(float64(lv.w)*cos) + math.Abs(float64(lv.h)*sin)))
+				rh := int(math.Ceil(math.Abs(float64(lv.w)*sin) + math.Abs(float64(lv.h)*cos)))
+				rcx, rcy := float64(rw-1)/2, float64(rh-1)/2
+				//toImage maps a point of the rotated image to the level, in the
+				//convention of score
+				toImage := func(px, py float64) (float64, float64) {
+					u, v := px-rcx, py-rcy
+					return ox + u*cos + v*sin, oy - u*sin + v*cos
+				}
+				rotated := image.NewGray(image.Rect(0, 0, rw, rh))
+				for py := 0; py < rh; py++ {
+					for px := 0; px < rw; px++ {
+						x, y := toImage(float64(px), float64(py))
+						if x >= 0 && y >= 0 && x <= float64(lv.w-1) && y <= float64(lv.h-1) {
+							rotated.Pix[py*rw+px] = uint8(clamp(math.Floor(bilinearAt(lv.img, lv.w, lv.h, x, y)+0.5), 0, 255))
+						}
+					}
+				}
+				scores := MatchTemplate(rotated, scaled, MatchZNCC)
+				if scores == nil {
+					return
+				}
+				//Keep the positions where score fits the template, so the
+				//windows never reach outside the level
+				r := s * math.Hypot(float64(lv.tw-1)/2, float64(lv.th-1)/2)
+				tcx, tcy := float64(scaled.Rect.Dx()-1)/2, float64(scaled.Rect.Dy()-1)/2
+				for row, line := range *scores {
+					for col, v := range line {
+						x, y := toImage(float64(col)+tcx, float64(row)+tcy)
+						if x-r < 0 || y-r < 0 || x+r > float64(lv.w-1) || y+r > float64(lv.h-1) {
+							continue
+						}
+						results[k] = append(results[k], ScaledMatch{X: x, Y: y, Scale: s, Angle: a, Score: v})
+					}
+				}
+			}(i*len(angles)+j, s, a)
+		}
+	}
+	wg.Wait()
+	for _, result := range results {
+		for _, m := range result {
+			i := int(math.Floor(m.Y+0.5))*lv.w + int(math.Floor(m.X+0.5))
+			if m.Score > best[i].Score {
+				best[i] = m
+			}
+		}
+	}
+	return best
+}
+
+// bilinearAt interpolates the w-by-h values at (x, y), which must lie inside
+// the image.
+func bilinearAt(values []float64, w, h int, x, y float64) float64 {
+	x0, y0 := int(x), int(y)
+	x1, y1 := min(x0+1, w-1), min(y0+1, h-1)
+	fx, fy := x-float64(x0), y-float64(y0)
+	top := values[y0*w+x0]*(1-fx) + values[y0*w+x1]*fx
+	bottom := values[y1*w+x0]*(1-fx) + values[y1*w+x1]*fx
+	return top*(1-fy) + bottom*fy
+}
+
+// searchRange returns the values from a to b in the given step, or just a if
+// the range is empty.
+func searchRange(a, b, step float64) []float64 {
+	if b <= a || step <= 0 {
+		return []float64{a}
+	}
+	values := make([]float64, 0)
+	for v := a; v <= b+step/2; v += step {
+		values = append(values, math.Min(v, b))
+	}
+	return values
+}
+
+// FindTemplateMultiScale searches the template over a range of scales and
+// angles. The image and the template are reduced in a pyramid, an exhaustive
+// search over positions, scales and angles is done at the coarsest level with
+// MatchTemplate, rotating the image by each angle and resizing the template
+// to each scale, and the best candidates are refined level by level with the
+// correlation of the rotated and scaled template. At the original resolution
+// the position, scale and angle are interpolated with parabolic fits of the
+// score, giving sub-pixel and sub-degree accuracy.
+func FindTemplateMultiScale(img, tmpl *image.Gray, opts SearchOptions) (matches []ScaledMatch) {
+	if opts.MinScale <= 0 {
+		opts.MinScale = 1
+	}
+	if opts.MaxScale < opts.MinScale {
+		opts.MaxScale = opts.MinScale
+	}
+	if opts.MaxAngle < opts.MinAngle {
+		opts.MaxAngle = opts.MinAngle
+	}
+	if opts.ScaleStep <= 0 {
+		opts.ScaleStep = 0.05
+	}
+	if opts.AngleStep <= 0 {
+		opts.AngleStep = 1
+	}
+	if opts.CoarseMargin == 0 {
+		opts.CoarseMargin = 0.25
+	}
+	if opts.CoarseMargin < 0 {
+		opts.CoarseMargin = 0
+	}
+	if opts.Candidates <= 0 {
+		opts.Candidates = max(16, 4*opts.MaxMatches)
+	}
+	b, tb := img.Bounds(), tmpl.Bounds()
+	w, h, tw, th := b.Dx(), b.Dy(), tb.Dx(), tb.Dy()
+	if tw == 0 || th == 0 || tw > w || th > h {
+		return nil
+	}
+	levels := opts.Levels
+	if levels <= 0 {
+		for min(tw, th)>>uint(levels+1) >= 8 {
+			levels++
+		}
+	}
+
+	//Pyramids
+	imgs := gaussianPlanes(grayPlane(img), levels+1, 0.5)
+	tmpls := gaussianPlanes(grayPlane(tmpl), levels+1, 0.5)
+	levels = min(len(imgs), len(tmpls)) - 1
+	pyramid := make([]*searchLevel, levels+1)
+	for l := range pyramid {
+		i, t := imgs[l], tmpls[l]
+		pyramid[l] = newSearchLevel(i.values, i.w, i.h, t.values, t.w, t.h)
+	}
+
+	//Exhaustive search at the coarsest level, keeping the best scale and
+	//angle for each position
+	top := pyramid[levels]
+	factor := float64(int(1) << uint(levels))
+	scales := searchRange(opts.MinScale, opts.MaxScale, opts.ScaleStep*factor)
+	angles := searchRange(opts.MinAngle, opts.MaxAngle, math.Min(opts.AngleStep*factor, 90))
+	best := top.search(scales, angles)
+
+	//Local maxima as candidates, with a looser threshold
+	candidates := make([]ScaledMatch, 0)
+	for y := 0; y < top.h; y++ {
+		for x := 0; x < top.w; x++ {
+			m := best[y*top.w+x]
+			if m.Score < opts.Threshold-opts.CoarseMargin {
+				continue
+			}
+			maximum := true
+			for j := max(y-1, 0); j <= min(y+1, top.h-1); j++ {
+				for i := max(x-1, 0); i <= min(x+1, top.w-1); i++ {
+					if best[j*top.w+i].Score > m.Score {
+						maximum = false
+					}
+				}
+			}
+			if maximum {
+				candidates = append(candidates, m)
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	if len(candidates) > opts.Candidates {
+		candidates = candidates[:opts.Candidates]
+	}
+
+	//Coarse to fine refinement
+	for i := range candidates {
+		m := candidates[i]
+		for l := levels; l >= 0; l-- {
+			lv := pyramid[l]
+			f := float64(int(1) << uint(l))
+			ds, da := opts.ScaleStep*f, math.Min(opts.AngleStep*f, 90)
+			if l < levels {
+				m.X, m.Y = finer(m.X, lv.w, pyramid[l+1].w), finer(m.Y, lv.h, pyramid[l+1].h)
+			}
+			m.Score = lv.score(m.X, m.Y, m.Scale, m.Angle)
+			m = lv.climb(m, ds, da, opts)
+		}
+		m = pyramid[0].climb(m, opts.ScaleStep/2, opts.AngleStep/2, opts)
+		candidates[i] = pyramid[0].interpolate(m, opts.ScaleStep/2, opts.AngleStep/2, opts)
+	}
+
+	//Non-maximum suppression on the template centers
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	t0w, t0h := tb.Dx(), tb.Dy()
+	for _, c := range candidates {
+		if c.Score < opts.Threshold {
+			break
+		}
+		if opts.MaxMatches > 0 && len(matches) == opts.MaxMatches {
+			break
+		}
+		suppressed := false
+		for _, m := range matches {
+			r := 0.5 * math.Min(m.Scale, c.Scale) * float64(min(t0w, t0h))
+			if math.Hypot(m.X-c.X, m.Y-c.Y) < r {
+				suppressed = true
+				break
+			}
+		}
+		if !suppressed {
+			matches = append(matches, c)
+		}
+	}
+	for i := range matches {
+		matches[i].X += float64(b.Min.X)
+		matches[i].Y += float64(b.Min.Y)
+	}
+	return
+}
+
+// climb moves the match to the best of its neighbors in position, scale and
+// angle until no neighbor improves the score.
+func (lv *searchLevel) climb(m ScaledMatch, ds, da float64, opts SearchOptions) ScaledMatch {
+	for iteration := 0; iteration < 32; iteration++ {
+		next := m
+		for _, dy := range []float64{-1, 0, 1} {
+			for _, dx := range []float64{-1, 0, 1} {
+				for _, s := range []float64{m.Scale - ds, m.Scale, m.Scale + ds} {
+					if s < opts.MinScale-1e-9 || s > opts.MaxScale+1e-9 {
+						continue
+					}
+					for _, a := range []float64{m.Angle - da, m.Angle, m.Angle + da} {
+						if a < opts.MinAngle-1e-9 || a > opts.MaxAngle+1e-9 {
+							continue
+						}
+						if v := lv.score(m.X+dx, m.Y+dy, s, a); v > next.Score {
+							next = ScaledMatch{X: m.X + dx, Y: m.Y + dy, Scale: s, Angle: a, Score: v}
+						}
+					}
+				}
+			}
+		}
+		if next == m {
+			break
+		}
+		m = next
+	}
+	return m
+}
+
+// interpolate refines each parameter of the match with a parabola through
+// the scores at the match and at one step on each side. The match is kept if
+// the interpolated one scores lower.
+func (lv *searchLevel) interpolate(m ScaledMatch, ds, da float64, opts SearchOptions) ScaledMatch {
+	vertex := func(f0, f1, f2 float64) float64 {
+		d := f0 - 2*f1 + f2
+		if d >= 0 {
+			return 0
+		}
+		return clamp((f0-f2)/(2*d), -0.5, 0.5)
+	}
+	out := m
+	out.X += vertex(lv.score(m.X-1, m.Y, m.Scale, m.Angle), m.Score, lv.score(m.X+1, m.Y, m.Scale, m.Angle))
+	out.Y += vertex(lv.score(m.X, m.Y-1, m.Scale, m.Angle), m.Score, lv.score(m.X, m.Y+1, m.Scale, m.Angle))
+	if opts.MaxScale > opts.MinScale {
+		s := m.Scale + ds*vertex(lv.score(m.X, m.Y, m.Scale-ds, m.Angle), m.Score, lv.score(m.X, m.Y, m.Scale+ds, m.Angle))
+		out.Scale = clamp(s, opts.MinScale, opts.MaxScale)
+	}
+	if opts.MaxAngle > opts.MinAngle {
+		a := m.Angle + da*vertex(lv.score(m.X, m.Y, m.Scale, m.Angle-da), m.Score, lv.score(m.X, m.Y, m.Scale, m.Angle+da))
+		out.Angle = clamp(a, opts.MinAngle, opts.MaxAngle)
+	}
+	v := lv.score(out.X, out.Y, out.Scale, out.Angle)
+	if v < m.Score {
+		return m
+	}
+	out.Score = v
+	return out
+}
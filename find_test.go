@@ -0,0 +1,141 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// noiseGray returns a w-by-h image of uniform random gray levels.
+func noiseGray(w, h int) *image.Gray {
+	rnd := rand.New(rand.NewSource(1))
+	gray := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(rnd.Intn(256))
+	}
+	return gray
+}
+
+func TestFindTemplate(t *testing.T) {
+	img := noiseGray(64, 48)
+	sizes := []image.Point{{5, 4}, {12, 10}}
+	methods := []struct {
+		method    MatchMethod
+		threshold float64
+	}{
+		{MatchSSD, 1e-6},
+		{MatchSSDNormed, 1e-9},
+		{MatchZNCC, 0.999},
+	}
+	for _, size := range sizes {
+		r := image.Rectangle{Min: image.Pt(30, 20)}
+		r.Max = r.Min.Add(size)
+		tmpl := image.NewGray(image.Rectangle{Max: size})
+		for y := 0; y < size.Y; y++ {
+			for x := 0; x < size.X; x++ {
+				tmpl.SetGray(x, y, img.GrayAt(r.Min.X+x, r.Min.Y+y))
+			}
+		}
+		for _, m := range methods {
+			scores, matches := FindTemplate(img, tmpl, m.method, m.threshold, 0)
+			rows, cols := scores.Size()
+			if rows != 48-size.Y+1 || cols != 64-size.X+1 {
+				t.Fatalf("score map size = %dx%d", rows, cols)
+			}
+			if len(matches) != 1 || matches[0].Rect != r {
+				t.Errorf("method %d, size %v: matches = %v, want %v", m.method, size, matches, r)
+			}
+		}
+		//Cross-correlation at the match must equal the template energy
+		scores := MatchTemplate(img, tmpl, MatchCCorr)
+		energy := 0.
+		for _, v := range tmpl.Pix {
+			energy += float64(v) * float64(v)
+		}
+		if got := (*scores)[20][30]; math.Abs(got-energy) > 1e-6*energy {
+			t.Errorf("MatchCCorr at the template = %v, want %v", got, energy)
+		}
+	}
+}
+
+// smoothTexture returns a w-by-h random texture blurred with a gaussian of
+// standard deviation σ.
+func smoothTexture(w, h int, σ float64, seed int64) []float64 {
+	rnd := rand.New(rand.NewSource(seed))
+	values := make([]float64, w*h)
+	for i := range values {
+		values[i] = float64(rnd.Intn(256))
+	}
+	values = gaussianBlur(values, w, h, σ)
+	//Stretch the contrast back after blurring
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	for i := range values {
+		values[i] = 255 * (values[i] - lo) / (hi - lo)
+	}
+	return values
+}
+
+func TestFindTemplateMultiScale(t *testing.T) {
+	//The template is the center of a larger texture, which is rendered
+	//scaled and rotated over another texture
+	const pw, ph, tw, th = 61, 53, 41, 33
+	pv := smoothTexture(pw, ph, 2, 1)
+	tmpl := image.NewGray(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		for x := 0; x < tw; x++ {
+			tmpl.Pix[y*tw+x] = uint8(pv[(y+(ph-th)/2)*pw+x+(pw-tw)/2] + 0.5)
+		}
+	}
+	const w, h = 160, 120
+	const cx, cy, scale, angle = 90.0, 55.0, 1.15, 23.0
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	bg := smoothTexture(w, h, 2, 2)
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := float64(x)-cx, float64(y)-cy
+			u := (dx*cos-dy*sin)/scale + float64(pw-1)/2
+			v := (dx*sin+dy*cos)/scale + float64(ph-1)/2
+			if u >= 0 && v >= 0 && u <= pw-1 && v <= ph-1 {
+				img.Pix[y*w+x] = uint8(bilinearAt(pv, pw, ph, u, v) + 0.5)
+			} else {
+				img.Pix[y*w+x] = uint8(bg[y*w+x])
+			}
+		}
+	}
+
+	matches := FindTemplateMultiScale(img, tmpl, SearchOptions{
+		MinScale: 0.9, MaxScale: 1.3, ScaleStep: 0.05,
+		MinAngle: -45, MaxAngle: 45, AngleStep: 2,
+		Threshold:  0.8,
+		MaxMatches: 1,
+	})
+	if len(matches) != 1 {
+		t.Fatalf("FindTemplateMultiScale() found %d matches", len(matches))
+	}
+	m := matches[0]
+	if math.Hypot(m.X-cx, m.Y-cy) > 0.5 || math.Abs(m.Scale-scale) > 0.02 || math.Abs(m.Angle-angle) > 1 {
+		t.Errorf("FindTemplateMultiScale() = %+v, want center (%v, %v), scale %v, angle %v", m, cx, cy, scale, angle)
+	}
+	//The score is the correlation at the reported match
+	lv := newSearchLevel(grayPlane(img).values, w, h, grayPlane(tmpl).values, tw, th)
+	if v := lv.score(m.X, m.Y, m.Scale, m.Angle); math.Abs(v-m.Score) > 1e-9 {
+		t.Errorf("FindTemplateMultiScale() scored %v, but the match scores %v", m.Score, v)
+	}
+
+	//Refining only the best coarse candidate with the full threshold
+	matches = FindTemplateMultiScale(img, tmpl, SearchOptions{
+		MinScale: 0.9, MaxScale: 1.3, ScaleStep: 0.05,
+		MinAngle: -45, MaxAngle: 45, AngleStep: 2,
+		Threshold:    0.5,
+		CoarseMargin: -1,
+		Candidates:   1,
+	})
+	if len(matches) != 1 || math.Hypot(matches[0].X-cx, matches[0].Y-cy) > 0.5 {
+		t.Errorf("FindTemplateMultiScale() with one candidate = %+v", matches)
+	}
+}
@@ -0,0 +1,427 @@
+package vision
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/joaowiciuk/matrix"
+)
+
+// Float64Image is an image of float64 samples with interleaved channels,
+// which is the common intermediate representation of the package. Samples
+// use the 0..255 range of 8-bit images, so they convert without scaling,
+// but may hold any value. One channel is gray, three are RGB and four are
+// premultiplied RGBA.
+type Float64Image struct {
+	// Pix holds the samples of the image. The sample of channel c at
+	// (x, y) starts at Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)*Channels + c].
+	Pix []float64
+	// Stride is the Pix stride in samples between vertically adjacent pixels.
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+	// Channels is the number of samples per pixel.
+	Channels int
+}
+
+// NewFloat64Image returns a new Float64Image with the given bounds and
+// number of channels.
+func NewFloat64Image(r image.Rectangle, channels int) *Float64Image {
+	return &Float64Image{
+		Pix:      make([]float64, r.Dx()*r.Dy()*channels),
+		Stride:   r.Dx() * channels,
+		Rect:     r,
+		Channels: channels,
+	}
+}
+
+// floatColorModel returns the color model of an image with the given
+// number of channels.
+func floatColorModel(channels int) color.Model {
+	if channels == 1 {
+		return color.Gray16Model
+	}
+	return color.RGBA64Model
+}
+
+// floatColor returns the color of the samples, which are in the 0..255 range.
+func floatColor(samples []float64) color.Color {
+	c16 := func(v float64) uint16 {
+		return uint16(clamp(math.Floor(v*257+0.5), 0, math.MaxUint16))
+	}
+	switch len(samples) {
+	case 1:
+		return color.Gray16{Y: c16(samples[0])}
+	case 3:
+		return color.RGBA64{R: c16(samples[0]), G: c16(samples[1]), B: c16(samples[2]), A: math.MaxUint16}
+	}
+	return color.RGBA64{R: c16(samples[0]), G: c16(samples[1]), B: c16(samples[2]), A: c16(samples[3])}
+}
+
+// ColorModel returns Gray16Model for single channel images and RGBA64Model
+// otherwise.
+func (f *Float64Image) ColorModel() color.Model {
+	return floatColorModel(f.Channels)
+}
+
+// Bounds returns the domain for which At can return non-zero color.
+func (f *Float64Image) Bounds() image.Rectangle {
+	return f.Rect
+}
+
+// PixOffset returns the index of the first sample of the pixel at (x, y).
+func (f *Float64Image) PixOffset(x, y int) int {
+	return (y-f.Rect.Min.Y)*f.Stride + (x-f.Rect.Min.X)*f.Channels
+}
+
+// At returns the color of the pixel at (x, y), clamping the samples.
+func (f *Float64Image) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(f.Rect)) {
+		return floatColor(make([]float64, f.Channels))
+	}
+	i := f.PixOffset(x, y)
+	return floatColor(f.Pix[i : i+f.Channels])
+}
+
+// FloatAt returns the sample of channel c at (x, y), or zero outside the
+// bounds.
+func (f *Float64Image) FloatAt(x, y, c int) float64 {
+	if !(image.Point{x, y}.In(f.Rect)) {
+		return 0
+	}
+	return f.Pix[f.PixOffset(x, y)+c]
+}
+
+// SetFloat sets the sample of channel c at (x, y).
+func (f *Float64Image) SetFloat(x, y, c int, v float64) {
+	if !(image.Point{x, y}.In(f.Rect)) {
+		return
+	}
+	f.Pix[f.PixOffset(x, y)+c] = v
+}
+
+// SubImage returns an image representing the portion of the image visible
+// through r. The returned value shares samples with the original image.
+func (f *Float64Image) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(f.Rect)
+	if r.Empty() {
+		return &Float64Image{Channels: f.Channels}
+	}
+	i := f.PixOffset(r.Min.X, r.Min.Y)
+	return &Float64Image{Pix: f.Pix[i:], Stride: f.Stride, Rect: r, Channels: f.Channels}
+}
+
+// contiguous reports whether the rows of the image are adjacent in Pix.
+func (f *Float64Image) contiguous() bool {
+	return f.Stride == f.Rect.Dx()*f.Channels
+}
+
+// row returns the samples of the row y.
+func (f *Float64Image) row(y int) []float64 {
+	i := f.PixOffset(f.Rect.Min.X, y)
+	return f.Pix[i : i+f.Rect.Dx()*f.Channels]
+}
+
+// ImageToFloat64 converts an image to a Float64Image. Gray and Gray16
+// images give one channel and any other image four channels of
+// premultiplied RGBA. Images with 16-bit color models are scaled to the
+// 0..255 range without rounding, so they convert losslessly, while Im2Mat
+// keeps their 0..65535 range. Gray, Gray16, RGBA and RGBA64 images are
+// converted directly from their pixels and the others through draw.Draw.
+func ImageToFloat64(img image.Image) *Float64Image {
+	if img == nil {
+		return nil
+	}
+	if f, ok := img.(*Float64Image); ok {
+		out := NewFloat64Image(f.Rect, f.Channels)
+		for y := f.Rect.Min.Y; y < f.Rect.Max.Y; y++ {
+			copy(out.row(y), f.row(y))
+		}
+		return out
+	}
+	b := img.Bounds()
+	w := b.Dx()
+	switch src := img.(type) {
+	case *image.Gray:
+		f := NewFloat64Image(b, 1)
+		for y := 0; y < b.Dy(); y++ {
+			pix := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):]
+			row := f.Pix[y*w : (y+1)*w]
+			for x := range row {
+				row[x] = float64(pix[x])
+			}
+		}
+		return f
+	case *image.RGBA:
+		f := NewFloat64Image(b, 4)
+		for y := 0; y < b.Dy(); y++ {
+			pix := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):]
+			row := f.Pix[y*4*w : (y+1)*4*w]
+			for x := range row {
+				row[x] = float64(pix[x])
+			}
+		}
+		return f
+	case *image.Gray16:
+		return pixFloat64(src.Pix[src.PixOffset(b.Min.X, b.Min.Y):], src.Stride, b, 1)
+	case *image.RGBA64:
+		return pixFloat64(src.Pix[src.PixOffset(b.Min.X, b.Min.Y):], src.Stride, b, 4)
+	case *Float32Image:
+		return src.Float64()
+	}
+	switch img.ColorModel() {
+	case color.GrayModel:
+		gray := image.NewGray(b)
+		draw.Draw(gray, b, img, b.Min, draw.Src)
+		return ImageToFloat64(gray)
+	case color.Gray16Model:
+		gray := image.NewGray16(b)
+		draw.Draw(gray, b, img, b.Min, draw.Src)
+		return ImageToFloat64(gray)
+	case color.RGBA64Model, color.NRGBA64Model:
+		rgba := image.NewRGBA64(b)
+		draw.Draw(rgba, b, img, b.Min, draw.Src)
+		return ImageToFloat64(rgba)
+	}
+	rgba := image.NewRGBA(b)
+	draw.Draw(rgba, b, img, b.Min, draw.Src)
+	return ImageToFloat64(rgba)
+}
+
+// luma returns the first channel of images with fewer than three channels
+// and the BT.601 luma of the color channels of any other image.
+func (f *Float64Image) luma() *Float64Image {
+	if f.Channels < 3 {
+		out := NewFloat64Image(f.Rect, 1)
+		for y := f.Rect.Min.Y; y < f.Rect.Max.Y; y++ {
+			row, dst := f.row(y), out.row(y)
+			for x := range dst {
+				dst[x] = row[x*f.Channels]
+			}
+		}
+		return out
+	}
+	out := NewFloat64Image(f.Rect, 1)
+	w := f.Rect.Dx()
+	for y := f.Rect.Min.Y; y < f.Rect.Max.Y; y++ {
+		row, dst := f.row(y), out.row(y)
+		for x := 0; x < w; x++ {
+			s := row[x*f.Channels:]
+			dst[x] = luma601[0]*s[0] + luma601[1]*s[1] + luma601[2]*s[2]
+		}
+	}
+	return out
+}
+
+// pixFloat64 converts the big-endian 16-bit samples of an image with the
+// given number of channels to a Float64Image.
+func pixFloat64(pix []uint8, stride int, b image.Rectangle, channels int) *Float64Image {
+	f := NewFloat64Image(b, channels)
+	for y := 0; y < b.Dy(); y++ {
+		src := pix[y*stride:]
+		row := f.Pix[y*f.Stride : (y+1)*f.Stride]
+		for i := range row {
+			row[i] = float64(uint16(src[2*i])<<8|uint16(src[2*i+1])) / 257
+		}
+	}
+	return f
+}
+
+// Gray converts the image to grayscale, rounding and clamping its samples.
+// Images with more than one channel are converted with their luma.
+func (f *Float64Image) Gray() *image.Gray {
+	l := f.luma()
+	gray := image.NewGray(f.Rect)
+	for y := f.Rect.Min.Y; y < f.Rect.Max.Y; y++ {
+		pix := gray.Pix[gray.PixOffset(f.Rect.Min.X, y):]
+		for x, v := range l.row(y) {
+			pix[x] = uint8(clamp(math.Floor(v+0.5), 0, 255))
+		}
+	}
+	return gray
+}
+
+// RGBA converts the image to RGBA, rounding and clamping its samples. Gray
+// images are replicated in the color channels and images without alpha are
+// opaque.
+func (f *Float64Image) RGBA() *image.RGBA {
+	rgba := image.NewRGBA(f.Rect)
+	w := f.Rect.Dx()
+	c8 := func(v float64) uint8 { return uint8(clamp(math.Floor(v+0.5), 0, 255)) }
+	for y := f.Rect.Min.Y; y < f.Rect.Max.Y; y++ {
+		row := f.row(y)
+		pix := rgba.Pix[rgba.PixOffset(f.Rect.Min.X, y):]
+		for x := 0; x < w; x++ {
+			s := row[x*f.Channels : (x+1)*f.Channels]
+			p := pix[4*x : 4*x+4]
+			switch f.Channels {
+			case 1:
+				p[0], p[1], p[2], p[3] = c8(s[0]), c8(s[0]), c8(s[0]), 255
+			case 3:
+				p[0], p[1], p[2], p[3] = c8(s[0]), c8(s[1]), c8(s[2]), 255
+			default:
+				p[0], p[1], p[2], p[3] = c8(s[0]), c8(s[1]), c8(s[2]), c8(s[3])
+			}
+		}
+	}
+	return rgba
+}
+
+// Matrix returns the single channel image as a matrix whose rows share the
+// samples of the image, so changes to either are visible in both. It returns
+// nil if the image has more than one channel.
+func (f *Float64Image) Matrix() *matrix.Matrix {
+	if f.Channels != 1 {
+		return nil
+	}
+	mat := make(matrix.Matrix, f.Rect.Dy())
+	for y := range mat {
+		mat[y] = f.row(f.Rect.Min.Y + y)
+	}
+	return &mat
+}
+
+// Matrices returns a copy of each channel of the image as a matrix, in the
+// layout of Im2Mat.
+func (f *Float64Image) Matrices() []*matrix.Matrix {
+	w, h := f.Rect.Dx(), f.Rect.Dy()
+	array := make([]*matrix.Matrix, f.Channels)
+	for c := range array {
+		array[c] = matrix.New(h, w)
+	}
+	for y := 0; y < h; y++ {
+		row := f.row(f.Rect.Min.Y + y)
+		if f.Channels == 1 {
+			copy((*array[0])[y], row)
+			continue
+		}
+		for x := 0; x < w; x++ {
+			for c := range array {
+				(*array[c])[y][x] = row[x*f.Channels+c]
+			}
+		}
+	}
+	return array
+}
+
+// MatricesToFloat64 interleaves matrices of the same size as the channels of
+// a Float64Image. It returns nil if there are no matrices or their sizes
+// differ.
+func MatricesToFloat64(array []*matrix.Matrix) *Float64Image {
+	if len(array) == 0 || array[0] == nil {
+		return nil
+	}
+	h, w := array[0].Size()
+	for _, m := range array[1:] {
+		if m == nil {
+			return nil
+		}
+		if r, c := m.Size(); r != h || c != w {
+			return nil
+		}
+	}
+	f := NewFloat64Image(image.Rect(0, 0, w, h), len(array))
+	for y := 0; y < h; y++ {
+		row := f.row(y)
+		if f.Channels == 1 {
+			copy(row, (*array[0])[y])
+			continue
+		}
+		for c, m := range array {
+			src := (*m)[y]
+			for x := 0; x < w; x++ {
+				row[x*f.Channels+c] = src[x]
+			}
+		}
+	}
+	return f
+}
+
+// Float32Image is a Float64Image with float32 samples, which halves the
+// memory of large intermediate results.
+type Float32Image struct {
+	Pix      []float32
+	Stride   int
+	Rect     image.Rectangle
+	Channels int
+}
+
+// NewFloat32Image returns a new Float32Image with the given bounds and
+// number of channels.
+func NewFloat32Image(r image.Rectangle, channels int) *Float32Image {
+	return &Float32Image{
+		Pix:      make([]float32, r.Dx()*r.Dy()*channels),
+		Stride:   r.Dx() * channels,
+		Rect:     r,
+		Channels: channels,
+	}
+}
+
+// ColorModel returns Gray16Model for single channel images and RGBA64Model
+// otherwise.
+func (f *Float32Image) ColorModel() color.Model {
+	return floatColorModel(f.Channels)
+}
+
+// Bounds returns the domain for which At can return non-zero color.
+func (f *Float32Image) Bounds() image.Rectangle {
+	return f.Rect
+}
+
+// PixOffset returns the index of the first sample of the pixel at (x, y).
+func (f *Float32Image) PixOffset(x, y int) int {
+	return (y-f.Rect.Min.Y)*f.Stride + (x-f.Rect.Min.X)*f.Channels
+}
+
+// At returns the color of the pixel at (x, y), clamping the samples.
+func (f *Float32Image) At(x, y int) color.Color {
+	samples := make([]float64, f.Channels)
+	if (image.Point{x, y}.In(f.Rect)) {
+		i := f.PixOffset(x, y)
+		for c := range samples {
+			samples[c] = float64(f.Pix[i+c])
+		}
+	}
+	return floatColor(samples)
+}
+
+// SubImage returns an image representing the portion of the image visible
+// through r. The returned value shares samples with the original image.
+func (f *Float32Image) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(f.Rect)
+	if r.Empty() {
+		return &Float32Image{Channels: f.Channels}
+	}
+	i := f.PixOffset(r.Min.X, r.Min.Y)
+	return &Float32Image{Pix: f.Pix[i:], Stride: f.Stride, Rect: r, Channels: f.Channels}
+}
+
+// Float64 converts the image to a Float64Image.
+func (f *Float32Image) Float64() *Float64Image {
+	out := NewFloat64Image(f.Rect, f.Channels)
+	n := f.Rect.Dx() * f.Channels
+	for y := 0; y < f.Rect.Dy(); y++ {
+		src := f.Pix[y*f.Stride : y*f.Stride+n]
+		dst := out.Pix[y*out.Stride : y*out.Stride+n]
+		for i, v := range src {
+			dst[i] = float64(v)
+		}
+	}
+	return out
+}
+
+// Float32 converts the image to a Float32Image.
+func (f *Float64Image) Float32() *Float32Image {
+	out := NewFloat32Image(f.Rect, f.Channels)
+	n := f.Rect.Dx() * f.Channels
+	for y := 0; y < f.Rect.Dy(); y++ {
+		src := f.Pix[y*f.Stride : y*f.Stride+n]
+		dst := out.Pix[y*out.Stride : y*out.Stride+n]
+		for i, v := range src {
+			dst[i] = float32(v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+package vision
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFloat64Image_Conversions(t *testing.T) {
+	rgba := image.NewRGBA(image.Rect(2, 3, 7, 9))
+	for i := range rgba.Pix {
+		rgba.Pix[i] = uint8(i * 7)
+	}
+	for i := 3; i < len(rgba.Pix); i += 4 {
+		rgba.Pix[i] = 255
+	}
+	f := ImageToFloat64(rgba)
+	if f.Channels != 4 || f.Rect != rgba.Rect {
+		t.Fatalf("got %d channels and bounds %v", f.Channels, f.Rect)
+	}
+	back := f.RGBA()
+	for i := range rgba.Pix {
+		if back.Pix[i] != rgba.Pix[i] {
+			t.Fatalf("sample %d: expected %d, got %d", i, rgba.Pix[i], back.Pix[i])
+		}
+	}
+	if c := f.At(4, 5); c != color.RGBA64Model.Convert(rgba.At(4, 5)) {
+		t.Errorf("At(4, 5): expected %v, got %v", rgba.At(4, 5), c)
+	}
+
+	gray := f.Gray()
+	for y := 3; y < 9; y++ {
+		for x := 2; x < 7; x++ {
+			expected := color.GrayModel.Convert(rgba.At(x, y)).(color.Gray).Y
+			if got := gray.GrayAt(x, y).Y; got < expected-1 || got > expected+1 {
+				t.Fatalf("gray at (%d, %d): expected %d, got %d", x, y, expected, got)
+			}
+		}
+	}
+	g := ImageToFloat64(gray)
+	if g.Channels != 1 || g.FloatAt(4, 5, 0) != float64(gray.GrayAt(4, 5).Y) {
+		t.Errorf("gray conversion: got %d channels and %v", g.Channels, g.FloatAt(4, 5, 0))
+	}
+
+	if f32 := f.Float32().Float64(); f32.FloatAt(6, 8, 2) != f.FloatAt(6, 8, 2) {
+		t.Errorf("float32 round trip: expected %v, got %v", f.FloatAt(6, 8, 2), f32.FloatAt(6, 8, 2))
+	}
+}
+
+func TestFloat64Image_Matrix(t *testing.T) {
+	f := NewFloat64Image(image.Rect(0, 0, 4, 3), 1)
+	mat := f.Matrix()
+	(*mat)[1][2] = 42
+	if f.FloatAt(2, 1, 0) != 42 {
+		t.Errorf("matrix does not share samples with the image")
+	}
+	sub := f.SubImage(image.Rect(1, 1, 3, 3)).(*Float64Image)
+	sub.SetFloat(2, 2, 0, 7)
+	if f.FloatAt(2, 2, 0) != 7 || sub.FloatAt(2, 1, 0) != 42 {
+		t.Errorf("subimage does not share samples with the image")
+	}
+	if m := sub.Matrix(); (*m)[0][1] != 42 || (*m)[1][1] != 7 {
+		t.Errorf("subimage matrix: got %v", *m)
+	}
+
+	rgb := MatricesToFloat64(NewFloat64Image(image.Rect(0, 0, 4, 3), 3).Matrices())
+	if rgb.Channels != 3 || rgb.Matrix() != nil {
+		t.Errorf("multichannel matrix: got %d channels", rgb.Channels)
+	}
+	if c := rgb.At(0, 0).(color.RGBA64); c.A != 0xffff {
+		t.Errorf("RGB images should be opaque, got %v", c)
+	}
+}
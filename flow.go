@@ -0,0 +1,288 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"sync"
+
+	"github.com/joaowiciuk/matrix"
+)
+
+// at returns the bilinear interpolation of the plane at (x, y), extending it
+// at its borders.
+func (p plane) at(x, y float64) float64 {
+	return bilinearAt(p.values, p.w, p.h, clamp(x, 0, float64(p.w-1)), clamp(y, 0, float64(p.h-1)))
+}
+
+// flowPyramid returns the gaussian pyramids of two frames halving at each
+// level, with as many levels as both allow up to n.
+func flowPyramid(prev, next *image.Gray, n int) (p0, p1 []plane) {
+	p0 = gaussianPlanes(grayPlane(prev), max(n, 1), 0.5)
+	p1 = gaussianPlanes(grayPlane(next), len(p0), 0.5)
+	return p0[:len(p1)], p1
+}
+
+// LucasKanade tracks points from the previous frame to the next one with the
+// pyramidal implementation of the Lucas-Kanade feature tracker described in
+// J.-Y. Bouguet, Pyramidal Implementation of the Lucas Kanade Feature Tracker,
+// Intel Corporation, Microprocessor Research Labs (2000).
+//
+// The flow is solved over square windows of the given radius at each level of
+// a gaussian pyramid with the given number of levels, so motions of up to
+// about radius·2^(levels-1) pixels are tracked. Points such as the corners
+// of Harris or FAST track best. It returns the tracked positions and whether
+// each point was tracked; it is not when its window is flat or it leaves the
+// frame.
+func LucasKanade(prev, next *image.Gray, points []Point2D, radius, levels int) (tracked []Point2D, status []bool) {
+	tracked = make([]Point2D, len(points))
+	status = make([]bool, len(points))
+	if !prev.Bounds().Size().Eq(next.Bounds().Size()) || radius < 1 {
+		return
+	}
+	p0, p1 := flowPyramid(prev, next, levels)
+	base := p0[0]
+	wg := sync.WaitGroup{}
+	for k := range points {
+		wg.Add(1)
+		go func(k int) {
+			tracked[k], status[k] = lucasKanadePoint(p0, p1, base, points[k], radius)
+			wg.Done()
+		}(k)
+	}
+	wg.Wait()
+	return
+}
+
+// lucasKanadePoint tracks a single point from the coarsest level down.
+func lucasKanadePoint(p0, p1 []plane, base plane, point Point2D, radius int) (Point2D, bool) {
+	n := 2*radius + 1
+	ix, iy, i0 := make([]float64, n*n), make([]float64, n*n), make([]float64, n*n)
+	var gx, gy float64
+	for l := len(p0) - 1; l >= 0; l-- {
+		a, b := p0[l], p1[l]
+		fx, fy := float64(a.w)/float64(base.w), float64(a.h)/float64(base.h)
+		x, y := (point.X+0.5)*fx-0.5, (point.Y+0.5)*fy-0.5
+
+		//Spatial gradient matrix of the window in the previous frame
+		var g00, g01, g11 float64
+		for j := 0; j < n; j++ {
+			for i := 0; i < n; i++ {
+				u, v := x+float64(i-radius), y+float64(j-radius)
+				k := j*n + i
+				i0[k] = a.at(u, v)
+				ix[k] = (a.at(u+1, v) - a.at(u-1, v)) / 2
+				iy[k] = (a.at(u, v+1) - a.at(u, v-1)) / 2
+				g00 += ix[k] * ix[k]
+				g01 += ix[k] * iy[k]
+				g11 += iy[k] * iy[k]
+			}
+		}
+		det := g00*g11 - g01*g01
+		//The smallest eigenvalue of the gradient matrix measures how well
+		//the window constrains the motion
+		λ := (g00 + g11 - math.Sqrt((g00-g11)*(g00-g11)+4*g01*g01)) / 2
+		if det <= 0 || λ < 1e-4*float64(n*n) {
+			return point, false
+		}
+
+		var dx, dy float64
+		for iteration := 0; iteration < 20; iteration++ {
+			var b0, b1 float64
+			for j := 0; j < n; j++ {
+				for i := 0; i < n; i++ {
+					k := j*n + i
+					u, v := x+gx+dx+float64(i-radius), y+gy+dy+float64(j-radius)
+					δ := i0[k] - b.at(u, v)
+					b0 += δ * ix[k]
+					b1 += δ * iy[k]
+				}
+			}
+			ex, ey := (g11*b0-g01*b1)/det, (g00*b1-g01*b0)/det
+			dx += ex
+			dy += ey
+			if ex*ex+ey*ey < 1e-4 {
+				break
+			}
+		}
+		gx, gy = gx+dx, gy+dy
+		if l > 0 {
+			//Carry the flow to the next finer level
+			c := p0[l-1]
+			gx *= float64(c.w) / float64(a.w)
+			gy *= float64(c.h) / float64(a.h)
+		}
+	}
+	q := Point2D{point.X + gx, point.Y + gy}
+	if q.X < 0 || q.Y < 0 || q.X > float64(base.w-1) || q.Y > float64(base.h-1) {
+		return q, false
+	}
+	return q, true
+}
+
+// HornSchunck estimates the dense optical flow from the previous frame to
+// the next one, as described in
+// B. K. P. Horn and B. G. Schunck, Determining optical flow,
+// Artificial Intelligence, 17 (1981), pp. 185–203.
+// https://doi.org/10.1016/0004-3702(81)90024-2
+//
+// The flow is estimated coarse to fine over a gaussian pyramid with the given
+// number of levels, warping the next frame by the flow of the coarser level
+// so motions larger than a pixel are recovered. Alpha weights the smoothness
+// of the flow, in the units of the image gradients, and iterations is the
+// number of Jacobi iterations per level. It returns the horizontal and
+// vertical displacements of every pixel, or nil if the frames have different
+// sizes.
+func HornSchunck(prev, next *image.Gray, alpha float64, iterations, levels int) (u, v *matrix.Matrix) {
+	if !prev.Bounds().Size().Eq(next.Bounds().Size()) {
+		return nil, nil
+	}
+	p0, p1 := flowPyramid(prev, next, levels)
+	var fu, fv plane
+	for l := len(p0) - 1; l >= 0; l-- {
+		a, b := p0[l], p1[l]
+		w, h := a.w, a.h
+		if l == len(p0)-1 {
+			fu = plane{make([]float64, w*h), w, h}
+			fv = plane{make([]float64, w*h), w, h}
+		} else {
+			//Expand the coarser flow, scaling its magnitude
+			sx, sy := float64(w)/float64(fu.w), float64(h)/float64(fu.h)
+			fu, fv = fu.resample(w, h), fv.resample(w, h)
+			for i := range fu.values {
+				fu.values[i] *= sx
+				fv.values[i] *= sy
+			}
+		}
+		fu, fv = hornSchunckLevel(a, b, fu, fv, alpha, iterations)
+	}
+	rows, cols := fu.h, fu.w
+	u, v = matrix.New(rows, cols), matrix.New(rows, cols)
+	for y := 0; y < rows; y++ {
+		copy((*u)[y], fu.values[y*cols:(y+1)*cols])
+		copy((*v)[y], fv.values[y*cols:(y+1)*cols])
+	}
+	return
+}
+
+// hornSchunckLevel refines the flow (u0, v0) between two planes, linearizing
+// the brightness constancy around it.
+func hornSchunckLevel(a, b, u0, v0 plane, alpha float64, iterations int) (plane, plane) {
+	w, h := a.w, a.h
+	ix, iy, it := make([]float64, w*h), make([]float64, w*h), make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			fx, fy := float64(x)+u0.values[i], float64(y)+v0.values[i]
+			//Gradients of the average of both frames, the next one warped
+			X, Y := float64(x), float64(y)
+			ix[i] = (a.at(X+1, Y) - a.at(X-1, Y) + b.at(fx+1, fy) - b.at(fx-1, fy)) / 4
+			iy[i] = (a.at(X, Y+1) - a.at(X, Y-1) + b.at(fx, fy+1) - b.at(fx, fy-1)) / 4
+			it[i] = b.at(fx, fy) - a.values[i]
+		}
+	}
+	u := plane{append([]float64(nil), u0.values...), w, h}
+	v := plane{append([]float64(nil), v0.values...), w, h}
+	nu, nv := make([]float64, w*h), make([]float64, w*h)
+	α2 := alpha * alpha
+	//average is the weighted mean of the eight neighbors
+	average := func(p plane, x, y int) float64 {
+		at := func(i, j int) float64 {
+			return p.values[borderIndex(y+j, h, BorderReplicate)*w+borderIndex(x+i, w, BorderReplicate)]
+		}
+		return (at(-1, 0)+at(1, 0)+at(0, -1)+at(0, 1))/6 + (at(-1, -1)+at(1, -1)+at(-1, 1)+at(1, 1))/12
+	}
+	for iteration := 0; iteration < iterations; iteration++ {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				i := y*w + x
+				ua, va := average(u, x, y), average(v, x, y)
+				r := (ix[i]*(ua-u0.values[i]) + iy[i]*(va-v0.values[i]) + it[i]) / (α2 + ix[i]*ix[i] + iy[i]*iy[i])
+				nu[i] = ua - ix[i]*r
+				nv[i] = va - iy[i]*r
+			}
+		}
+		copy(u.values, nu)
+		copy(v.values, nv)
+	}
+	return u, v
+}
+
+// flowWheel returns the color wheel of the Middlebury flow visualization.
+func flowWheel() [][3]float64 {
+	const ry, yg, gc, cb, bm, mr = 15, 6, 4, 11, 13, 6
+	wheel := make([][3]float64, 0, ry+yg+gc+cb+bm+mr)
+	for i := 0; i < ry; i++ {
+		wheel = append(wheel, [3]float64{255, 255 * float64(i) / ry, 0})
+	}
+	for i := 0; i < yg; i++ {
+		wheel = append(wheel, [3]float64{255 - 255*float64(i)/yg, 255, 0})
+	}
+	for i := 0; i < gc; i++ {
+		wheel = append(wheel, [3]float64{0, 255, 255 * float64(i) / gc})
+	}
+	for i := 0; i < cb; i++ {
+		wheel = append(wheel, [3]float64{0, 255 - 255*float64(i)/cb, 255})
+	}
+	for i := 0; i < bm; i++ {
+		wheel = append(wheel, [3]float64{255 * float64(i) / bm, 0, 255})
+	}
+	for i := 0; i < mr; i++ {
+		wheel = append(wheel, [3]float64{255, 0, 255 - 255*float64(i)/mr})
+	}
+	return wheel
+}
+
+// FlowToColor renders a flow field with the color coding of
+// S. Baker, D. Scharstein, J. P. Lewis, S. Roth, M. J. Black and R. Szeliski, A Database and Evaluation Methodology for Optical Flow,
+// International Journal of Computer Vision, 92 (2011), pp. 1–31.
+// https://doi.org/10.1007/s11263-010-0390-2
+//
+// where the hue is the direction of the motion and the saturation its
+// magnitude relative to maxMagnitude, white being still. A non positive
+// maxMagnitude uses the largest magnitude of the field. It returns nil if
+// the fields have different sizes.
+func FlowToColor(u, v *matrix.Matrix, maxMagnitude float64) *image.RGBA {
+	if u == nil || v == nil {
+		return nil
+	}
+	rows, cols := u.Size()
+	if r, c := v.Size(); r != rows || c != cols {
+		return nil
+	}
+	if maxMagnitude <= 0 {
+		for y := 0; y < rows; y++ {
+			for x := 0; x < cols; x++ {
+				maxMagnitude = math.Max(maxMagnitude, math.Hypot((*u)[y][x], (*v)[y][x]))
+			}
+		}
+		if maxMagnitude == 0 {
+			maxMagnitude = 1
+		}
+	}
+	wheel := flowWheel()
+	n := float64(len(wheel))
+	img := image.NewRGBA(image.Rect(0, 0, cols, rows))
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			fu, fv := (*u)[y][x]/maxMagnitude, (*v)[y][x]/maxMagnitude
+			radius := math.Hypot(fu, fv)
+			angle := math.Atan2(-fv, -fu) / math.Pi
+			k := (angle + 1) / 2 * (n - 1)
+			k0 := int(k)
+			k1 := (k0 + 1) % len(wheel)
+			f := k - float64(k0)
+			o := img.PixOffset(x, y)
+			for c := 0; c < 3; c++ {
+				col := ((1-f)*wheel[k0][c] + f*wheel[k1][c]) / 255
+				if radius <= 1 {
+					col = 1 - radius*(1-col)
+				} else {
+					col *= 0.75
+				}
+				img.Pix[o+c] = uint8(math.Floor(255*col + 0.5))
+			}
+			img.Pix[o+3] = 255
+		}
+	}
+	return img
+}
@@ -0,0 +1,86 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// shiftedTextures returns a smooth texture of random waves and the same
+// texture moved by (dx, dy) pixels.
+func shiftedTextures(w, h int, dx, dy float64) (prev, next *image.Gray) {
+	rnd := rand.New(rand.NewSource(1))
+	var waves [12][4]float64
+	for k := range waves {
+		θ := rnd.Float64() * 2 * math.Pi
+		ω := 2 * math.Pi / (8 + 16*rnd.Float64())
+		waves[k] = [4]float64{ω * math.Cos(θ), ω * math.Sin(θ), rnd.Float64() * 2 * math.Pi, 10 + 10*rnd.Float64()}
+	}
+	render := func(ox, oy float64) *image.Gray {
+		p := plane{make([]float64, w*h), w, h}
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				v := 128.
+				for _, wave := range waves {
+					v += wave[3] * math.Sin(wave[0]*(float64(x)-ox)+wave[1]*(float64(y)-oy)+wave[2])
+				}
+				p.values[y*w+x] = v
+			}
+		}
+		return p.gray()
+	}
+	return render(0, 0), render(dx, dy)
+}
+
+func TestLucasKanade(t *testing.T) {
+	prev, next := shiftedTextures(96, 80, 5.4, -3.2)
+	var points []Point2D
+	for y := 20.; y < 60; y += 10 {
+		for x := 20.; x < 76; x += 10 {
+			points = append(points, Point2D{x, y})
+		}
+	}
+	tracked, status := LucasKanade(prev, next, points, 4, 3)
+	for i, p := range points {
+		if !status[i] {
+			t.Errorf("point %v was not tracked", p)
+			continue
+		}
+		if d := math.Hypot(tracked[i].X-p.X-5.4, tracked[i].Y-p.Y+3.2); d > 0.1 {
+			t.Errorf("point %v tracked to %v, %g pixels away", p, tracked[i], d)
+		}
+	}
+
+	//A flat window can not be tracked
+	flat := image.NewGray(image.Rect(0, 0, 32, 32))
+	if _, status := LucasKanade(flat, flat, []Point2D{{16, 16}}, 4, 2); status[0] {
+		t.Errorf("point in a flat frame was tracked")
+	}
+}
+
+func TestHornSchunck(t *testing.T) {
+	prev, next := shiftedTextures(64, 64, 2.5, 1.5)
+	u, v := HornSchunck(prev, next, 2, 200, 3)
+	var su, sv float64
+	n := 0
+	for y := 16; y < 48; y++ {
+		for x := 16; x < 48; x++ {
+			su += (*u)[y][x]
+			sv += (*v)[y][x]
+			n++
+		}
+	}
+	if mu, mv := su/float64(n), sv/float64(n); math.Abs(mu-2.5) > 0.25 || math.Abs(mv-1.5) > 0.25 {
+		t.Errorf("mean flow = (%.3f, %.3f), want (2.5, 1.5)", mu, mv)
+	}
+
+	img := FlowToColor(u, v, 0)
+	if !img.Bounds().Size().Eq(image.Pt(64, 64)) {
+		t.Fatalf("FlowToColor() size = %v", img.Bounds().Size())
+	}
+	still := FlowToColor(u, v, 1e9).RGBAAt(32, 32)
+	if still.R < 250 || still.G < 250 || still.B < 250 {
+		t.Errorf("FlowToColor() of a small motion = %v, want white", still)
+	}
+}
@@ -0,0 +1,280 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"math/cmplx"
+)
+
+// Spectrum is the 2D discrete Fourier transform of a W by H image, in
+// row-major order with the zero frequency first.
+type Spectrum struct {
+	Data []complex128
+	W, H int
+}
+
+// realFFT2 transforms the w by h real values. Pairs of rows are transformed
+// at once as the real and imaginary parts of a complex row, whose transform
+// splits into theirs by the Hermitian symmetry of real transforms.
+func realFFT2(values []float64, w, h int) []complex128 {
+	data := make([]complex128, w*h)
+	plan := newDFTPlan(w)
+	z := make([]complex128, w)
+	for y := 0; y < h; y += 2 {
+		for x := 0; x < w; x++ {
+			im := 0.
+			if y+1 < h {
+				im = values[(y+1)*w+x]
+			}
+			z[x] = complex(values[y*w+x], im)
+		}
+		plan.transform(z, false)
+		for k := 0; k < w; k++ {
+			zk, zc := z[k], cmplx.Conj(z[(w-k)%w])
+			data[y*w+k] = (zk + zc) / 2
+			if y+1 < h {
+				data[(y+1)*w+k] = (zk - zc) / 2i
+			}
+		}
+	}
+	cols := newDFTPlan(h)
+	col := make([]complex128, h)
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			col[y] = data[y*w+x]
+		}
+		cols.transform(col, false)
+		for y := 0; y < h; y++ {
+			data[y*w+x] = col[y]
+		}
+	}
+	return data
+}
+
+// FFT2 computes the 2D discrete Fourier transform of a single channel image,
+// or of the luma of a color image, of any size.
+func FFT2(f *Float64Image) *Spectrum {
+	if f == nil || f.Rect.Empty() {
+		return nil
+	}
+	w, h := f.Rect.Dx(), f.Rect.Dy()
+	return &Spectrum{Data: realFFT2(f.luma().channel(0), w, h), W: w, H: h}
+}
+
+// IFFT2 computes the inverse transform of the spectrum and returns its real
+// part as a single channel image at the origin. It returns nil if the
+// spectrum is nil or empty, or if its data do not hold W times H values.
+func IFFT2(s *Spectrum) *Float64Image {
+	if s == nil || s.W <= 0 || s.H <= 0 || len(s.Data) != s.W*s.H {
+		return nil
+	}
+	data := make([]complex128, len(s.Data))
+	copy(data, s.Data)
+	fft2(data, s.W, s.H, true)
+	out := NewFloat64Image(image.Rect(0, 0, s.W, s.H), 1)
+	for i, v := range data {
+		out.Pix[i] = real(v)
+	}
+	return out
+}
+
+// frequency returns the signed frequency in cycles per pixel of the index i
+// of a transform of length n.
+func frequency(i, n int) float64 {
+	if 2*i > n {
+		i -= n
+	}
+	return float64(i) / float64(n)
+}
+
+// centered calls f with the index of every frequency and returns its values
+// in the order of an image with the zero frequency at its center.
+func (s *Spectrum) centered(f func(i int) float64) []float64 {
+	values := make([]float64, s.W*s.H)
+	for y := 0; y < s.H; y++ {
+		for x := 0; x < s.W; x++ {
+			values[y*s.W+x] = f(((y+(s.H+1)/2)%s.H)*s.W + (x+(s.W+1)/2)%s.W)
+		}
+	}
+	return values
+}
+
+// levels maps the values from lo in black to hi in white, or to black if the
+// range is empty.
+func (s *Spectrum) levels(values []float64, lo, hi float64) *image.Gray {
+	gray := image.NewGray(image.Rect(0, 0, s.W, s.H))
+	for i, v := range values {
+		if hi > lo {
+			gray.Pix[i] = uint8(math.Floor(rescale(clamp(v, lo, hi), lo, hi, 0, 255) + 0.5))
+		}
+	}
+	return gray
+}
+
+// Magnitude returns the logarithm of one plus the magnitude of the spectrum,
+// stretched to the gray levels, with the zero frequency at the center.
+func (s *Spectrum) Magnitude() *image.Gray {
+	values := s.centered(func(i int) float64 { return math.Log1p(cmplx.Abs(s.Data[i])) })
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	return s.levels(values, lo, hi)
+}
+
+// Phase returns the phase of the spectrum, from -π in black to π in white,
+// with the zero frequency at the center.
+func (s *Spectrum) Phase() *image.Gray {
+	return s.levels(s.centered(func(i int) float64 { return cmplx.Phase(s.Data[i]) }), -math.Pi, math.Pi)
+}
+
+// Apply multiplies the spectrum by the filter.
+func (s *Spectrum) Apply(filter FrequencyFilter) {
+	for y := 0; y < s.H; y++ {
+		v := frequency(y, s.H)
+		for x := 0; x < s.W; x++ {
+			s.Data[y*s.W+x] *= complex(filter(frequency(x, s.W), v), 0)
+		}
+	}
+}
+
+// FrequencyFilter is the real gain of a filter at the horizontal and
+// vertical frequencies u and v, in cycles per pixel from -0.5 to 0.5.
+type FrequencyFilter func(u, v float64) float64
+
+// FilterShape is the profile of a frequency filter.
+type FilterShape int
+
+const (
+	// FilterIdeal cuts sharply at the cutoff, which rings in the image.
+	FilterIdeal FilterShape = iota
+	// FilterButterworth falls as 1/(1+(d/cutoff)^2n) for an order n.
+	FilterButterworth
+	// FilterGaussian falls as a gaussian of standard deviation cutoff.
+	FilterGaussian
+)
+
+// LowPass returns the low-pass filter of the given shape, passing
+// frequencies below the cutoff, in cycles per pixel. The order only applies
+// to Butterworth filters.
+func LowPass(shape FilterShape, cutoff float64, order int) FrequencyFilter {
+	return func(u, v float64) float64 {
+		d := math.Hypot(u, v)
+		switch shape {
+		case FilterButterworth:
+			return 1 / (1 + math.Pow(d/cutoff, 2*float64(order)))
+		case FilterGaussian:
+			return math.Exp(-d * d / (2 * cutoff * cutoff))
+		}
+		if d <= cutoff {
+			return 1
+		}
+		return 0
+	}
+}
+
+// HighPass returns the complement of the low-pass filter with the same
+// arguments.
+func HighPass(shape FilterShape, cutoff float64, order int) FrequencyFilter {
+	lp := LowPass(shape, cutoff, order)
+	return func(u, v float64) float64 { return 1 - lp(u, v) }
+}
+
+// BandPass returns the band-pass filter passing frequencies between low and
+// high, the product of a high-pass filter at low and a low-pass filter at
+// high.
+func BandPass(shape FilterShape, low, high float64, order int) FrequencyFilter {
+	hp, lp := HighPass(shape, low, order), LowPass(shape, high, order)
+	return func(u, v float64) float64 { return hp(u, v) * lp(u, v) }
+}
+
+// Notch returns the filter rejecting the frequencies within radius of each
+// center and of its symmetric, which removes periodic patterns whose peaks
+// appear at the centers of the spectrum.
+func Notch(shape FilterShape, centers []Point2D, radius float64, order int) FrequencyFilter {
+	hp := HighPass(shape, radius, order)
+	return func(u, v float64) float64 {
+		gain := 1.
+		for _, c := range centers {
+			gain *= hp(u-c.X, v-c.Y) * hp(u+c.X, v+c.Y)
+		}
+		return gain
+	}
+}
+
+// FilterFrequency applies the filter to each channel of the image in the
+// frequency domain and returns the filtered values, which are not clamped.
+func FilterFrequency(img image.Image, filter FrequencyFilter) *Float64Image {
+	f := ImageToFloat64(img)
+	if f == nil || f.Rect.Empty() {
+		return nil
+	}
+	w, h := f.Rect.Dx(), f.Rect.Dy()
+	out := NewFloat64Image(f.Rect, f.Channels)
+	for c := 0; c < f.Channels; c++ {
+		s := &Spectrum{Data: realFFT2(f.channel(c), w, h), W: w, H: h}
+		s.Apply(filter)
+		out.setChannel(c, IFFT2(s).Pix)
+	}
+	return out
+}
+
+// PhaseCorrelate estimates the translation of b relative to a, such that
+// b(x, y) ≈ a(x - shift.X, y - shift.Y), from the peak of the inverse
+// transform of their normalized cross-power spectrum, as described in
+// C. D. Kuglin and D. C. Hines, The phase correlation image alignment method,
+// Proceedings of the IEEE Conference on Cybernetics and Society (1975), pp. 163–165.
+//
+// Both images are weighted by a Hann window and the peak is refined to
+// subpixel accuracy with the centroid of its neighborhood. The response is
+// the height of the peak, near one for a pure translation. It returns false
+// if the images differ in size.
+func PhaseCorrelate(a, b *image.Gray) (shift Point2D, response float64, ok bool) {
+	w, h := a.Bounds().Dx(), a.Bounds().Dy()
+	if b.Bounds().Dx() != w || b.Bounds().Dy() != h || w == 0 || h == 0 {
+		return Point2D{}, 0, false
+	}
+	hann := func(i, n int) float64 {
+		if n < 2 {
+			return 1
+		}
+		return 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+	}
+	windowed := func(gray *image.Gray) []float64 {
+		values := grayPlane(gray).values
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				values[y*w+x] *= hann(x, w) * hann(y, h)
+			}
+		}
+		return values
+	}
+	fa, fb := realFFT2(windowed(a), w, h), realFFT2(windowed(b), w, h)
+	for i := range fa {
+		p := fb[i] * cmplx.Conj(fa[i])
+		if m := cmplx.Abs(p); m > 1e-12 {
+			fa[i] = p / complex(m, 0)
+		} else {
+			fa[i] = 0
+		}
+	}
+	fft2(fa, w, h, true)
+	peak := 0
+	for i := range fa {
+		if real(fa[i]) > real(fa[peak]) {
+			peak = i
+		}
+	}
+	px, py := peak%w, peak/w
+	var sx, sy, sum float64
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			v := math.Max(real(fa[modulo(py+dy, h)*w+modulo(px+dx, w)]), 0)
+			sx += v * float64(dx)
+			sy += v * float64(dy)
+			sum += v
+		}
+	}
+	shift = Point2D{frequency(px, w)*float64(w) + sx/sum, frequency(py, h)*float64(h) + sy/sum}
+	return shift, real(fa[peak]), true
+}
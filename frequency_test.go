@@ -0,0 +1,114 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+func TestFFT2(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	for _, size := range []image.Point{{8, 4}, {7, 5}, {12, 9}, {1, 3}} {
+		w, h := size.X, size.Y
+		f := NewFloat64Image(image.Rect(0, 0, w, h), 1)
+		for i := range f.Pix {
+			f.Pix[i] = rng.Float64() * 255
+		}
+		s := FFT2(f)
+		//Compare with the definition
+		for v := 0; v < h; v++ {
+			for u := 0; u < w; u++ {
+				var expected complex128
+				for y := 0; y < h; y++ {
+					for x := 0; x < w; x++ {
+						θ := -2 * math.Pi * (float64(u*x)/float64(w) + float64(v*y)/float64(h))
+						expected += complex(f.Pix[y*w+x], 0) * cmplx.Rect(1, θ)
+					}
+				}
+				if cmplx.Abs(s.Data[v*w+u]-expected) > 1e-8*float64(w*h)*255 {
+					t.Fatalf("%dx%d at (%d, %d): expected %v, got %v", w, h, u, v, expected, s.Data[v*w+u])
+				}
+			}
+		}
+		back := IFFT2(s)
+		for i, v := range f.Pix {
+			if math.Abs(back.Pix[i]-v) > 1e-9 {
+				t.Fatalf("%dx%d: inverse sample %d expected %v, got %v", w, h, i, v, back.Pix[i])
+			}
+		}
+	}
+	if IFFT2(FFT2(nil)) != nil || IFFT2(FFT2(NewFloat64Image(image.Rect(0, 0, 0, 3), 1))) != nil {
+		t.Errorf("the inverse of an empty transform should be nil")
+	}
+	if IFFT2(&Spectrum{Data: make([]complex128, 5), W: 2, H: 3}) != nil {
+		t.Errorf("a spectrum with missing data should give nil")
+	}
+}
+
+func TestFilterFrequency(t *testing.T) {
+	//A constant plus a fast vertical grating
+	w, h := 30, 20
+	gray := NewFloat64Image(image.Rect(0, 0, w, h), 1)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			gray.Pix[y*w+x] = 128 + 50*math.Cos(2*math.Pi*float64(x)/3)
+		}
+	}
+	for _, shape := range []FilterShape{FilterIdeal, FilterButterworth, FilterGaussian} {
+		low := FilterFrequency(gray, LowPass(shape, 0.05, 4))
+		high := FilterFrequency(gray, HighPass(shape, 0.05, 4))
+		notch := FilterFrequency(gray, Notch(shape, []Point2D{{1. / 3, 0}}, 0.03, 4))
+		for i := range low.Pix {
+			if math.Abs(low.Pix[i]-128) > 1 || math.Abs(notch.Pix[i]-128) > 1 {
+				t.Fatalf("shape %d: grating not removed at %d, got %v and %v", shape, i, low.Pix[i], notch.Pix[i])
+			}
+			if math.Abs(low.Pix[i]+high.Pix[i]-gray.Pix[i]) > 1e-6 {
+				t.Fatalf("shape %d: low and high pass do not add up at %d", shape, i)
+			}
+		}
+	}
+	band := FilterFrequency(gray, BandPass(FilterIdeal, 0.2, 0.4, 0))
+	if math.Abs(band.Pix[0]-50) > 1e-6 {
+		t.Errorf("band-pass should keep only the grating, got %v", band.Pix[0])
+	}
+
+	s := FFT2(gray)
+	if m := s.Magnitude(); m.GrayAt(w/2, h/2).Y != 255 {
+		t.Errorf("the zero frequency should be centered and brightest")
+	}
+	//An impulse at the origin has a uniform zero phase, halfway between -π
+	//and π
+	gray.Pix = make([]float64, w*h)
+	gray.Pix[0] = 1
+	for i, v := range FFT2(gray).Phase().Pix {
+		if v < 127 || v > 128 {
+			t.Fatalf("expected a zero phase in mid gray at %d, got %d", i, v)
+		}
+	}
+}
+
+func TestPhaseCorrelate(t *testing.T) {
+	w, h := 64, 48
+	a, b := image.NewGray(image.Rect(0, 0, w, h)), image.NewGray(image.Rect(0, 0, w, h))
+	texture := func(x, y float64) uint8 {
+		return uint8(128 + 40*math.Sin(0.31*x+0.17*y) + 30*math.Cos(0.23*x-0.41*y) + 20*math.Sin(0.53*y))
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			a.Pix[y*w+x] = texture(float64(x), float64(y))
+			b.Pix[y*w+x] = texture(float64(x)-5, float64(y)+3)
+		}
+	}
+	shift, response, ok := PhaseCorrelate(a, b)
+	if !ok || math.Abs(shift.X-5) > 0.3 || math.Abs(shift.Y+3) > 0.3 {
+		t.Errorf("expected shift (5, -3), got %v", shift)
+	}
+	if response < 0.2 {
+		t.Errorf("expected a clear peak, got %v", response)
+	}
+	if _, _, ok := PhaseCorrelate(a, image.NewGray(image.Rect(0, 0, 3, 3))); ok {
+		t.Errorf("images of different sizes should not correlate")
+	}
+}
@@ -15,3 +15,25 @@ func TestGaussian(t *testing.T) {
 	gaussian := Gaussian(gray, 1.4)
 	_ = imgio.Save("images/house-gaussian.png", gaussian, imgio.PNGEncoder())
 }
+
+func TestGaussianZeroSigma(t *testing.T) {
+	gray := image.NewGray(image.Rect(2, 1, 7, 5))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i * 13)
+	}
+	for _, σ := range []float64{0, -1} {
+		out := Gaussian(gray, σ)
+		if out == gray || out.Bounds() != gray.Bounds() {
+			t.Fatalf("σ %v: expected a copy with the same bounds", σ)
+		}
+		for i, v := range gray.Pix {
+			if out.Pix[i] != v {
+				t.Fatalf("σ %v: sample %d expected %d, got %d", σ, i, v, out.Pix[i])
+			}
+		}
+	}
+	_, levels := AdaptiveGaussian(gray, 0, 0)
+	if levels.GrayAt(2, 2) != gray.GrayAt(4, 3) {
+		t.Errorf("expected the pixel as its own mean, got %v", levels.GrayAt(2, 2))
+	}
+}
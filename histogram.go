@@ -0,0 +1,273 @@
+package vision
+
+import (
+	"image"
+	"math"
+)
+
+// Histogram counts the values of one channel of an image in Bins equal bins
+// covering [Min, Max).
+type Histogram struct {
+	Counts   []int
+	Min, Max float64
+}
+
+// HistogramOptions configures Histograms. The zero value gives 256 bins
+// covering [0, 256), one for each 8-bit level, over the whole image.
+type HistogramOptions struct {
+	// Bins is the number of bins, 256 if not positive.
+	Bins int
+	// Min and Max give the range of the bins, [0, 256) if they are equal.
+	// Values outside the range are not counted.
+	Min, Max float64
+	// Mask restricts the histogram to the pixels where it is not black. It
+	// must cover the bounds of the image.
+	Mask *image.Gray
+}
+
+// Bin returns the bin of a value, or -1 if it is outside the range.
+func (h *Histogram) Bin(v float64) int {
+	if !(v >= h.Min && v < h.Max) {
+		return -1
+	}
+	return min(int((v-h.Min)/(h.Max-h.Min)*float64(len(h.Counts))), len(h.Counts)-1)
+}
+
+// Total returns the number of counted values.
+func (h *Histogram) Total() int {
+	total := 0
+	for _, c := range h.Counts {
+		total += c
+	}
+	return total
+}
+
+// Cumulative returns the cumulative histogram, whose bin i counts the values
+// in the bins up to i.
+func (h *Histogram) Cumulative() []int {
+	cdf := make([]int, len(h.Counts))
+	sum := 0
+	for i, c := range h.Counts {
+		sum += c
+		cdf[i] = sum
+	}
+	return cdf
+}
+
+// Histograms returns the histogram of each channel of an image, with values
+// in the 0..255 range of ImageToFloat64. Gray images have a single channel
+// and other images four channels of alpha-premultiplied RGBA.
+func Histograms(img image.Image, opts HistogramOptions) []*Histogram {
+	f := ImageToFloat64(img)
+	if f == nil {
+		return nil
+	}
+	if opts.Bins <= 0 {
+		opts.Bins = 256
+	}
+	if opts.Min == opts.Max {
+		opts.Min, opts.Max = 0, 256
+	}
+	hs := make([]*Histogram, f.Channels)
+	for c := range hs {
+		hs[c] = &Histogram{Counts: make([]int, opts.Bins), Min: opts.Min, Max: opts.Max}
+	}
+	for y := f.Rect.Min.Y; y < f.Rect.Max.Y; y++ {
+		row := f.row(y)
+		for x := 0; x < f.Rect.Dx(); x++ {
+			if opts.Mask != nil && opts.Mask.GrayAt(f.Rect.Min.X+x, y).Y == 0 {
+				continue
+			}
+			for c, h := range hs {
+				if i := h.Bin(row[x*f.Channels+c]); i >= 0 {
+					h.Counts[i]++
+				}
+			}
+		}
+	}
+	return hs
+}
+
+// remap returns a copy of the image, placed at the origin, with every level
+// replaced through the lookup table.
+func remap(gray *image.Gray, lut [256]uint8) *image.Gray {
+	b := gray.Bounds()
+	out := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for i, v := range grayValues(gray) {
+		out.Pix[i] = lut[v]
+	}
+	return out
+}
+
+// equalization returns the lookup table that spreads the cumulative
+// histogram evenly over the 256 levels.
+func equalization(h [256]int) (lut [256]uint8) {
+	var cdf [256]int
+	sum := 0
+	for i, c := range h {
+		sum += c
+		cdf[i] = sum
+	}
+	first := 0
+	for _, c := range cdf {
+		if c > 0 {
+			first = c
+			break
+		}
+	}
+	if sum == first {
+		for i := range lut {
+			lut[i] = uint8(i)
+		}
+		return
+	}
+	for i, c := range cdf {
+		lut[i] = uint8(clamp(math.Floor(float64(c-first)/float64(sum-first)*255+0.5), 0, 255))
+	}
+	return
+}
+
+// Equalize spreads the levels of a grayscale image so its cumulative
+// histogram becomes approximately linear.
+func Equalize(gray *image.Gray) *image.Gray {
+	return remap(gray, equalization(histogram(gray)))
+}
+
+// Stretch linearly maps the levels of a grayscale image so the given
+// fractions of darkest and brightest pixels saturate to black and white. With
+// zero fractions the darkest and brightest levels become black and white.
+func Stretch(gray *image.Gray, low, high float64) *image.Gray {
+	h := histogram(gray)
+	total := 0
+	for _, c := range h {
+		total += c
+	}
+	lo, hi := 0, 255
+	for sum := 0; lo < 255; lo++ {
+		if sum += h[lo]; float64(sum) > low*float64(total) {
+			break
+		}
+	}
+	for sum := 0; hi > 0; hi-- {
+		if sum += h[hi]; float64(sum) > high*float64(total) {
+			break
+		}
+	}
+	var lut [256]uint8
+	for i := range lut {
+		if hi <= lo {
+			lut[i] = uint8(i)
+			continue
+		}
+		lut[i] = uint8(clamp(math.Floor(rescale(float64(i), float64(lo), float64(hi), 0, 255)+0.5), 0, 255))
+	}
+	return remap(gray, lut)
+}
+
+// MatchHistogram maps the levels of a grayscale image so its histogram
+// resembles that of the reference image. Each level is mapped to the
+// reference level whose cumulative frequency is the nearest above it.
+func MatchHistogram(gray, reference *image.Gray) *image.Gray {
+	cdf := func(h [256]int) (c [256]float64) {
+		sum := 0
+		for i, v := range h {
+			sum += v
+			c[i] = float64(sum)
+		}
+		for i := range c {
+			c[i] /= math.Max(float64(sum), 1)
+		}
+		return
+	}
+	src, ref := cdf(histogram(gray)), cdf(histogram(reference))
+	var lut [256]uint8
+	j := 0
+	for i := range lut {
+		for j < 255 && ref[j] < src[i]-1e-12 {
+			j++
+		}
+		lut[i] = uint8(j)
+	}
+	return remap(gray, lut)
+}
+
+// CLAHE applies contrast limited adaptive histogram equalization, as described in
+// K. Zuiderveld, Contrast limited adaptive histogram equalization,
+// Graphics Gems IV, Academic Press (1994), pp. 474–485.
+// https://doi.org/10.1016/B978-0-12-336156-1.50061-6
+//
+// The image is split into a grid of tiles.X by tiles.Y tiles, whose
+// histograms are clipped at clipLimit times their mean bin count, with the
+// excess spread over all bins, and equalized. Each pixel is mapped by
+// bilinearly interpolating the mappings of the four nearest tiles. A clip
+// limit that is not positive disables clipping.
+func CLAHE(gray *image.Gray, tiles image.Point, clipLimit float64) *image.Gray {
+	b := gray.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return image.NewGray(image.Rect(0, 0, w, h))
+	}
+	tiles = image.Pt(min(max(tiles.X, 1), w), min(max(tiles.Y, 1), h))
+	size := image.Rect(0, 0, (w+tiles.X-1)/tiles.X, (h+tiles.Y-1)/tiles.Y)
+	frames := stackFrames(image.Rect(0, 0, w, h), size)
+	//The frames may round to fewer tiles than requested
+	cols := (w + size.Dx() - 1) / size.Dx()
+	rows := len(frames) / cols
+	values := grayValues(gray)
+
+	luts := make([][256]uint8, len(frames))
+	cx, cy := make([]float64, cols), make([]float64, rows)
+	for i, frame := range frames {
+		var hist [256]int
+		for y := frame.Min.Y; y < frame.Max.Y; y++ {
+			for _, v := range values[y*w+frame.Min.X : y*w+frame.Max.X] {
+				hist[v]++
+			}
+		}
+		if clipLimit > 0 {
+			limit := max(int(clipLimit*float64(frame.Dx()*frame.Dy())/256), 1)
+			excess := 0
+			for k, c := range hist {
+				if c > limit {
+					excess += c - limit
+					hist[k] = limit
+				}
+			}
+			for k := range hist {
+				hist[k] += excess / 256
+				if k < excess%256 {
+					hist[k]++
+				}
+			}
+		}
+		luts[i] = equalization(hist)
+		cx[i%cols] = float64(frame.Min.X+frame.Max.X-1) / 2
+		cy[i/cols] = float64(frame.Min.Y+frame.Max.Y-1) / 2
+	}
+
+	//neighbors returns the two tile centers around a coordinate and the weight
+	//of the second one
+	neighbors := func(centers []float64, p float64) (int, int, float64) {
+		if p <= centers[0] {
+			return 0, 0, 0
+		}
+		for k := 1; k < len(centers); k++ {
+			if p < centers[k] {
+				return k - 1, k, (p - centers[k-1]) / (centers[k] - centers[k-1])
+			}
+		}
+		return len(centers) - 1, len(centers) - 1, 0
+	}
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		r0, r1, ty := neighbors(cy, float64(y))
+		for x := 0; x < w; x++ {
+			c0, c1, tx := neighbors(cx, float64(x))
+			v := values[y*w+x]
+			top := (1-tx)*float64(luts[r0*cols+c0][v]) + tx*float64(luts[r0*cols+c1][v])
+			bottom := (1-tx)*float64(luts[r1*cols+c0][v]) + tx*float64(luts[r1*cols+c1][v])
+			out.Pix[y*w+x] = uint8(math.Floor((1-ty)*top + ty*bottom + 0.5))
+		}
+	}
+	return out
+}
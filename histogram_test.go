@@ -0,0 +1,109 @@
+package vision
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// rampGray returns a w by h image whose levels grow from lo to hi along x.
+func rampGray(w, h int, lo, hi float64) *image.Gray {
+	gray := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			gray.Pix[y*w+x] = uint8(lo + (hi-lo)*float64(x)/float64(w-1))
+		}
+	}
+	return gray
+}
+
+func TestHistograms(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		img.SetRGBA(x, 0, color.RGBA{uint8(60 * x), 10, 200, 255})
+		img.SetRGBA(x, 1, color.RGBA{255, 10, 200, 255})
+	}
+	mask := image.NewGray(img.Bounds())
+	for x := 0; x < 4; x++ {
+		mask.SetGray(x, 0, color.Gray{255})
+	}
+	hs := Histograms(img, HistogramOptions{Bins: 4, Mask: mask})
+	if len(hs) != 4 {
+		t.Fatalf("expected 4 channels, got %d", len(hs))
+	}
+	for i, expected := range []int{2, 1, 1, 0} {
+		if hs[0].Counts[i] != expected {
+			t.Errorf("red bin %d: expected %d, got %d", i, expected, hs[0].Counts[i])
+		}
+	}
+	if cdf := hs[2].Cumulative(); cdf[2] != 0 || cdf[3] != 4 || hs[2].Total() != 4 {
+		t.Errorf("blue cumulative histogram: got %v", cdf)
+	}
+	if hs[0].Bin(256) != -1 || hs[0].Bin(255.9) != 3 {
+		t.Errorf("bins of values at the limits: got %d and %d", hs[0].Bin(256), hs[0].Bin(255.9))
+	}
+}
+
+func TestEqualize(t *testing.T) {
+	out := Equalize(rampGray(64, 4, 100, 163))
+	if out.Pix[0] != 0 || out.Pix[63] != 255 {
+		t.Errorf("expected full range, got %d to %d", out.Pix[0], out.Pix[63])
+	}
+	for x := 1; x < 64; x++ {
+		if math.Abs(float64(out.Pix[x])-float64(x)*255/63) > 1 {
+			t.Fatalf("level %d: expected linear mapping, got %d", x, out.Pix[x])
+		}
+	}
+
+	stretched := Stretch(rampGray(100, 1, 50, 149), 0.1, 0.1)
+	if stretched.Pix[5] != 0 || stretched.Pix[94] != 255 || stretched.Pix[50] < 120 || stretched.Pix[50] > 135 {
+		t.Errorf("stretch: got %d, %d and %d", stretched.Pix[5], stretched.Pix[50], stretched.Pix[94])
+	}
+
+	reference := rampGray(64, 4, 0, 63)
+	matched := MatchHistogram(rampGray(64, 4, 192, 255), reference)
+	for x := 0; x < 64; x++ {
+		if matched.Pix[x] != reference.Pix[x] {
+			t.Fatalf("match at %d: expected %d, got %d", x, reference.Pix[x], matched.Pix[x])
+		}
+	}
+}
+
+func TestCLAHE(t *testing.T) {
+	//Two halves of low contrast at different brightness
+	gray := image.NewGray(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			v := 40 + (x+y)%8
+			if x >= 32 {
+				v += 150
+			}
+			gray.Pix[y*64+x] = uint8(v)
+		}
+	}
+	spread := func(img *image.Gray, x0, x1 int) int {
+		lo, hi := 255, 0
+		for y := 16; y < 48; y++ {
+			for x := x0; x < x1; x++ {
+				v := int(img.Pix[y*64+x])
+				lo, hi = min(lo, v), max(hi, v)
+			}
+		}
+		return hi - lo
+	}
+	out := CLAHE(gray, image.Pt(4, 4), 0)
+	if spread(out, 4, 12) < 100 || spread(out, 52, 60) < 100 {
+		t.Errorf("adaptive equalization should stretch both halves, got %d and %d", spread(out, 4, 12), spread(out, 52, 60))
+	}
+	limited := CLAHE(gray, image.Pt(4, 4), 2)
+	if s := spread(limited, 4, 12); s >= spread(out, 4, 12) || s < 8 {
+		t.Errorf("clip limit should reduce the contrast gain, got %d", s)
+	}
+	if b := CLAHE(gray, image.Pt(100, 3), 2).Bounds(); b != gray.Bounds() {
+		t.Errorf("unexpected bounds %v", b)
+	}
+	if b := CLAHE(image.NewGray(image.Rect(0, 0, 0, 5)), image.Pt(4, 4), 2).Bounds(); !b.Empty() {
+		t.Errorf("expected an empty image, got %v", b)
+	}
+}
@@ -0,0 +1,211 @@
+package vision
+
+import (
+	"image"
+	"math"
+)
+
+// Mul returns the composition h·g, which applies g first and then h.
+func (h Homography) Mul(g Homography) Homography {
+	var p Homography
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			for k := 0; k < 3; k++ {
+				p[3*r+c] += h[3*r+k] * g[3*k+c]
+			}
+		}
+	}
+	return p
+}
+
+// normalization returns the similarity that moves the centroid of the points
+// to the origin and scales their mean distance to it to √2.
+func normalization(points []Point2D, indexes []int) Homography {
+	var mx, my float64
+	for _, i := range indexes {
+		mx += points[i].X
+		my += points[i].Y
+	}
+	n := float64(len(indexes))
+	mx /= n
+	my /= n
+	d := 0.
+	for _, i := range indexes {
+		d += math.Hypot(points[i].X-mx, points[i].Y-my)
+	}
+	k := 1.
+	if d > 0 {
+		k = math.Sqrt2 * n / d
+	}
+	return Homography{k, 0, -k * mx, 0, k, -k * my, 0, 0, 1}
+}
+
+// estimateHomography fits the homography mapping src[i] to dst[i] for the
+// given indexes with the normalized direct linear transform. The null vector
+// of the stacked constraints is the eigenvector of AᵀA with the smallest
+// eigenvalue.
+func estimateHomography(src, dst []Point2D, indexes []int) (Homography, bool) {
+	if len(indexes) < 4 {
+		return Homography{}, false
+	}
+	ts, td := normalization(src, indexes), normalization(dst, indexes)
+	ata := make([][]float64, 9)
+	for i := range ata {
+		ata[i] = make([]float64, 9)
+	}
+	for _, i := range indexes {
+		s, d := ts.Apply(src[i]), td.Apply(dst[i])
+		rows := [2][9]float64{
+			{-s.X, -s.Y, -1, 0, 0, 0, d.X * s.X, d.X * s.Y, d.X},
+			{0, 0, 0, -s.X, -s.Y, -1, d.Y * s.X, d.Y * s.Y, d.Y},
+		}
+		for _, row := range rows {
+			for r := range row {
+				for c := range row {
+					ata[r][c] += row[r] * row[c]
+				}
+			}
+		}
+	}
+	values, vectors := symmetricEigen(ata)
+	//A second null vector means the points are degenerate, such as three of
+	//them being collinear in a minimal sample
+	if values[1] <= 1e-12*values[8] {
+		return Homography{}, false
+	}
+	var hn Homography
+	copy(hn[:], vectors[0])
+	tdInv, _ := td.Invert()
+	h := tdInv.Mul(hn).Mul(ts)
+	if math.Abs(h[8]) < 1e-12 {
+		return Homography{}, false
+	}
+	for i := range h {
+		h[i] /= h[8]
+	}
+	return h, true
+}
+
+// EstimateHomography fits the homography mapping src[i] to dst[i] from four
+// or more correspondences with the normalized direct linear transform of
+// R. Hartley, In defense of the eight-point algorithm,
+// IEEE Transactions on Pattern Analysis and Machine Intelligence, 19 (1997), pp. 580–593.
+// https://doi.org/10.1109/34.601246
+//
+// It returns false if there are too few or degenerate correspondences.
+func EstimateHomography(src, dst []Point2D) (Homography, bool) {
+	if len(src) != len(dst) {
+		return Homography{}, false
+	}
+	indexes := make([]int, len(src))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return estimateHomography(src, dst, indexes)
+}
+
+// EstimateHomographyRANSAC robustly fits the homography mapping src[i] to
+// dst[i] with RANSAC, where opts.Threshold is the maximum transfer error of
+// an inlier in pixels. It returns the homography refitted to the inliers and
+// the inlier mask, or false if no homography could be fitted.
+func EstimateHomographyRANSAC(src, dst []Point2D, opts RANSACOptions) (Homography, []bool, bool) {
+	if len(src) != len(dst) {
+		return Homography{}, nil, false
+	}
+	result := RANSAC(&HomographyModel{Src: src, Dst: dst}, opts)
+	if result == nil {
+		return Homography{}, nil, false
+	}
+	var h Homography
+	copy(h[:], result.Params)
+	return h, result.Inliers, true
+}
+
+// Decompose factors the homography as H = S·A·P into a similarity S, an
+// affine transform A with unit determinant and no rotation and a pure
+// projective transform P, as described in
+// R. Hartley and A. Zisserman, Multiple View Geometry in Computer Vision,
+// Cambridge University Press (2004), section 2.4.6.
+//
+// When H maps a plane to its image, warping the image with H⁻¹ restores the
+// plane up to scale, which is what Rectify does, while warping it with
+// S·A·H⁻¹ only removes the projective part P and restores parallelism. It
+// returns false if the homography maps the origin to infinity or reverses
+// orientation.
+func (h Homography) Decompose() (s, a, p Homography, ok bool) {
+	w := h[8]
+	if w == 0 {
+		return
+	}
+	tx, ty := h[2]/w, h[5]/w
+	//B = sRK = M - t vᵀ, factored with a QR decomposition
+	b00, b01 := h[0]-tx*h[6], h[1]-tx*h[7]
+	b10, b11 := h[3]-ty*h[6], h[4]-ty*h[7]
+	det := b00*b11 - b01*b10
+	r := math.Hypot(b00, b10)
+	if det <= 0 || r == 0 {
+		return
+	}
+	c, sn := b00/r, b10/r
+	u00, u01, u11 := r, (b00*b01+b10*b11)/r, det/r
+	scale := math.Sqrt(det)
+	s = Homography{scale * c, -scale * sn, tx, scale * sn, scale * c, ty, 0, 0, 1}
+	a = Homography{u00 / scale, u01 / scale, 0, 0, u11 / scale, 0, 0, 0, 1}
+	p = Homography{1, 0, 0, 0, 1, 0, h[6], h[7], w}
+	return s, a, p, true
+}
+
+// OrderCorners sorts four corners of a convex quadrilateral as top left, top
+// right, bottom right and bottom left, that is, clockwise on the image
+// starting from the corner nearest to the image origin.
+func OrderCorners(corners [4]Point2D) [4]Point2D {
+	var cx, cy float64
+	for _, p := range corners {
+		cx += p.X / 4
+		cy += p.Y / 4
+	}
+	//Sort by angle about the centroid, which is clockwise on the image
+	angle := func(p Point2D) float64 { return math.Atan2(p.Y-cy, p.X-cx) }
+	sorted := corners
+	for i := 1; i < 4; i++ {
+		for j := i; j > 0 && angle(sorted[j]) < angle(sorted[j-1]); j-- {
+			sorted[j], sorted[j-1] = sorted[j-1], sorted[j]
+		}
+	}
+	first := 0
+	for i := range sorted {
+		if sorted[i].X+sorted[i].Y < sorted[first].X+sorted[first].Y {
+			first = i
+		}
+	}
+	var ordered [4]Point2D
+	for i := range ordered {
+		ordered[i] = sorted[(first+i)%4]
+	}
+	return ordered
+}
+
+// Rectify returns the fronto-parallel w-by-h view of the quadrilateral with
+// the given corners, which may be in any order. If w or h are not positive
+// they are taken from the longest opposite sides of the quadrilateral. The
+// output follows the conventions of WarpPerspective and is nil if the
+// corners are degenerate.
+func Rectify(img image.Image, corners [4]Point2D, w, h int, opts WarpOptions) image.Image {
+	c := OrderCorners(corners)
+	dist := func(p, q Point2D) float64 { return math.Hypot(p.X-q.X, p.Y-q.Y) }
+	if w <= 0 {
+		w = int(math.Floor(math.Max(dist(c[0], c[1]), dist(c[3], c[2])) + 0.5))
+	}
+	if h <= 0 {
+		h = int(math.Floor(math.Max(dist(c[0], c[3]), dist(c[1], c[2])) + 0.5))
+	}
+	if w <= 0 || h <= 0 {
+		return nil
+	}
+	target := []Point2D{{0, 0}, {float64(w - 1), 0}, {float64(w - 1), float64(h - 1)}, {0, float64(h - 1)}}
+	H, ok := EstimateHomography(c[:], target)
+	if !ok {
+		return nil
+	}
+	return WarpPerspective(img, H, image.Pt(w, h), opts)
+}
@@ -0,0 +1,133 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// testHomography is a perspective view of a plane used across the tests.
+var testHomography = Homography{0.8, 0.15, 12, -0.05, 0.9, 8, 0.0008, 0.0004, 1}
+
+func TestEstimateHomography(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var src, dst []Point2D
+	for i := 0; i < 40; i++ {
+		p := Point2D{rnd.Float64() * 300, rnd.Float64() * 200}
+		q := testHomography.Apply(p)
+		q.X += rnd.NormFloat64() * 0.1
+		q.Y += rnd.NormFloat64() * 0.1
+		src, dst = append(src, p), append(dst, q)
+	}
+	h, ok := EstimateHomography(src, dst)
+	if !ok {
+		t.Fatal("EstimateHomography() failed")
+	}
+	for i, p := range src {
+		q, r := h.Apply(p), testHomography.Apply(p)
+		if d := math.Hypot(q.X-r.X, q.Y-r.Y); d > 0.3 {
+			t.Errorf("point %d transferred %g pixels away", i, d)
+		}
+	}
+
+	//Outliers break the plain estimate but not the robust one
+	for i := 0; i < 40; i += 3 {
+		dst[i].X += 20 + rnd.Float64()*50
+	}
+	h, inliers, ok := EstimateHomographyRANSAC(src, dst, RANSACOptions{Method: MethodLORANSAC, Threshold: 1, Seed: 1})
+	if !ok {
+		t.Fatal("EstimateHomographyRANSAC() failed")
+	}
+	for i, p := range src {
+		if inliers[i] != (i%3 != 0) {
+			t.Errorf("point %d inlier = %v", i, inliers[i])
+		}
+		q, r := h.Apply(p), testHomography.Apply(p)
+		if d := math.Hypot(q.X-r.X, q.Y-r.Y); d > 0.3 {
+			t.Errorf("point %d transferred %g pixels away", i, d)
+		}
+	}
+
+	collinear := []Point2D{{0, 0}, {1, 1}, {2, 2}, {3, 3}}
+	if _, ok := EstimateHomography(collinear, collinear); ok {
+		t.Errorf("EstimateHomography() with collinear points succeeded")
+	}
+}
+
+func TestHomography_Decompose(t *testing.T) {
+	s, a, p, ok := testHomography.Decompose()
+	if !ok {
+		t.Fatal("Decompose() failed")
+	}
+	product := s.Mul(a).Mul(p)
+	for i := range product {
+		if math.Abs(product[i]-testHomography[i]) > 1e-12 {
+			t.Fatalf("S·A·P = %v, want %v", product, testHomography)
+		}
+	}
+	if det := a[0]*a[4] - a[1]*a[3]; math.Abs(det-1) > 1e-12 || a[3] != 0 {
+		t.Errorf("A = %v is not upper triangular with unit determinant", a)
+	}
+	if math.Abs(s[0]-s[4]) > 1e-12 || math.Abs(s[1]+s[3]) > 1e-12 {
+		t.Errorf("S = %v is not a similarity", s)
+	}
+}
+
+func TestRectify(t *testing.T) {
+	//Render a 40x30 checkerboard of 10 pixel squares in perspective
+	plane := image.NewGray(image.Rect(0, 0, 40, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			if (x/10+y/10)%2 == 0 {
+				plane.Pix[y*40+x] = 255
+			}
+		}
+	}
+	h := Homography{1.5, 0.3, 20, -0.1, 1.6, 15, 0.004, 0.002, 1}
+	photo := WarpPerspective(plane, h, image.Pt(120, 100), WarpOptions{Interpolation: InterpolationBilinear})
+	corners := [4]Point2D{
+		h.Apply(Point2D{39, 29}), h.Apply(Point2D{0, 0}),
+		h.Apply(Point2D{0, 29}), h.Apply(Point2D{39, 0}),
+	}
+	ordered := OrderCorners(corners)
+	if ordered[0] != corners[1] || ordered[1] != corners[3] || ordered[2] != corners[0] || ordered[3] != corners[2] {
+		t.Errorf("OrderCorners() = %v", ordered)
+	}
+	out := Rectify(photo, corners, 40, 30, WarpOptions{Interpolation: InterpolationBilinear}).(*image.Gray)
+	wrong := 0
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			if x%10 == 0 || x%10 == 9 || y%10 == 0 || y%10 == 9 {
+				continue
+			}
+			if d := int(out.Pix[y*40+x]) - int(plane.Pix[y*40+x]); d < -64 || d > 64 {
+				wrong++
+			}
+		}
+	}
+	if wrong > 0 {
+		t.Errorf("Rectify() differs from the plane at %d pixels", wrong)
+	}
+	if out := Rectify(photo, corners, 0, 0, WarpOptions{}); out.Bounds().Dx() < 40 {
+		t.Errorf("Rectify() automatic size = %v", out.Bounds())
+	}
+}
+
+func TestHoughSpace_Lines(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 40, 40))
+	for y := 0; y < 40; y++ {
+		gray.Pix[y*40+10] = 255
+	}
+	lines := NewHoughSpace(gray, 181, 200).Lines()
+	if l := lines[0]; math.Abs(l[0]-1) > 1e-9 || math.Abs(l[1]) > 1e-9 || math.Abs(l[2]+10) > 0.5 {
+		t.Errorf("strongest line = %v, want x = 10", l)
+	}
+	p, ok := Line{1, 0, -10}.Intersect(Line{0, 1, -20})
+	if !ok || p != (Point2D{10, 20}) {
+		t.Errorf("Intersect() = %v, %v, want (10, 20)", p, ok)
+	}
+	if _, ok := (Line{1, 0, -10}).Intersect(Line{1, 0, 5}); ok {
+		t.Errorf("Intersect() of parallel lines succeeded")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"image/color"
 	"image/draw"
 	"math"
+	"sort"
 
 	"github.com/fogleman/gg"
 )
@@ -233,3 +234,66 @@ func (h *HoughSpace) PlotLines() *image.Gray {
 	draw.Draw(gray, b, img, image.ZP, draw.Src)
 	return gray
 }
+
+// Line is the line A[0] x + A[1] y + A[2] = 0 with A[0]² + A[1]² = 1, the
+// same layout as the params of LineModel.
+type Line [3]float64
+
+// Intersect returns the intersection of two lines, or false if they are
+// parallel.
+func (l Line) Intersect(m Line) (Point2D, bool) {
+	det := l[0]*m[1] - l[1]*m[0]
+	if math.Abs(det) < 1e-12 {
+		return Point2D{}, false
+	}
+	return Point2D{(l[1]*m[2] - l[2]*m[1]) / det, (l[2]*m[0] - l[0]*m[2]) / det}, true
+}
+
+// Line returns the spatial line of a Hough point, relative to the origin of
+// the spatial bounds.
+func (h *HoughSpace) Line(hp *HoughPoint) Line {
+	b := h.SpatialBounds
+	rhoMax := math.Hypot(float64(b.Dx()), float64(b.Dy()))
+	drho := rhoMax / float64(h.RhoRes/2)
+	theta := rescale(float64(hp.Indexes[0]), 0, float64(h.ThetaRes-1), -math.Pi/2, math.Pi/2)
+	rho := float64(h.RhoRes/2-hp.Indexes[1]) * drho
+	return Line{math.Cos(theta), math.Sin(theta), -rho}
+}
+
+// Lines returns the spatial lines of every Hough point, sorted by
+// decreasing score, usually after FindCentroids.
+func (h *HoughSpace) Lines() []Line {
+	points := make([]*HoughPoint, 0, len(h.points))
+	for _, p := range h.points {
+		points = append(points, p)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Score != points[j].Score {
+			return points[i].Score > points[j].Score
+		}
+		return GenerateKey(points[i].Indexes[0], points[i].Indexes[1]) < GenerateKey(points[j].Indexes[0], points[j].Indexes[1])
+	})
+	lines := make([]Line, len(points))
+	for i, p := range points {
+		lines[i] = h.Line(p)
+	}
+	return lines
+}
+
+// Intersections returns the intersections of every pair of lines that lie
+// inside the spatial bounds, which for the four strongest lines of a
+// document are its corners.
+func (h *HoughSpace) Intersections(lines []Line) []Point2D {
+	b := h.SpatialBounds
+	var points []Point2D
+	for i := range lines {
+		for j := i + 1; j < len(lines); j++ {
+			p, ok := lines[i].Intersect(lines[j])
+			if !ok || p.X < 0 || p.Y < 0 || p.X > float64(b.Dx()-1) || p.Y > float64(b.Dy()-1) {
+				continue
+			}
+			points = append(points, p)
+		}
+	}
+	return points
+}
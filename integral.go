@@ -0,0 +1,178 @@
+package vision
+
+import (
+	"image"
+	"math"
+)
+
+// IntegralImage stores the summed-area tables of a grayscale image, which
+// give the sum, the squared sum and the sum over 45 degrees rotated
+// rectangles of any region in constant time.
+//
+// The tilted table follows
+// R. Lienhart and J. Maydt, An extended set of Haar-like features for rapid object detection,
+// Proceedings of the International Conference on Image Processing, 1 (2002), pp. 900–903.
+// https://doi.org/10.1109/ICIP.2002.1038171
+type IntegralImage struct {
+	// Rect is the region covered by the integral image. Sums are clipped
+	// to it.
+	Rect image.Rectangle
+
+	// origin is the image point corresponding to the first table entry and
+	// size is the size of the image the tables were computed from. Both are
+	// shared by every sub integral image.
+	origin image.Point
+	size   image.Point
+	sum    []float64
+	sqsum  []float64
+	tilted []float64
+}
+
+// NewIntegralImage computes the summed-area tables of a grayscale image.
+func NewIntegralImage(gray *image.Gray) *IntegralImage {
+	b := gray.Bounds()
+	w, h := b.Dx(), b.Dy()
+	values := grayValues(gray)
+	ii := &IntegralImage{
+		Rect:   b,
+		origin: b.Min,
+		size:   image.Pt(w, h),
+		sum:    make([]float64, (w+1)*(h+1)),
+		sqsum:  make([]float64, (w+1)*(h+1)),
+		tilted: make([]float64, (w+2)*(h+1)),
+	}
+	for y := 0; y < h; y++ {
+		var rs, rq float64
+		for x := 0; x < w; x++ {
+			v := float64(values[y*w+x])
+			rs += v
+			rq += v * v
+			ii.sum[(y+1)*(w+1)+x+1] = ii.sum[y*(w+1)+x+1] + rs
+			ii.sqsum[(y+1)*(w+1)+x+1] = ii.sqsum[y*(w+1)+x+1] + rq
+		}
+	}
+
+	//The tilted table at (x, y) sums the triangle of pixels above (x, y)
+	//bounded by the two diagonals through it. Triangles whose apex lies
+	//outside the image still cover some of its pixels, so each row is
+	//computed over an extended width and only columns -1 to w are kept
+	pad := h + 1
+	e := w + 2*pad
+	rows := [3][]float64{make([]float64, e), make([]float64, e), make([]float64, e)}
+	at := func(x, y int) float64 {
+		if x < 0 || x >= w || y < 0 || y >= h {
+			return 0
+		}
+		return float64(values[y*w+x])
+	}
+	for y := 0; y < h; y++ {
+		r2, r1, r0 := rows[0], rows[1], rows[2]
+		for i := 0; i < e; i++ {
+			x := i - pad
+			v := at(x, y) + at(x, y-1) - r2[i]
+			if i > 0 {
+				v += r1[i-1]
+			}
+			if i < e-1 {
+				v += r1[i+1]
+			}
+			r0[i] = v
+		}
+		copy(ii.tilted[(y+1)*(w+2):(y+2)*(w+2)], r0[pad-1:pad+w+1])
+		rows[0], rows[1], rows[2] = r1, r0, r2
+	}
+	return ii
+}
+
+// Bounds returns the region covered by the integral image.
+func (ii *IntegralImage) Bounds() image.Rectangle {
+	return ii.Rect
+}
+
+// lookup returns the entry of a summed-area table at the image point p.
+func (ii *IntegralImage) lookup(table []float64, p image.Point) float64 {
+	p = p.Sub(ii.origin)
+	return table[p.Y*(ii.size.X+1)+p.X]
+}
+
+// rectSum returns the sum of a table over r clipped to the integral image.
+func (ii *IntegralImage) rectSum(table []float64, r image.Rectangle) float64 {
+	r = r.Intersect(ii.Rect)
+	if r.Empty() {
+		return 0
+	}
+	return ii.lookup(table, r.Max) - ii.lookup(table, image.Pt(r.Max.X, r.Min.Y)) -
+		ii.lookup(table, image.Pt(r.Min.X, r.Max.Y)) + ii.lookup(table, r.Min)
+}
+
+// Sum returns the sum of the pixels in r.
+func (ii *IntegralImage) Sum(r image.Rectangle) float64 {
+	return ii.rectSum(ii.sum, r)
+}
+
+// SqSum returns the sum of the squared pixels in r.
+func (ii *IntegralImage) SqSum(r image.Rectangle) float64 {
+	return ii.rectSum(ii.sqsum, r)
+}
+
+// Mean returns the mean of the pixels in r, or zero if r is empty.
+func (ii *IntegralImage) Mean(r image.Rectangle) float64 {
+	n := r.Intersect(ii.Rect).Size()
+	if n.X*n.Y == 0 {
+		return 0
+	}
+	return ii.Sum(r) / float64(n.X*n.Y)
+}
+
+// Variance returns the variance of the pixels in r, or zero if r is empty.
+func (ii *IntegralImage) Variance(r image.Rectangle) float64 {
+	n := r.Intersect(ii.Rect).Size()
+	if n.X*n.Y == 0 {
+		return 0
+	}
+	mean := ii.Sum(r) / float64(n.X*n.Y)
+	return math.Max(ii.SqSum(r)/float64(n.X*n.Y)-mean*mean, 0)
+}
+
+// tiltedAt returns the tilted table entry whose apex is the image point
+// (x, y). Entries above the image are zero.
+func (ii *IntegralImage) tiltedAt(x, y int) float64 {
+	x, y = x-ii.origin.X, y-ii.origin.Y
+	if y < 0 {
+		return 0
+	}
+	x = min(max(x, -1), ii.size.X)
+	y = min(y, ii.size.Y-1)
+	return ii.tilted[(y+1)*(ii.size.X+2)+x+1]
+}
+
+// TiltedSum returns the sum of the pixels of the 45 degrees rotated
+// rectangle whose top corner is the pixel (x, y) and that spans w pixels
+// along the down-right diagonal and h pixels along the down-left diagonal.
+// The rectangle must lie inside the image the tables were computed from.
+func (ii *IntegralImage) TiltedSum(x, y, w, h int) float64 {
+	if w <= 0 || h <= 0 {
+		return 0
+	}
+	return ii.tiltedAt(x-h+w, y+w+h-1) + ii.tiltedAt(x, y-1) -
+		ii.tiltedAt(x-h, y+h-1) - ii.tiltedAt(x+w, y+w-1)
+}
+
+// SubImage returns an integral image restricted to r that shares the tables
+// of ii, so sums over the sub image cost no extra computation.
+func (ii *IntegralImage) SubImage(r image.Rectangle) *IntegralImage {
+	sub := *ii
+	sub.Rect = r.Intersect(ii.Rect)
+	return &sub
+}
+
+// Stack splits the integral image into the same tiles as the Stack function
+// does for an image, each of them sharing the tables of ii.
+func (ii *IntegralImage) Stack(R image.Rectangle) (O []*IntegralImage) {
+	frames := stackFrames(ii.Rect, R)
+	O = make([]*IntegralImage, len(frames))
+	for i, frame := range frames {
+		O[i] = ii.SubImage(frame)
+	}
+	return
+}
@@ -0,0 +1,89 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func randomGray(w, h int) *image.Gray {
+	gray := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8((i*37 + i*i*11) % 251)
+	}
+	return gray
+}
+
+func TestIntegralImage(t *testing.T) {
+	gray := randomGray(17, 13)
+	ii := NewIntegralImage(gray)
+	rects := []image.Rectangle{
+		image.Rect(0, 0, 17, 13),
+		image.Rect(3, 2, 9, 11),
+		image.Rect(-4, -4, 2, 3),
+		image.Rect(16, 12, 20, 20),
+	}
+	for _, r := range rects {
+		var sum, sqsum float64
+		c := r.Intersect(gray.Bounds())
+		for y := c.Min.Y; y < c.Max.Y; y++ {
+			for x := c.Min.X; x < c.Max.X; x++ {
+				v := float64(gray.GrayAt(x, y).Y)
+				sum += v
+				sqsum += v * v
+			}
+		}
+		if got := ii.Sum(r); got != sum {
+			t.Errorf("Sum(%v) = %v, want %v", r, got, sum)
+		}
+		if got := ii.SqSum(r); got != sqsum {
+			t.Errorf("SqSum(%v) = %v, want %v", r, got, sqsum)
+		}
+	}
+	n := float64(6 * 9)
+	r := image.Rect(3, 2, 9, 11)
+	mean := ii.Sum(r) / n
+	if got, want := ii.Variance(r), ii.SqSum(r)/n-mean*mean; math.Abs(got-want) > 1e-9 {
+		t.Errorf("Variance() = %v, want %v", got, want)
+	}
+}
+
+func TestIntegralImage_TiltedSum(t *testing.T) {
+	gray := randomGray(20, 16)
+	ii := NewIntegralImage(gray)
+	cases := [][4]int{{10, 3, 3, 2}, {5, 0, 1, 1}, {8, 1, 6, 4}, {2, 2, 2, 2}}
+	for _, c := range cases {
+		x, y, w, h := c[0], c[1], c[2], c[3]
+		//Rotated rectangle pixels in diagonal coordinates
+		want := 0.
+		for i := 0; i < w; i++ {
+			for j := 0; j < h; j++ {
+				//Each step along the diagonals covers two pixels of the digital rectangle
+				p := image.Pt(x+i-j, y+i+j)
+				want += float64(gray.GrayAt(p.X, p.Y).Y)
+				q := image.Pt(x+i-j, y+i+j+1)
+				want += float64(gray.GrayAt(q.X, q.Y).Y)
+			}
+		}
+		if got := ii.TiltedSum(x, y, w, h); got != want {
+			t.Errorf("TiltedSum(%v) = %v, want %v", c, got, want)
+		}
+	}
+}
+
+func TestIntegralImage_Stack(t *testing.T) {
+	gray := randomGray(10, 7)
+	ii := NewIntegralImage(gray)
+	tiles := ii.Stack(image.Rect(0, 0, 4, 4))
+	if len(tiles) != 6 {
+		t.Fatalf("Stack() returned %d tiles, want 6", len(tiles))
+	}
+	for _, tile := range tiles {
+		if tile.Bounds().Dx() != 4 || tile.Bounds().Dy() != 4 {
+			t.Errorf("tile %v has the wrong size", tile.Bounds())
+		}
+		if got, want := tile.Sum(image.Rect(-10, -10, 20, 20)), NewIntegralImage(gray.SubImage(tile.Bounds()).(*image.Gray)).Sum(tile.Bounds()); got != want {
+			t.Errorf("tile %v sum = %v, want %v", tile.Bounds(), got, want)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package kernel
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/joaowiciuk/matrix"
+)
+
+// Format is a kernel file format.
+type Format int
+
+const (
+	// FormatCSV holds one row of comma separated weights per line. Lines
+	// starting with # are comments.
+	FormatCSV Format = iota
+	// FormatJSON holds an object with the weights as an array of rows, an
+	// optional anchor {"x": column, "y": row} and an optional normalize flag
+	// dividing the weights by their sum.
+	FormatJSON
+	// FormatText holds one row of whitespace separated weights per line,
+	// optionally preceded by the directives "anchor <column> <row>" and
+	// "normalize". Text after # is a comment.
+	FormatText
+)
+
+var (
+	// ErrEmpty reports a kernel without weights.
+	ErrEmpty = errors.New("kernel: no weights")
+	// ErrRagged reports rows of different lengths.
+	ErrRagged = errors.New("kernel: rows of different lengths")
+	// ErrAnchor reports an anchor outside the weights.
+	ErrAnchor = errors.New("kernel: anchor outside the weights")
+	// ErrNormalize reports normalizing weights of zero sum.
+	ErrNormalize = errors.New("kernel: cannot normalize weights of zero sum")
+	// ErrFormat reports an unknown file format.
+	ErrFormat = errors.New("kernel: unknown format")
+)
+
+// FormatOf returns the format of the file from its extension, .csv, .json,
+// .txt or .kernel.
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".json":
+		return FormatJSON, nil
+	case ".txt", ".kernel":
+		return FormatText, nil
+	}
+	return 0, fmt.Errorf("%w: %q", ErrFormat, path)
+}
+
+// jsonKernel is the layout of FormatJSON.
+type jsonKernel struct {
+	Weights   [][]float64 `json:"weights"`
+	Anchor    *jsonAnchor `json:"anchor,omitempty"`
+	Normalize bool        `json:"normalize,omitempty"`
+}
+
+type jsonAnchor struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// build validates the weights and returns them as a kernel, anchored at the
+// given point or at their center if it is nil, and normalized if asked.
+func build(rows [][]float64, anchor *image.Point, normalize bool) (*Kernel, error) {
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return nil, ErrEmpty
+	}
+	weights := matrix.New(len(rows), len(rows[0]))
+	sum := 0.
+	for r, row := range rows {
+		if len(row) != len(rows[0]) {
+			return nil, fmt.Errorf("%w: row %d has %d weights, expected %d", ErrRagged, r+1, len(row), len(rows[0]))
+		}
+		for c, v := range row {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("kernel: row %d column %d is not finite", r+1, c+1)
+			}
+			(*weights)[r][c] = v
+			sum += v
+		}
+	}
+	if normalize {
+		if math.Abs(sum) < 1e-12 {
+			return nil, ErrNormalize
+		}
+		for r := range *weights {
+			for c := range (*weights)[r] {
+				(*weights)[r][c] /= sum
+			}
+		}
+	}
+	k := New(weights)
+	if anchor != nil {
+		if !anchor.In(image.Rect(0, 0, len(rows[0]), len(rows))) {
+			return nil, fmt.Errorf("%w: %v in %dx%d", ErrAnchor, *anchor, len(rows[0]), len(rows))
+		}
+		k.Anchor = *anchor
+	}
+	return k, nil
+}
+
+// parseRow parses the weights of a row, numbered from one in errors.
+func parseRow(fields []string, n int) ([]float64, error) {
+	row := make([]float64, len(fields))
+	for i, field := range fields {
+		v, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("kernel: row %d column %d: %w", n, i+1, err)
+		}
+		row[i] = v
+	}
+	return row, nil
+}
+
+// Read reads a kernel in the given format.
+func Read(r io.Reader, format Format) (*Kernel, error) {
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.Comment = '#'
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		var rows [][]float64
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("kernel: %w", err)
+			}
+			row, err := parseRow(record, len(rows)+1)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, row)
+		}
+		return build(rows, nil, false)
+	case FormatJSON:
+		var data jsonKernel
+		decoder := json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&data); err != nil {
+			return nil, fmt.Errorf("kernel: %w", err)
+		}
+		var anchor *image.Point
+		if data.Anchor != nil {
+			anchor = &image.Point{data.Anchor.X, data.Anchor.Y}
+		}
+		return build(data.Weights, anchor, data.Normalize)
+	case FormatText:
+		var rows [][]float64
+		var anchor *image.Point
+		normalize := false
+		scanner := bufio.NewScanner(r)
+		for line := 1; scanner.Scan(); line++ {
+			text := scanner.Text()
+			if i := strings.IndexByte(text, '#'); i >= 0 {
+				text = text[:i]
+			}
+			fields := strings.Fields(text)
+			if len(fields) == 0 {
+				continue
+			}
+			switch fields[0] {
+			case "anchor":
+				if len(fields) != 3 || rows != nil {
+					return nil, fmt.Errorf("kernel: line %d: expected \"anchor <column> <row>\" before the weights", line)
+				}
+				x, errX := strconv.Atoi(fields[1])
+				y, errY := strconv.Atoi(fields[2])
+				if errX != nil || errY != nil {
+					return nil, fmt.Errorf("kernel: line %d: invalid anchor", line)
+				}
+				anchor = &image.Point{x, y}
+			case "normalize":
+				if len(fields) != 1 || rows != nil {
+					return nil, fmt.Errorf("kernel: line %d: expected \"normalize\" before the weights", line)
+				}
+				normalize = true
+			default:
+				row, err := parseRow(fields, len(rows)+1)
+				if err != nil {
+					return nil, err
+				}
+				rows = append(rows, row)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("kernel: %w", err)
+		}
+		return build(rows, anchor, normalize)
+	}
+	return nil, ErrFormat
+}
+
+// Write writes the kernel in the given format. Only FormatJSON and
+// FormatText keep an anchor other than the center.
+func Write(w io.Writer, k *Kernel, format Format) error {
+	if k == nil || k.Weights == nil || len(*k.Weights) == 0 {
+		return ErrEmpty
+	}
+	weights := *k.Weights
+	format64 := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		for _, row := range weights {
+			record := make([]string, len(row))
+			for i, v := range row {
+				record[i] = format64(v)
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "\t")
+		return encoder.Encode(jsonKernel{Weights: weights, Anchor: &jsonAnchor{k.Anchor.X, k.Anchor.Y}})
+	case FormatText:
+		buffer := bufio.NewWriter(w)
+		fmt.Fprintf(buffer, "anchor %d %d\n", k.Anchor.X, k.Anchor.Y)
+		for _, row := range weights {
+			for i, v := range row {
+				if i > 0 {
+					buffer.WriteByte(' ')
+				}
+				buffer.WriteString(format64(v))
+			}
+			buffer.WriteByte('\n')
+		}
+		return buffer.Flush()
+	}
+	return ErrFormat
+}
+
+// Load reads the kernel in the file, in the format given by its extension.
+func Load(path string) (*Kernel, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	k, err := Read(f, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return k, nil
+}
+
+// Save writes the kernel to the file, in the format given by its extension.
+func Save(path string, k *Kernel) error {
+	format, err := FormatOf(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := Write(f, k, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,113 @@
+package kernel
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	cases := []struct {
+		name     string
+		format   Format
+		data     string
+		expected [][]float64
+		anchor   image.Point
+	}{
+		{"csv", FormatCSV, "# sobel\n1, 0, -1\n2,0,-2\n1,0,-1\n", [][]float64{{1, 0, -1}, {2, 0, -2}, {1, 0, -1}}, image.Pt(1, 1)},
+		{"json", FormatJSON, `{"weights": [[1, 1], [1, 1]], "anchor": {"x": 0, "y": 1}, "normalize": true}`, [][]float64{{0.25, 0.25}, {0.25, 0.25}}, image.Pt(0, 1)},
+		{"text", FormatText, "# shift\nanchor 0 0\n0 0 1 # row\n", [][]float64{{0, 0, 1}}, image.Pt(0, 0)},
+		{"normalized text", FormatText, "normalize\n1 2 1\n", [][]float64{{0.25, 0.5, 0.25}}, image.Pt(1, 0)},
+	}
+	for _, c := range cases {
+		k, err := Read(strings.NewReader(c.data), c.format)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !equalWeights(k.Weights, c.expected, 1e-12) || k.Anchor != c.anchor {
+			t.Errorf("%s: expected %v anchored at %v, got %v at %v", c.name, c.expected, c.anchor, *k.Weights, k.Anchor)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		format Format
+		data   string
+		err    error
+	}{
+		{"empty", FormatCSV, "# nothing\n", ErrEmpty},
+		{"ragged", FormatCSV, "1,2\n3\n", ErrRagged},
+		{"anchor", FormatJSON, `{"weights": [[1]], "anchor": {"x": 1, "y": 0}}`, ErrAnchor},
+		{"zero sum", FormatText, "normalize\n1 -1\n", ErrNormalize},
+		{"number", FormatText, "1 x\n", nil},
+		{"late anchor", FormatText, "1\nanchor 0 0\n", nil},
+		{"unknown field", FormatJSON, `{"weights": [[1]], "scale": 2}`, nil},
+	}
+	for _, c := range cases {
+		k, err := Read(strings.NewReader(c.data), c.format)
+		if err == nil || k != nil {
+			t.Errorf("%s: expected an error", c.name)
+		} else if c.err != nil && !errors.Is(err, c.err) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.err, err)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	k := Sobel(5, 1, 1)
+	k.Anchor = image.Pt(1, 3)
+	for _, format := range []Format{FormatCSV, FormatJSON, FormatText} {
+		buffer := &bytes.Buffer{}
+		if err := Write(buffer, k, format); err != nil {
+			t.Fatalf("format %d: %v", format, err)
+		}
+		back, err := Read(buffer, format)
+		if err != nil {
+			t.Fatalf("format %d: %v", format, err)
+		}
+		anchor := k.Anchor
+		if format == FormatCSV {
+			anchor = image.Pt(2, 2)
+		}
+		if !equalWeights(back.Weights, *k.Weights, 0) || back.Anchor != anchor || !back.Separable() {
+			t.Errorf("format %d: expected %v at %v, got %v at %v", format, *k.Weights, anchor, *back.Weights, back.Anchor)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "kernel.json")
+	if err := Save(path, k); err != nil {
+		t.Fatal(err)
+	}
+	if back, err := Load(path); err != nil || back.Anchor != k.Anchor {
+		t.Errorf("expected the saved kernel, got %v", err)
+	}
+	if _, err := Load("kernel.png"); !errors.Is(err, ErrFormat) {
+		t.Errorf("expected an unknown format, got %v", err)
+	}
+}
+
+func TestNamed(t *testing.T) {
+	names := Names()
+	for i, name := range names {
+		k, err := Named(name)
+		if err != nil || k == nil || len(*k.Weights) == 0 {
+			t.Errorf("%s: expected a kernel, got %v", name, err)
+		}
+		if i > 0 && names[i-1] >= name {
+			t.Errorf("names are not sorted: %v", names)
+		}
+	}
+	if _, err := Named("missing"); err == nil {
+		t.Errorf("expected an error for an unknown name")
+	}
+	Register("identity", func() *Kernel { return NewSeparable([]float64{1}, []float64{1}) })
+	if k, err := Named("identity"); err != nil || (*k.Weights)[0][0] != 1 {
+		t.Errorf("expected the registered kernel, got %v", err)
+	}
+}
@@ -0,0 +1,460 @@
+package kernel
+
+import (
+	"image"
+	"math"
+
+	"github.com/joaowiciuk/matrix"
+)
+
+// Kernel is a convolution kernel with the metadata needed to apply it.
+// Weights are in convolution order, the order in which the library's
+// Convolve and ApplyKernel flip them over the image, so derivative kernels
+// hold [1, 0, -1] along their axis and respond positively to values
+// increasing rightwards or downwards.
+type Kernel struct {
+	// Weights holds the kernel weights.
+	Weights *matrix.Matrix
+	// Anchor is the column and row of the weight aligned with each output
+	// pixel.
+	Anchor image.Point
+	//Factors of separable kernels, see Factors
+	col, row []float64
+}
+
+// New wraps the weights in a kernel anchored at their center, detecting
+// whether they are separable.
+func New(weights *matrix.Matrix) *Kernel {
+	cx, cy := weights.Center()
+	k := &Kernel{Weights: weights, Anchor: image.Pt(cx, cy)}
+	k.col, k.row, _ = factor(*weights)
+	return k
+}
+
+// NewSeparable returns the kernel col·rowᵀ anchored at its center.
+func NewSeparable(col, row []float64) *Kernel {
+	weights := matrix.New(len(col), len(row))
+	for r, a := range col {
+		for c, b := range row {
+			(*weights)[r][c] = a * b
+		}
+	}
+	cx, cy := weights.Center()
+	return &Kernel{Weights: weights, Anchor: image.Pt(cx, cy), col: col, row: row}
+}
+
+// Factors returns the column and row whose outer product col·rowᵀ is the
+// weights if they are separable, which allows applying the kernel as a
+// vertical and a horizontal 1D pass. Factors found by New or given to
+// NewSeparable are checked against the current weights and found again if
+// they no longer match.
+func (k *Kernel) Factors() (col, row []float64, ok bool) {
+	if k.Weights == nil {
+		return nil, nil, false
+	}
+	w := *k.Weights
+	if k.col != nil && outer(w, k.col, k.row) {
+		return k.col, k.row, true
+	}
+	return factor(w)
+}
+
+// Separable reports whether the weights are the outer product of a column
+// and a row.
+func (k *Kernel) Separable() bool {
+	_, _, ok := k.Factors()
+	return ok
+}
+
+// outer reports whether the matrix is the outer product col·rowᵀ.
+func outer(w [][]float64, col, row []float64) bool {
+	if len(w) != len(col) {
+		return false
+	}
+	peak := 0.
+	for r := range w {
+		if len(w[r]) != len(row) {
+			return false
+		}
+		for _, v := range w[r] {
+			peak = math.Max(peak, math.Abs(v))
+		}
+	}
+	for r := range w {
+		for c, v := range w[r] {
+			if math.Abs(v-col[r]*row[c]) > 1e-12*peak {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// factor returns the column and row whose outer product is the matrix if it
+// has rank one. They are the column and row through its largest weight.
+func factor(w [][]float64) (col, row []float64, ok bool) {
+	if len(w) == 0 || len(w[0]) == 0 {
+		return nil, nil, false
+	}
+	pr, pc, peak := 0, 0, 0.
+	for r := range w {
+		if len(w[r]) != len(w[0]) {
+			return nil, nil, false
+		}
+		for c, v := range w[r] {
+			if math.Abs(v) > peak {
+				pr, pc, peak = r, c, math.Abs(v)
+			}
+		}
+	}
+	if peak == 0 {
+		return nil, nil, false
+	}
+	col = make([]float64, len(w))
+	for r := range w {
+		col[r] = w[r][pc]
+	}
+	row = make([]float64, len(w[0]))
+	for c := range row {
+		row[c] = w[pr][c] / w[pr][pc]
+	}
+	for r := range w {
+		for c, v := range w[r] {
+			if math.Abs(v-col[r]*row[c]) > 1e-12*peak {
+				return nil, nil, false
+			}
+		}
+	}
+	return col, row, true
+}
+
+// convolve1D returns the full convolution of two sequences.
+func convolve1D(a, b []float64) []float64 {
+	out := make([]float64, len(a)+len(b)-1)
+	for i, u := range a {
+		for j, v := range b {
+			out[i+j] += u * v
+		}
+	}
+	return out
+}
+
+// oddSize returns n rounded up to an odd size of at least min.
+func oddSize(n, min int) int {
+	if n < min {
+		n = min
+	}
+	if n%2 == 0 {
+		n++
+	}
+	return n
+}
+
+// derivative returns the n taps binomial smoothing differentiated order
+// times, built as [1, 1]^(n-1-order) * [1, -1]^order.
+func derivative(n, order int) []float64 {
+	k := []float64{1}
+	for i := 0; i < n-1-order; i++ {
+		k = convolve1D(k, []float64{1, 1})
+	}
+	for i := 0; i < order; i++ {
+		k = convolve1D(k, []float64{1, -1})
+	}
+	return k
+}
+
+// Sobel returns the n-by-n Sobel kernel of the derivative of order dx along
+// x and dy along y, built from binomial smoothing and differences as in
+// OpenCV. The size is rounded up to an odd size larger than both orders.
+func Sobel(n, dx, dy int) *Kernel {
+	if dx < 0 || dy < 0 {
+		return nil
+	}
+	least := 3
+	if dx >= least {
+		least = dx + 1
+	}
+	if dy >= least {
+		least = dy + 1
+	}
+	n = oddSize(n, least)
+	return NewSeparable(derivative(n, dy), derivative(n, dx))
+}
+
+// Scharr returns the n-by-n Scharr kernel of the first derivative along x
+// if dx is one or along y if dy is one, whose [3, 10, 3] smoothing is more
+// rotation invariant than Sobel's. Sizes above 3 extend both taps with
+// binomial smoothing. It returns nil unless exactly one order is one.
+func Scharr(n, dx, dy int) *Kernel {
+	if dx+dy != 1 || dx < 0 || dy < 0 {
+		return nil
+	}
+	n = oddSize(n, 3)
+	smooth, diff := []float64{3, 10, 3}, []float64{1, 0, -1}
+	for i := 3; i < n; i++ {
+		smooth = convolve1D(smooth, []float64{1, 1})
+		diff = convolve1D(diff, []float64{1, 1})
+	}
+	if dx == 1 {
+		return NewSeparable(smooth, diff)
+	}
+	return NewSeparable(diff, smooth)
+}
+
+// Prewitt returns the n-by-n Prewitt kernel of the first derivative along x
+// if dx is one or along y if dy is one, with uniform smoothing and a linear
+// ramp of differences. It returns nil unless exactly one order is one.
+func Prewitt(n, dx, dy int) *Kernel {
+	if dx+dy != 1 || dx < 0 || dy < 0 {
+		return nil
+	}
+	n = oddSize(n, 3)
+	smooth, diff := make([]float64, n), make([]float64, n)
+	for i := range smooth {
+		smooth[i] = 1
+		diff[i] = float64(n/2 - i)
+	}
+	if dx == 1 {
+		return NewSeparable(smooth, diff)
+	}
+	return NewSeparable(diff, smooth)
+}
+
+// gaussianSize returns the size of kernels truncated at three standard
+// deviations.
+func gaussianSize(σ float64) int {
+	return 2*int(math.Ceil(3*σ)) + 1
+}
+
+// hermite returns the probabilists' Hermite polynomial of the given order,
+// which gives the derivatives of the gaussian as
+// dⁿ/dxⁿ g(x) = (-1/σ)ⁿ Heₙ(x/σ) g(x).
+func hermite(order int, x float64) float64 {
+	h0, h1 := 1., x
+	if order == 0 {
+		return h0
+	}
+	for k := 1; k < order; k++ {
+		h0, h1 = h1, x*h1-float64(k)*h0
+	}
+	return h1
+}
+
+// gaussianDerivative returns the samples of the derivative of the given order
+// of a gaussian normalized to unit sum.
+func gaussianDerivative(σ float64, order int) []float64 {
+	n := gaussianSize(σ)
+	g := make([]float64, n)
+	sum := 0.
+	for i := range g {
+		x := float64(i - n/2)
+		g[i] = math.Exp(-x * x / (2 * σ * σ))
+		sum += g[i]
+	}
+	for i := range g {
+		x := float64(i - n/2)
+		g[i] *= math.Pow(-1/σ, float64(order)) * hermite(order, x/σ) / sum
+	}
+	return g
+}
+
+// GaussianDerivative returns the separable derivative of order dx along x
+// and dy along y of a gaussian of standard deviation σ, truncated at three
+// standard deviations. Orders zero give the normalized gaussian.
+func GaussianDerivative(σ float64, dx, dy int) *Kernel {
+	if σ <= 0 || dx < 0 || dy < 0 {
+		return nil
+	}
+	return NewSeparable(gaussianDerivative(σ, dy), gaussianDerivative(σ, dx))
+}
+
+// LaplacianOfGaussian returns the Laplacian of a gaussian of standard
+// deviation σ, the sum of its second derivatives along x and y, adjusted to
+// zero sum so flat regions give no response.
+func LaplacianOfGaussian(σ float64) *Kernel {
+	if σ <= 0 {
+		return nil
+	}
+	g, d := gaussianDerivative(σ, 0), gaussianDerivative(σ, 2)
+	n := len(g)
+	weights := matrix.New(n, n)
+	sum := 0.
+	for r := 0; r < n; r++ {
+		for c := 0; c < n; c++ {
+			(*weights)[r][c] = d[r]*g[c] + g[r]*d[c]
+			sum += (*weights)[r][c]
+		}
+	}
+	for r := 0; r < n; r++ {
+		for c := 0; c < n; c++ {
+			(*weights)[r][c] -= sum / float64(n*n)
+		}
+	}
+	return New(weights)
+}
+
+// DifferenceOfGaussians returns the difference of normalized gaussians of
+// standard deviations σ1 and σ2, which approximates a negative multiple of
+// the Laplacian of Gaussian when σ2 is about 1.6 σ1.
+func DifferenceOfGaussians(σ1, σ2 float64) *Kernel {
+	if σ1 <= 0 || σ2 <= 0 {
+		return nil
+	}
+	g1, g2 := gaussianDerivative(σ1, 0), gaussianDerivative(σ2, 0)
+	n := len(g1)
+	if len(g2) > n {
+		n = len(g2)
+	}
+	weights := matrix.New(n, n)
+	o1, o2 := (n-len(g1))/2, (n-len(g2))/2
+	for r := 0; r < n; r++ {
+		for c := 0; c < n; c++ {
+			if r >= o1 && r < o1+len(g1) && c >= o1 && c < o1+len(g1) {
+				(*weights)[r][c] += g1[r-o1] * g1[c-o1]
+			}
+			if r >= o2 && r < o2+len(g2) && c >= o2 && c < o2+len(g2) {
+				(*weights)[r][c] -= g2[r-o2] * g2[c-o2]
+			}
+		}
+	}
+	return New(weights)
+}
+
+// Gabor returns the Gabor kernel with a gaussian envelope of standard
+// deviation σ and spatial aspect ratio γ, modulated by a cosine of
+// wavelength λ and phase offset ψ, in radians, along the direction θ,
+// in radians counterclockwise from the x axis, as in
+// J. G. Daugman, Uncertainty relation for resolution in space, spatial frequency, and orientation optimized by two-dimensional visual cortical filters,
+// Journal of the Optical Society of America A, 2 (1985), pp. 1160–1169.
+// https://doi.org/10.1364/JOSAA.2.001160
+func Gabor(σ, θ, λ, γ, ψ float64) *Kernel {
+	if σ <= 0 || λ <= 0 || γ <= 0 {
+		return nil
+	}
+	//The envelope is σ/γ long across the stripes
+	n := gaussianSize(σ * math.Max(1, 1/γ))
+	weights := matrix.New(n, n)
+	sin, cos := math.Sincos(θ)
+	for r := 0; r < n; r++ {
+		for c := 0; c < n; c++ {
+			x, y := float64(c-n/2), float64(r-n/2)
+			//Rows grow downwards, so y is negated for counterclockwise angles
+			u := x*cos - y*sin
+			v := x*sin + y*cos
+			(*weights)[r][c] = math.Exp(-(u*u+γ*γ*v*v)/(2*σ*σ)) * math.Cos(2*math.Pi*u/λ+ψ)
+		}
+	}
+	return New(weights)
+}
+
+// GaborBank returns Gabor kernels at the given number of orientations evenly
+// spaced over 180 degrees, starting along the x axis.
+func GaborBank(σ, λ, γ, ψ float64, orientations int) []*Kernel {
+	bank := make([]*Kernel, 0, orientations)
+	for i := 0; i < orientations; i++ {
+		bank = append(bank, Gabor(σ, math.Pi*float64(i)/float64(orientations), λ, γ, ψ))
+	}
+	return bank
+}
+
+// Disk returns the normalized circular averaging kernel of the given radius,
+// weighting border pixels by the fraction of their area inside the circle.
+func Disk(radius float64) *Kernel {
+	if radius <= 0 {
+		return nil
+	}
+	r := int(math.Ceil(radius - 0.5))
+	n := 2*r + 1
+	return coverage(n, func(x, y float64) bool { return x*x+y*y <= radius*radius })
+}
+
+// MotionBlur returns the normalized kernel of a linear motion of the given
+// length in pixels along the direction angle, in degrees counterclockwise
+// from the x axis, weighting pixels by the fraction of their area within
+// the one pixel wide path.
+func MotionBlur(length, angle float64) *Kernel {
+	if length <= 0 {
+		return nil
+	}
+	r := int(math.Ceil(length/2 + 0.5))
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	return coverage(2*r+1, func(x, y float64) bool {
+		//Distances along and across the path, with y negated as rows grow
+		//downwards
+		along := x*cos - y*sin
+		across := x*sin + y*cos
+		return math.Abs(along) <= length/2 && math.Abs(across) <= 0.5
+	})
+}
+
+// coverage returns the normalized n-by-n kernel weighting each pixel by the
+// fraction of it inside a region, estimated on a grid of subpixels.
+func coverage(n int, inside func(x, y float64) bool) *Kernel {
+	const sub = 8
+	weights := matrix.New(n, n)
+	sum := 0.
+	for r := 0; r < n; r++ {
+		for c := 0; c < n; c++ {
+			count := 0
+			for j := 0; j < sub; j++ {
+				for i := 0; i < sub; i++ {
+					x := float64(c-n/2) + (float64(i)+0.5)/sub - 0.5
+					y := float64(r-n/2) + (float64(j)+0.5)/sub - 0.5
+					if inside(x, y) {
+						count++
+					}
+				}
+			}
+			(*weights)[r][c] = float64(count)
+			sum += float64(count)
+		}
+	}
+	for r := range *weights {
+		for c := range (*weights)[r] {
+			(*weights)[r][c] /= sum
+		}
+	}
+	return New(weights)
+}
+
+// compass returns the eight rotations of a 3-by-3 mask by steps of 45
+// degrees counterclockwise, rotating its outer ring.
+func compass(mask [3][3]float64) [8]*Kernel {
+	//Rows and columns of the ring in counterclockwise order on the image,
+	//starting at the top left
+	ring := [8][2]int{{0, 0}, {1, 0}, {2, 0}, {2, 1}, {2, 2}, {1, 2}, {0, 2}, {0, 1}}
+	var out [8]*Kernel
+	for k := range out {
+		weights := matrix.New(3, 3)
+		(*weights)[1][1] = mask[1][1]
+		for i, p := range ring {
+			q := ring[(i+k)%8]
+			(*weights)[q[0]][q[1]] = mask[p[0]][p[1]]
+		}
+		out[k] = New(weights)
+	}
+	return out
+}
+
+// Kirsch returns the eight Kirsch compass masks, which respond most to edges
+// whose brighter side lies north, northwest, west, southwest, south,
+// southeast, east and northeast of the pixel, in that order. The image
+// north is its top.
+func Kirsch() [8]*Kernel {
+	//In convolution order the bright side of the north mask is the bottom
+	return compass([3][3]float64{
+		{-3, -3, -3},
+		{-3, 0, -3},
+		{5, 5, 5},
+	})
+}
+
+// Robinson returns the eight Robinson compass masks, in the order of
+// Kirsch.
+func Robinson() [8]*Kernel {
+	return compass([3][3]float64{
+		{-1, -2, -1},
+		{0, 0, 0},
+		{1, 2, 1},
+	})
+}
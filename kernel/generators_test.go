@@ -0,0 +1,169 @@
+package kernel
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/joaowiciuk/matrix"
+)
+
+func equalWeights(a *matrix.Matrix, b [][]float64, tolerance float64) bool {
+	if len(*a) != len(b) {
+		return false
+	}
+	for r := range b {
+		if len((*a)[r]) != len(b[r]) {
+			return false
+		}
+		for c := range b[r] {
+			if math.Abs((*a)[r][c]-b[r][c]) > tolerance {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func sum(k *Kernel) float64 {
+	s := 0.
+	for _, row := range *k.Weights {
+		for _, v := range row {
+			s += v
+		}
+	}
+	return s
+}
+
+func TestSobel(t *testing.T) {
+	cases := []struct {
+		name     string
+		k        *Kernel
+		expected [][]float64
+	}{
+		{"sobel x", Sobel(3, 1, 0), [][]float64{{1, 0, -1}, {2, 0, -2}, {1, 0, -1}}},
+		{"sobel y", Sobel(3, 0, 1), [][]float64{{1, 2, 1}, {0, 0, 0}, {-1, -2, -1}}},
+		{"sobel xx", Sobel(3, 2, 0), [][]float64{{1, -2, 1}, {2, -4, 2}, {1, -2, 1}}},
+		{"sobel 5", Sobel(4, 1, 0), [][]float64{
+			{1, 2, 0, -2, -1},
+			{4, 8, 0, -8, -4},
+			{6, 12, 0, -12, -6},
+			{4, 8, 0, -8, -4},
+			{1, 2, 0, -2, -1},
+		}},
+		{"scharr x", Scharr(3, 1, 0), [][]float64{{3, 0, -3}, {10, 0, -10}, {3, 0, -3}}},
+		{"prewitt y", Prewitt(3, 0, 1), [][]float64{{1, 1, 1}, {0, 0, 0}, {-1, -1, -1}}},
+	}
+	for _, c := range cases {
+		if !equalWeights(c.k.Weights, c.expected, 1e-12) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, *c.k.Weights)
+		}
+		if !c.k.Separable() || c.k.Anchor != image.Pt(len(c.expected)/2, len(c.expected)/2) {
+			t.Errorf("%s: expected a separable kernel anchored at its center", c.name)
+		}
+	}
+	if Scharr(3, 1, 1) != nil || Prewitt(3, 0, 0) != nil || Sobel(3, -1, 0) != nil {
+		t.Errorf("invalid orders should give nil")
+	}
+}
+
+func TestGaussianDerivative(t *testing.T) {
+	σ := 1.5
+	g := GaussianDerivative(σ, 0, 0)
+	if math.Abs(sum(g)-1) > 1e-12 || len(*g.Weights) != 11 {
+		t.Errorf("expected a normalized 11x11 gaussian, got sum %v", sum(g))
+	}
+	//Applied to a ramp, the first derivative gives its slope and the second
+	//gives zero
+	for order, slope := range map[int]float64{1: 1, 2: 0} {
+		d := GaussianDerivative(σ, order, 0).row
+		response := 0.
+		for i, v := range d {
+			//Convolution order flips the kernel over the samples
+			response += v * float64(len(d)/2-i)
+		}
+		if math.Abs(response-slope) > 0.01 {
+			t.Errorf("order %d: expected response %v, got %v", order, slope, response)
+		}
+	}
+	if GaussianDerivative(0, 1, 0) != nil {
+		t.Errorf("a zero σ should give nil")
+	}
+}
+
+func TestLaplacianOfGaussian(t *testing.T) {
+	for sign, k := range map[float64]*Kernel{-1: LaplacianOfGaussian(1.4), 1: DifferenceOfGaussians(1, 1.6)} {
+		n := len(*k.Weights)
+		if math.Abs(sum(k)) > 1e-9 || k.Separable() {
+			t.Errorf("expected a zero sum non separable kernel, got sum %v", sum(k))
+		}
+		if center := (*k.Weights)[n/2][n/2]; center*sign <= 0 {
+			t.Errorf("expected a center of sign %v, got %v", sign, center)
+		}
+	}
+}
+
+func TestGabor(t *testing.T) {
+	bank := GaborBank(2, 4, 1, 0, 4)
+	if len(bank) != 4 {
+		t.Fatalf("expected 4 kernels, got %d", len(bank))
+	}
+	//The kernel along x varies across columns only at its central row
+	k := *bank[0].Weights
+	n := len(k)
+	if math.Abs(k[n/2][n/2]-1) > 1e-12 || math.Abs(k[n/2][n/2+2]+math.Exp(-0.5)) > 1e-12 {
+		t.Errorf("unexpected weights along x: %v", k[n/2])
+	}
+	//The kernel at 90 degrees is the transpose
+	q := *bank[2].Weights
+	for r := 0; r < n; r++ {
+		for c := 0; c < n; c++ {
+			if math.Abs(k[r][c]-q[c][r]) > 1e-9 {
+				t.Fatalf("expected the transpose at (%d, %d)", r, c)
+			}
+		}
+	}
+}
+
+func TestDisk(t *testing.T) {
+	for _, k := range []*Kernel{Disk(2.5), MotionBlur(5, 0), MotionBlur(7, 45)} {
+		if math.Abs(sum(k)-1) > 1e-12 {
+			t.Errorf("expected a normalized kernel, got sum %v", sum(k))
+		}
+	}
+	d := *Disk(2.5).Weights
+	if len(d) != 5 || d[2][2] != d[0][2] || d[0][0] >= d[0][1] {
+		t.Errorf("unexpected disk %v", d)
+	}
+	m := MotionBlur(5, 0)
+	if len(*m.Weights) != 7 || !m.Separable() || (*m.Weights)[2][3] != 0 || (*m.Weights)[3][1] == 0 {
+		t.Errorf("expected a horizontal line, got %v", *m.Weights)
+	}
+}
+
+func TestKirsch(t *testing.T) {
+	//An edge brighter at the top
+	patch := [3][3]float64{{9, 9, 9}, {5, 5, 5}, {1, 1, 1}}
+	for name, masks := range map[string][8]*Kernel{"kirsch": Kirsch(), "robinson": Robinson()} {
+		best, response := -1, math.Inf(-1)
+		for i, k := range masks {
+			v := 0.
+			for r := 0; r < 3; r++ {
+				for c := 0; c < 3; c++ {
+					//Convolution order flips the kernel over the patch
+					v += (*k.Weights)[2-r][2-c] * patch[r][c]
+				}
+			}
+			if v > response {
+				best, response = i, v
+			}
+		}
+		if best != 0 {
+			t.Errorf("%s: expected the north mask, got %d", name, best)
+		}
+	}
+	west := *Kirsch()[2].Weights
+	if !equalWeights(&west, [][]float64{{-3, -3, 5}, {-3, 0, 5}, {-3, -3, 5}}, 0) {
+		t.Errorf("unexpected west mask %v", west)
+	}
+}
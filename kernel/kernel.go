@@ -151,7 +151,7 @@ func Gaussian(n int, σ float64) *matrix.Matrix {
 	return X.ForEach(func(x float64) float64 { return x / s })
 }
 
-// Gaussian1D generates the 1D gaussian kernel with standart deviation σ, used for separable convolution.
+// Gaussian1D generates the 1-by-n gaussian kernel with standart deviation σ, used for separable convolution.
 func Gaussian1D(n int, σ float64) (A *matrix.Matrix) {
 	if n < 3 {
 		n = 3
@@ -159,14 +159,11 @@ func Gaussian1D(n int, σ float64) (A *matrix.Matrix) {
 	if n%2 == 0 {
 		n++
 	}
-	X := matrix.New(n, n)
+	X := matrix.New(1, n)
 	u := float64(n / 2)
 	X.Law(func(r, c int) float64 {
-		x := float64(c)
-		y := float64(r)
-		return math.Exp(-(math.Pow(x-u, 2) + math.Pow(y-u, 2)) / (2. * σ * σ))
+		return math.Exp(-math.Pow(float64(c)-u, 2) / (2. * σ * σ))
 	})
 	s := X.Sum()
-	X = X.ForEach(func(x float64) float64 { return x / s })
-	return X.Row(n / 2)
+	return X.ForEach(func(x float64) float64 { return x / s })
 }
@@ -278,21 +278,22 @@ func TestGaussian1D(t *testing.T) {
 				σ: math.Sqrt(2),
 			},
 			wantA: &matrix.Matrix{
-				{1, 4, 7, 10, 7, 4, 1},
-				{4, 12, 26, 33, 26, 12, 4},
-				{7, 26, 55, 71, 55, 26, 7},
-				{10, 33, 71, 91, 71, 33, 10},
-				{7, 26, 55, 71, 55, 26, 7},
-				{4, 12, 26, 33, 26, 12, 4},
-				{1, 4, 7, 10, 7, 4, 1},
+				{0.030078, 0.104984, 0.222250, 0.285375, 0.222250, 0.104984, 0.030078},
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			gotA := Gaussian1D(tt.args.n, tt.args.σ)
-			if !reflect.DeepEqual(gotA, tt.wantA) {
-				t.Errorf("Gaussian1D() gotA = \n%v\nWant \n%v\n", gotA, tt.wantA)
+			m, n := gotA.Size()
+			if m != 1 || n != tt.args.n {
+				t.Fatalf("Gaussian1D() size = %dx%d, want 1x%d", m, n, tt.args.n)
+			}
+			for c := 0; c < n; c++ {
+				if math.Abs((*gotA)[0][c]-(*tt.wantA)[0][c]) > 1e-6 {
+					t.Errorf("Gaussian1D() gotA = \n%v\nWant \n%v\n", gotA, tt.wantA)
+					break
+				}
 			}
 		})
 	}
@@ -0,0 +1,70 @@
+package kernel
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// registry maps the names of kernels to their generators.
+var registry = struct {
+	sync.RWMutex
+	kernels map[string]func() *Kernel
+}{kernels: map[string]func() *Kernel{
+	"laplacian": func() *Kernel { return New(Laplacian()) },
+	"sharpen":   func() *Kernel { return New(Sharpen()) },
+	"line180":   func() *Kernel { return New(Line180()) },
+	"line90":    func() *Kernel { return New(Line90()) },
+	"line45":    func() *Kernel { return New(Line45()) },
+	"line135":   func() *Kernel { return New(Line135()) },
+	"log":       func() *Kernel { return New(LoG()) },
+	"box":       func() *Kernel { return New(Box()) },
+	"unsharp55": func() *Kernel { return New(Unsharp55()) },
+	"gaussian3": func() *Kernel { return New(Gaussian(3, 1)) },
+	"gaussian5": func() *Kernel { return New(Gaussian(5, 1)) },
+	"sobelx":    func() *Kernel { return Sobel(3, 1, 0) },
+	"sobely":    func() *Kernel { return Sobel(3, 0, 1) },
+	"scharrx":   func() *Kernel { return Scharr(3, 1, 0) },
+	"scharry":   func() *Kernel { return Scharr(3, 0, 1) },
+	"prewittx":  func() *Kernel { return Prewitt(3, 1, 0) },
+	"prewitty":  func() *Kernel { return Prewitt(3, 0, 1) },
+	"kirschn":   func() *Kernel { return Kirsch()[0] },
+	"kirschw":   func() *Kernel { return Kirsch()[2] },
+	"kirschs":   func() *Kernel { return Kirsch()[4] },
+	"kirsche":   func() *Kernel { return Kirsch()[6] },
+	"robinsonn": func() *Kernel { return Robinson()[0] },
+	"robinsonw": func() *Kernel { return Robinson()[2] },
+	"robinsons": func() *Kernel { return Robinson()[4] },
+	"robinsone": func() *Kernel { return Robinson()[6] },
+}}
+
+// Register makes the kernel returned by the generator available by name,
+// replacing any kernel of the same name.
+func Register(name string, generator func() *Kernel) {
+	registry.Lock()
+	registry.kernels[name] = generator
+	registry.Unlock()
+}
+
+// Names returns the names of the registered kernels in alphabetical order.
+func Names() []string {
+	registry.RLock()
+	names := make([]string, 0, len(registry.kernels))
+	for name := range registry.kernels {
+		names = append(names, name)
+	}
+	registry.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// Named returns a new copy of the registered kernel of the given name.
+func Named(name string) (*Kernel, error) {
+	registry.RLock()
+	generator, ok := registry.kernels[name]
+	registry.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("kernel: unknown kernel %q", name)
+	}
+	return generator(), nil
+}
@@ -3,7 +3,9 @@ package vision
 import (
 	"image"
 	"image/color"
+	"math"
 	"math/rand"
+	"sort"
 )
 
 func max(i, j int) int {
@@ -82,3 +84,115 @@ func pairing(x, y int) int {
 	}
 	return x*x + x + y
 }
+
+// solveLinear solves the square system a x = b by gaussian elimination with
+// partial pivoting. It returns false if the system is singular. Both a and b
+// are modified.
+func solveLinear(a [][]float64, b []float64) ([]float64, bool) {
+	n := len(b)
+	for c := 0; c < n; c++ {
+		p := c
+		for r := c + 1; r < n; r++ {
+			if math.Abs(a[r][c]) > math.Abs(a[p][c]) {
+				p = r
+			}
+		}
+		if math.Abs(a[p][c]) < 1e-12 {
+			return nil, false
+		}
+		a[c], a[p] = a[p], a[c]
+		b[c], b[p] = b[p], b[c]
+		for r := c + 1; r < n; r++ {
+			f := a[r][c] / a[c][c]
+			for k := c; k < n; k++ {
+				a[r][k] -= f * a[c][k]
+			}
+			b[r] -= f * b[c]
+		}
+	}
+	x := make([]float64, n)
+	for r := n - 1; r >= 0; r-- {
+		s := b[r]
+		for k := r + 1; k < n; k++ {
+			s -= a[r][k] * x[k]
+		}
+		x[r] = s / a[r][r]
+	}
+	return x, true
+}
+
+// symmetricEigen returns the eigenvalues of the symmetric matrix a in
+// increasing order and the corresponding unit eigenvectors, computed with
+// the cyclic Jacobi method. The matrix a is not modified.
+func symmetricEigen(a [][]float64) (values []float64, vectors [][]float64) {
+	n := len(a)
+	m := make([][]float64, n)
+	v := make([][]float64, n)
+	for i := range m {
+		m[i] = append([]float64(nil), a[i]...)
+		v[i] = make([]float64, n)
+		v[i][i] = 1
+	}
+	//Sweep until the off-diagonal part is negligible relative to the matrix
+	norm := 0.
+	for i := range a {
+		for _, x := range a[i] {
+			norm += x * x
+		}
+	}
+	for sweep := 0; sweep < 100; sweep++ {
+		off := 0.
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				off += m[i][j] * m[i][j]
+			}
+		}
+		if off <= 1e-30*norm {
+			break
+		}
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				if m[p][q] == 0 {
+					continue
+				}
+				theta := (m[q][q] - m[p][p]) / (2 * m[p][q])
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+				for k := 0; k < n; k++ {
+					mkp, mkq := m[k][p], m[k][q]
+					m[k][p] = c*mkp - s*mkq
+					m[k][q] = s*mkp + c*mkq
+				}
+				for k := 0; k < n; k++ {
+					mpk, mqk := m[p][k], m[q][k]
+					m[p][k] = c*mpk - s*mqk
+					m[q][k] = s*mpk + c*mqk
+				}
+				for k := 0; k < n; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p] = c*vkp - s*vkq
+					v[k][q] = s*vkp + c*vkq
+				}
+			}
+		}
+	}
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return m[order[i]][order[i]] < m[order[j]][order[j]] })
+	values = make([]float64, n)
+	vectors = make([][]float64, n)
+	for i, k := range order {
+		values[i] = m[k][k]
+		vectors[i] = make([]float64, n)
+		for r := 0; r < n; r++ {
+			vectors[i][r] = v[r][k]
+		}
+	}
+	return
+}
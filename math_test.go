@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"image"
 	"image/draw"
+	"math"
 	"testing"
 
 	"github.com/anthonynsimon/bild/imgio"
@@ -26,3 +27,23 @@ func Test_generateVoronoi(t *testing.T) {
 	voroni := generateVoronoi(gray, sx, sy)
 	_ = imgio.Save("examples/blob-voroni.png", voroni, imgio.PNGEncoder())
 }
+
+func TestSymmetricEigenScale(t *testing.T) {
+	//The eigenvalues of [[2, 1], [1, 2]] are 1 and 3 at any scale, which
+	//needs a convergence test relative to the magnitude of the matrix
+	for _, scale := range []float64{1e-20, 1, 1e12} {
+		a := [][]float64{{2 * scale, scale}, {scale, 2 * scale}}
+		values, vectors := symmetricEigen(a)
+		for i, want := range []float64{scale, 3 * scale} {
+			if math.Abs(values[i]-want) > 1e-12*scale {
+				t.Errorf("scale %v: eigenvalue %d = %v, want %v", scale, i, values[i], want)
+			}
+			v := vectors[i]
+			for r := range a {
+				if av := a[r][0]*v[0] + a[r][1]*v[1]; math.Abs(av-values[i]*v[r]) > 1e-12*scale {
+					t.Errorf("scale %v: vector %d is not an eigenvector", scale, i)
+				}
+			}
+		}
+	}
+}
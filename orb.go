@@ -0,0 +1,227 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"math/bits"
+	"math/rand"
+	"sort"
+)
+
+// Keypoint is a salient point found by a feature detector. X and Y are its
+// coordinates at the original resolution, Size is the diameter of the
+// neighborhood described around it, Angle is its orientation in degrees,
+// Response is the detector score and Octave is the pyramid level where it
+// was found.
+type Keypoint struct {
+	X, Y     float64
+	Size     float64
+	Angle    float64
+	Response float64
+	Octave   int
+}
+
+// Descriptor is a 256 bits binary feature descriptor.
+type Descriptor [4]uint64
+
+// Distance returns the Hamming distance between two descriptors.
+func (d Descriptor) Distance(e Descriptor) int {
+	n := 0
+	for i := range d {
+		n += bits.OnesCount64(d[i] ^ e[i])
+	}
+	return n
+}
+
+// Match pairs the descriptor Query of a set with the descriptor Train of
+// another and stores the distance between them.
+type Match struct {
+	Query, Train int
+	Distance     int
+}
+
+const (
+	// orbPatchSize is the diameter of the patch described around each
+	// keypoint.
+	orbPatchSize = 31
+
+	// orbLevels and orbScale define the pyramid where keypoints are
+	// detected.
+	orbLevels = 8
+	orbScale  = 1 / 1.2
+
+	// orbFASTThreshold is the intensity difference used by the segment
+	// test.
+	orbFASTThreshold = 20
+
+	// harrisK is the sensitivity of the Harris corner measure.
+	harrisK = 0.04
+)
+
+// briefPattern holds the 256 point pairs compared by the descriptor, drawn
+// once from an isotropic gaussian as in the original BRIEF paper.
+var briefPattern = newBRIEFPattern()
+
+func newBRIEFPattern() (pattern [256][4]float64) {
+	rnd := rand.New(rand.NewSource(0x0b1ef))
+	r := float64(orbPatchSize/2 - 2)
+	σ := float64(orbPatchSize) / 5
+	for i := range pattern {
+		for j := range pattern[i] {
+			pattern[i][j] = clamp(math.Floor(rnd.NormFloat64()*σ+0.5), -r, r)
+		}
+	}
+	return
+}
+
+// ORB detects up to nFeatures keypoints and computes their binary
+// descriptors, as described in
+// E. Rublee, V. Rabaud, K. Konolige and G. Bradski, ORB: An efficient alternative to SIFT or SURF,
+// International Conference on Computer Vision (2011), pp. 2564–2571.
+// https://doi.org/10.1109/ICCV.2011.6126544
+//
+// Keypoints are FAST corners detected over a gaussian pyramid with a scale
+// factor of 1.2 and ranked by their Harris measure. Each keypoint is oriented
+// by the intensity centroid of its patch and described by 256 intensity
+// comparisons of the smoothed patch along a BRIEF pattern rotated by that
+// orientation.
+func ORB(gray *image.Gray, nFeatures int) (keypoints []Keypoint, descriptors []Descriptor) {
+	levels := gaussianPlanes(grayPlane(gray), orbLevels, orbScale)
+	border := orbPatchSize/2 + 4
+	type candidate struct {
+		kp   Keypoint
+		x, y int
+	}
+	candidates := make([]candidate, 0)
+	for l, p := range levels {
+		f := math.Pow(orbScale, float64(l))
+		scores := fastScores(p.gray(), orbFASTThreshold, 9, border)
+		for _, c := range suppressScores(scores, p.w, p.h) {
+			kp := Keypoint{
+				X:        (float64(c.X)+0.5)/f - 0.5,
+				Y:        (float64(c.Y)+0.5)/f - 0.5,
+				Size:     orbPatchSize / f,
+				Response: p.harris(c.X, c.Y, 3),
+				Octave:   l,
+			}
+			candidates = append(candidates, candidate{kp, c.X, c.Y})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].kp.Response > candidates[j].kp.Response
+	})
+	if nFeatures > 0 && len(candidates) > nFeatures {
+		candidates = candidates[:nFeatures]
+	}
+
+	smoothed := make([]plane, len(levels))
+	for l, p := range levels {
+		smoothed[l] = plane{values: gaussianBlur(p.values, p.w, p.h, 2), w: p.w, h: p.h}
+	}
+	keypoints = make([]Keypoint, len(candidates))
+	descriptors = make([]Descriptor, len(candidates))
+	for i, c := range candidates {
+		kp := c.kp
+		kp.Angle = levels[kp.Octave].centroidAngle(c.x, c.y, orbPatchSize/2)
+		keypoints[i] = kp
+		descriptors[i] = smoothed[kp.Octave].brief(c.x, c.y, kp.Angle)
+	}
+	return
+}
+
+// harris returns the Harris corner measure at (x, y), computed from the Sobel
+// derivatives summed over the window of the given radius.
+func (p plane) harris(x, y, r int) float64 {
+	at := func(x, y int) float64 {
+		return p.values[min(max(y, 0), p.h-1)*p.w+min(max(x, 0), p.w-1)]
+	}
+	var a, b, c float64
+	for j := y - r; j <= y+r; j++ {
+		for i := x - r; i <= x+r; i++ {
+			//The measure does not depend on the sign of the derivatives
+			dx, dy := sobel3(at, i, j)
+			dx, dy = dx/8, dy/8
+			a += dx * dx
+			b += dy * dy
+			c += dx * dy
+		}
+	}
+	return a*b - c*c - harrisK*(a+b)*(a+b)
+}
+
+// centroidAngle returns the direction in degrees from (x, y) to the
+// intensity centroid of the disk of radius r around it.
+func (p plane) centroidAngle(x, y, r int) float64 {
+	var m01, m10 float64
+	for j := -r; j <= r; j++ {
+		for i := -r; i <= r; i++ {
+			if i*i+j*j > r*r {
+				continue
+			}
+			v := p.values[(y+j)*p.w+x+i]
+			m10 += float64(i) * v
+			m01 += float64(j) * v
+		}
+	}
+	angle := math.Atan2(m01, m10) * 180 / math.Pi
+	if angle < 0 {
+		angle += 360
+	}
+	return angle
+}
+
+// brief computes the descriptor at (x, y) comparing the point pairs of the
+// pattern rotated by the angle in degrees.
+func (p plane) brief(x, y int, angle float64) (d Descriptor) {
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	at := func(u, v float64) float64 {
+		i := int(math.Floor(u*cos - v*sin + 0.5))
+		j := int(math.Floor(u*sin + v*cos + 0.5))
+		return p.values[(y+j)*p.w+x+i]
+	}
+	for k, pair := range briefPattern {
+		if at(pair[0], pair[1]) < at(pair[2], pair[3]) {
+			d[k/64] |= 1 << uint(k%64)
+		}
+	}
+	return
+}
+
+// MatchDescriptors matches each query descriptor to its nearest train
+// descriptor by brute force. If ratio is in (0, 1), a match is kept only
+// when its distance is below ratio times the distance to the second nearest
+// train descriptor. If crossCheck is true, a match is kept only when the
+// query descriptor is also the nearest to the train descriptor. Matches are
+// sorted by increasing distance.
+func MatchDescriptors(query, train []Descriptor, ratio float64, crossCheck bool) []Match {
+	matches := make([]Match, 0)
+	if len(train) == 0 {
+		return matches
+	}
+	nearest := func(d Descriptor, set []Descriptor) (best, first, second int) {
+		best, first, second = -1, math.MaxInt32, math.MaxInt32
+		for i, e := range set {
+			dist := d.Distance(e)
+			if dist < first {
+				best, first, second = i, dist, first
+			} else if dist < second {
+				second = dist
+			}
+		}
+		return
+	}
+	for q, d := range query {
+		t, first, second := nearest(d, train)
+		if ratio > 0 && ratio < 1 && second != math.MaxInt32 && float64(first) >= ratio*float64(second) {
+			continue
+		}
+		if crossCheck {
+			if back, _, _ := nearest(train[t], query); back != q {
+				continue
+			}
+		}
+		matches = append(matches, Match{Query: q, Train: t, Distance: first})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Distance < matches[j].Distance })
+	return matches
+}
@@ -0,0 +1,75 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestORB(t *testing.T) {
+	//A textured image and a copy rotated by 90 degrees clockwise
+	const w, h = 160, 120
+	values := smoothTexture(w, h, 1.5, 3)
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	rotated := image.NewGray(image.Rect(0, 0, h, w))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Pix[y*w+x] = uint8(values[y*w+x])
+			rotated.Pix[x*h+(h-1-y)] = uint8(values[y*w+x])
+		}
+	}
+	kp1, d1 := ORB(img, 200)
+	kp2, d2 := ORB(rotated, 200)
+	if len(kp1) == 0 || len(kp1) > 200 || len(kp1) != len(d1) {
+		t.Fatalf("ORB() returned %d keypoints and %d descriptors", len(kp1), len(d1))
+	}
+	matches := MatchDescriptors(d1, d2, 0.8, true)
+	if len(matches) < 10 {
+		t.Fatalf("MatchDescriptors() returned %d matches", len(matches))
+	}
+	good := 0
+	for _, m := range matches {
+		p, q := kp1[m.Query], kp2[m.Train]
+		if math.Hypot(q.X-(h-1-p.Y), q.Y-p.X) <= 3 {
+			good++
+		}
+	}
+	if float64(good) < 0.8*float64(len(matches)) {
+		t.Errorf("only %d of %d matches are consistent with the rotation", good, len(matches))
+	}
+}
+
+func TestMatchDescriptors(t *testing.T) {
+	query := []Descriptor{{0, 0, 0, 0}, {^uint64(0), 0, 0, 0}}
+	train := []Descriptor{{^uint64(0), 1, 0, 0}, {1, 0, 0, 0}, {2, 0, 0, 0}}
+	got := MatchDescriptors(query, train, 0, true)
+	want := []Match{{Query: 0, Train: 1, Distance: 1}, {Query: 1, Train: 0, Distance: 1}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("MatchDescriptors() = %v, want %v", got, want)
+	}
+	//The ratio test rejects the first query, whose two nearest are too close
+	if got := MatchDescriptors(query, train, 0.6, false); len(got) != 1 || got[0].Query != 1 {
+		t.Errorf("MatchDescriptors() with ratio = %v", got)
+	}
+}
+
+func TestHarris(t *testing.T) {
+	//A bright square: positive at a vertex, negative along an edge and zero
+	//on flat ground
+	gray := image.NewGray(image.Rect(0, 0, 20, 20))
+	for y := 5; y < 15; y++ {
+		for x := 5; x < 15; x++ {
+			gray.Pix[y*20+x] = 200
+		}
+	}
+	p := grayPlane(gray)
+	if r := p.harris(5, 5, 3); r <= 0 {
+		t.Errorf("expected a positive measure at the vertex, got %v", r)
+	}
+	if r := p.harris(10, 5, 3); r >= 0 {
+		t.Errorf("expected a negative measure along the edge, got %v", r)
+	}
+	if r := p.harris(10, 10, 2); r != 0 {
+		t.Errorf("expected zero on flat ground, got %v", r)
+	}
+}
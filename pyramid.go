@@ -0,0 +1,168 @@
+package vision
+
+import (
+	"image"
+	"math"
+
+	"github.com/joaowiciuk/matrix"
+)
+
+// plane is a single channel image of float values in row-major order, used
+// as the working representation of the multi-scale algorithms.
+type plane struct {
+	values []float64
+	w, h   int
+}
+
+// grayPlane converts a grayscale image to a plane.
+func grayPlane(gray *image.Gray) plane {
+	b := gray.Bounds()
+	p := plane{values: make([]float64, b.Dx()*b.Dy()), w: b.Dx(), h: b.Dy()}
+	for i, v := range grayValues(gray) {
+		p.values[i] = float64(v)
+	}
+	return p
+}
+
+// gray converts the plane to a grayscale image, rounding and clamping its
+// values.
+func (p plane) gray() *image.Gray {
+	gray := image.NewGray(image.Rect(0, 0, p.w, p.h))
+	for i, v := range p.values {
+		gray.Pix[i] = uint8(clamp(math.Floor(v+0.5), 0, 255))
+	}
+	return gray
+}
+
+// resample resizes the plane to w-by-h with bilinear interpolation, aligning
+// the pixel centers of both grids.
+func (p plane) resample(w, h int) plane {
+	out := plane{values: make([]float64, w*h), w: w, h: h}
+	sx, sy := float64(p.w)/float64(w), float64(p.h)/float64(h)
+	for y := 0; y < h; y++ {
+		v := clamp((float64(y)+0.5)*sy-0.5, 0, float64(p.h-1))
+		for x := 0; x < w; x++ {
+			u := clamp((float64(x)+0.5)*sx-0.5, 0, float64(p.w-1))
+			out.values[y*w+x] = bilinearAt(p.values, p.w, p.h, u, v)
+		}
+	}
+	return out
+}
+
+// finer maps the coordinate x of a plane resampled from n to m pixels back to
+// the coordinate of the original plane. Pixel centers are aligned, so halving
+// maps x to 2x+0.5 rather than 2x.
+func finer(x float64, n, m int) float64 {
+	return (x+0.5)*float64(n)/float64(m) - 0.5
+}
+
+// reduce blurs the plane and resamples it by the scale, which must lie in
+// (0, 1). The blur removes the frequencies above the new Nyquist limit; its
+// standard deviation is 1 when halving the plane.
+func (p plane) reduce(scale float64) plane {
+	σ := math.Sqrt(1/(scale*scale)-1) / math.Sqrt(3)
+	blurred := plane{values: gaussianBlur(p.values, p.w, p.h, σ), w: p.w, h: p.h}
+	w := max(int(math.Floor(float64(p.w)*scale+0.5)), 1)
+	h := max(int(math.Floor(float64(p.h)*scale+0.5)), 1)
+	return blurred.resample(w, h)
+}
+
+// gaussianPlanes returns up to n levels of the gaussian pyramid of the plane,
+// stopping early when a level would be a single pixel wide or tall.
+func gaussianPlanes(base plane, n int, scale float64) []plane {
+	planes := []plane{base}
+	for len(planes) < n {
+		last := planes[len(planes)-1]
+		if last.w < 2 || last.h < 2 {
+			break
+		}
+		planes = append(planes, last.reduce(scale))
+	}
+	return planes
+}
+
+// GaussianPyramid is a sequence of successively blurred and reduced copies
+// of an image. Levels[0] is the original image and each level is Scale times
+// the size of the previous one.
+type GaussianPyramid struct {
+	Scale  float64
+	Levels []*image.Gray
+}
+
+// NewGaussianPyramid builds the gaussian pyramid of the image with the given
+// number of levels, including the original one, and scale factor between
+// consecutive levels. A scale outside (0, 1) defaults to 0.5. The pyramid may
+// have fewer levels if the image becomes too small.
+func NewGaussianPyramid(gray *image.Gray, levels int, scale float64) *GaussianPyramid {
+	if scale <= 0 || scale >= 1 {
+		scale = 0.5
+	}
+	gp := &GaussianPyramid{Scale: scale}
+	for _, p := range gaussianPlanes(grayPlane(gray), levels, scale) {
+		gp.Levels = append(gp.Levels, p.gray())
+	}
+	return gp
+}
+
+// LaplacianPyramid stores the band-pass decomposition of an image. Each
+// level but the last holds the difference between a level of the gaussian
+// pyramid and the expansion of the next one, while the last level holds the
+// coarsest gaussian level. Levels are matrices since the differences are
+// signed.
+type LaplacianPyramid struct {
+	Scale  float64
+	Levels []*matrix.Matrix
+}
+
+// NewLaplacianPyramid builds the Laplacian pyramid of the image with the
+// given number of levels and scale factor, as described in
+// P. J. Burt and E. H. Adelson, The Laplacian Pyramid as a Compact Image Code,
+// IEEE Transactions on Communications, 31 (1983), pp. 532–540.
+// https://doi.org/10.1109/TCOM.1983.1095851
+func NewLaplacianPyramid(gray *image.Gray, levels int, scale float64) *LaplacianPyramid {
+	if scale <= 0 || scale >= 1 {
+		scale = 0.5
+	}
+	lp := &LaplacianPyramid{Scale: scale}
+	planes := gaussianPlanes(grayPlane(gray), levels, scale)
+	for l, p := range planes {
+		level := matrix.New(p.h, p.w)
+		var expanded plane
+		if l < len(planes)-1 {
+			expanded = planes[l+1].resample(p.w, p.h)
+		}
+		for y := 0; y < p.h; y++ {
+			for x := 0; x < p.w; x++ {
+				(*level)[y][x] = p.values[y*p.w+x]
+				if expanded.values != nil {
+					(*level)[y][x] -= expanded.values[y*p.w+x]
+				}
+			}
+		}
+		lp.Levels = append(lp.Levels, level)
+	}
+	return lp
+}
+
+// Reconstruct collapses the pyramid back into an image by expanding each
+// level and adding the next finer band, from the coarsest to the finest.
+func (lp *LaplacianPyramid) Reconstruct() *image.Gray {
+	if len(lp.Levels) == 0 {
+		return nil
+	}
+	var p plane
+	for l := len(lp.Levels) - 1; l >= 0; l-- {
+		h, w := lp.Levels[l].Size()
+		if l < len(lp.Levels)-1 {
+			p = p.resample(w, h)
+		} else {
+			p = plane{values: make([]float64, w*h), w: w, h: h}
+		}
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				p.values[y*w+x] += (*lp.Levels[l])[y][x]
+			}
+		}
+	}
+	return p.gray()
+}
@@ -0,0 +1,78 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestGaussianPyramid(t *testing.T) {
+	gray := noiseGray(64, 40)
+	cases := []struct {
+		scale float64
+		sizes []image.Point
+	}{
+		{0.5, []image.Point{{64, 40}, {32, 20}, {16, 10}, {8, 5}}},
+		{0.75, []image.Point{{64, 40}, {48, 30}, {36, 23}, {27, 17}}},
+	}
+	for _, c := range cases {
+		gp := NewGaussianPyramid(gray, 4, c.scale)
+		if len(gp.Levels) != len(c.sizes) {
+			t.Fatalf("scale %v: %d levels, want %d", c.scale, len(gp.Levels), len(c.sizes))
+		}
+		for l, size := range c.sizes {
+			if got := gp.Levels[l].Bounds().Size(); got != size {
+				t.Errorf("scale %v: level %d size = %v, want %v", c.scale, l, got, size)
+			}
+		}
+	}
+	//A constant image stays constant at every level
+	flat := image.NewGray(image.Rect(0, 0, 20, 20))
+	for i := range flat.Pix {
+		flat.Pix[i] = 77
+	}
+	for _, level := range NewGaussianPyramid(flat, 10, 0.5).Levels {
+		for _, v := range level.Pix {
+			if v != 77 {
+				t.Fatalf("constant image level has value %d", v)
+			}
+		}
+	}
+}
+
+func TestLaplacianPyramid_Reconstruct(t *testing.T) {
+	gray := noiseGray(37, 23)
+	for _, scale := range []float64{0.5, 0.7} {
+		lp := NewLaplacianPyramid(gray, 5, scale)
+		if len(lp.Levels) != 5 {
+			t.Fatalf("%d levels, want 5", len(lp.Levels))
+		}
+		out := lp.Reconstruct()
+		for i := range gray.Pix {
+			if out.Pix[i] != gray.Pix[i] {
+				t.Fatalf("scale %v: reconstructed pixel %d = %d, want %d", scale, i, out.Pix[i], gray.Pix[i])
+			}
+		}
+	}
+}
+
+func TestFiner(t *testing.T) {
+	//A ramp holds its own coordinate, so a resampled ramp holds the
+	//coordinates its pixels map back to
+	for _, size := range [][2]int{{40, 20}, {41, 21}, {64, 48}} {
+		n, m := size[0], size[1]
+		ramp := plane{values: make([]float64, n), w: n, h: 1}
+		for x := range ramp.values {
+			ramp.values[x] = float64(x)
+		}
+		coarse := ramp.resample(m, 1)
+		for x := 1; x < m-1; x++ {
+			if got, want := coarse.values[x], finer(float64(x), n, m); math.Abs(got-want) > 1e-9 {
+				t.Errorf("%d to %d: pixel %d maps to %v, want %v", n, m, x, want, got)
+			}
+		}
+	}
+	if got := finer(3, 40, 20); got != 6.5 {
+		t.Errorf("halving maps 3 to %v, want 6.5", got)
+	}
+}
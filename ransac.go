@@ -0,0 +1,421 @@
+package vision
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Point2D is a point with real coordinates.
+type Point2D struct {
+	X, Y float64
+}
+
+// Model is a parametric model fitted by RANSAC to a data set of Len()
+// points, which the implementation holds. Data points are referred to by
+// their indexes.
+type Model interface {
+	// Len returns the number of data points.
+	Len() int
+
+	// MinSamples returns the number of data points of a minimal sample.
+	MinSamples() int
+
+	// Fit estimates the model parameters from a minimal sample. It
+	// returns false if the sample is degenerate.
+	Fit(sample []int) ([]float64, bool)
+
+	// Refit estimates the model parameters from any number of inliers,
+	// usually by least squares. It returns false if they are degenerate.
+	Refit(inliers []int) ([]float64, bool)
+
+	// Residual returns the error of the data point i under the parameters.
+	Residual(params []float64, i int) float64
+}
+
+// RANSACMethod selects how RANSAC scores and improves the hypotheses.
+type RANSACMethod int
+
+const (
+	// MethodRANSAC maximizes the number of inliers.
+	MethodRANSAC RANSACMethod = iota
+
+	// MethodMSAC minimizes the sum of the squared residuals truncated at
+	// the threshold, which also rewards how well inliers fit.
+	MethodMSAC
+
+	// MethodLORANSAC scores as MSAC and runs an inner RANSAC over the
+	// inliers of every new best hypothesis: non-minimal samples drawn from
+	// them are fitted by least squares, and each fit is refitted to its own
+	// inliers until it stops improving.
+	MethodLORANSAC
+)
+
+const (
+	// loRepetitions is the number of samples drawn by the inner RANSAC of
+	// MethodLORANSAC.
+	loRepetitions = 10
+
+	// loSampleFactor bounds the size of the samples of the inner RANSAC as
+	// a multiple of the minimal sample, which is also limited to half of the
+	// inliers.
+	loSampleFactor = 7
+)
+
+// RANSACOptions configures RANSAC. A point is an inlier when its residual is
+// not larger than Threshold. The iterations stop when the probability of
+// having drawn an outlier free sample reaches Confidence or after
+// MaxIterations. Seed makes the sampling deterministic.
+type RANSACOptions struct {
+	Method        RANSACMethod
+	Threshold     float64
+	MaxIterations int
+	Confidence    float64
+	Seed          int64
+}
+
+// RANSACResult is the best model found by RANSAC, with its inlier mask, the
+// number of inliers, its cost under the chosen method and the number of
+// iterations performed.
+type RANSACResult struct {
+	Params     []float64
+	Inliers    []bool
+	NumInliers int
+	Cost       float64
+	Iterations int
+}
+
+// RANSAC robustly fits the model to its data, as described in
+// M. A. Fischler and R. C. Bolles, Random sample consensus,
+// Communications of the ACM, 24 (1981), pp. 381–395.
+// https://doi.org/10.1145/358669.358692
+//
+// with the MSAC cost of
+// P. H. S. Torr and A. Zisserman, MLESAC: A New Robust Estimator with Application to Estimating Image Geometry,
+// Computer Vision and Image Understanding, 78 (2000), pp. 138–156.
+// https://doi.org/10.1006/cviu.1999.0832
+//
+// and the local optimization of
+// O. Chum, J. Matas and J. Kittler, Locally Optimized RANSAC,
+// Pattern Recognition, DAGM (2003), pp. 236–243.
+// https://doi.org/10.1007/978-3-540-45243-0_31
+//
+// The best hypothesis is finally refitted to all its inliers. It returns nil
+// if there are not enough data points or no sample could be fitted.
+func RANSAC(m Model, opts RANSACOptions) *RANSACResult {
+	n, s := m.Len(), m.MinSamples()
+	if n < s || s <= 0 {
+		return nil
+	}
+	if opts.MaxIterations <= 0 {
+		opts.MaxIterations = 1000
+	}
+	if opts.Confidence <= 0 || opts.Confidence >= 1 {
+		opts.Confidence = 0.99
+	}
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	t2 := opts.Threshold * opts.Threshold
+
+	//score returns the cost of the parameters, lower being better, and the
+	//inliers
+	score := func(params []float64) (cost float64, inliers []int) {
+		for i := 0; i < n; i++ {
+			r := m.Residual(params, i)
+			r2 := r * r
+			if r2 <= t2 {
+				inliers = append(inliers, i)
+			} else if opts.Method == MethodRANSAC {
+				cost++
+			}
+			if opts.Method != MethodRANSAC {
+				cost += math.Min(r2, t2)
+			}
+		}
+		return
+	}
+
+	var best *RANSACResult
+	var bestInliers []int
+	//keep makes the scored parameters the best ones if they are better, or
+	//as good when ties are accepted
+	keep := func(params []float64, cost float64, inliers []int, ties bool) bool {
+		if best != nil && (cost > best.Cost || cost == best.Cost && !ties) {
+			return false
+		}
+		best = &RANSACResult{Params: params, Cost: cost, NumInliers: len(inliers)}
+		bestInliers = inliers
+		return true
+	}
+	//consider scores the parameters and keeps them if they are better
+	consider := func(params []float64, ties bool) bool {
+		cost, inliers := score(params)
+		return keep(params, cost, inliers, ties)
+	}
+	//optimize refits the best hypothesis to its inliers while it improves
+	optimize := func(limit int, ties bool) {
+		for k := 0; k < limit && len(bestInliers) >= s; k++ {
+			params, ok := m.Refit(bestInliers)
+			if !ok || !consider(params, ties) {
+				return
+			}
+		}
+	}
+
+	//inner runs the inner RANSAC on the inliers of the best hypothesis
+	inner := func() {
+		inliers := append([]int(nil), bestInliers...)
+		size := min(loSampleFactor*s, len(inliers)/2)
+		if size <= s {
+			optimize(10, false)
+			return
+		}
+		sample := make([]int, size)
+		for r := 0; r < loRepetitions; r++ {
+			for i := 0; i < size; i++ {
+				j := i + rnd.Intn(len(inliers)-i)
+				inliers[i], inliers[j] = inliers[j], inliers[i]
+				sample[i] = inliers[i]
+			}
+			//Refit the fit of the sample to its own inliers while it improves
+			params, ok := m.Refit(sample)
+			last := math.Inf(1)
+			for k := 0; ok && k < 10; k++ {
+				cost, inliers := score(params)
+				if cost >= last || len(inliers) < s {
+					break
+				}
+				last = cost
+				keep(params, cost, inliers, false)
+				params, ok = m.Refit(inliers)
+			}
+		}
+	}
+
+	indexes := make([]int, n)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sample := make([]int, s)
+	iterations := opts.MaxIterations
+	k := 0
+	for ; k < iterations; k++ {
+		//Partial Fisher-Yates shuffle for a sample of distinct indexes
+		for i := 0; i < s; i++ {
+			j := i + rnd.Intn(n-i)
+			indexes[i], indexes[j] = indexes[j], indexes[i]
+			sample[i] = indexes[i]
+		}
+		params, ok := m.Fit(sample)
+		if !ok || !consider(params, false) {
+			continue
+		}
+		if opts.Method == MethodLORANSAC {
+			inner()
+		}
+
+		//Adaptive number of iterations
+		w := float64(best.NumInliers) / float64(n)
+		if w >= 1 {
+			iterations = k + 1
+			continue
+		}
+		if p := math.Pow(w, float64(s)); p > 0 {
+			needed := math.Log(1-opts.Confidence) / math.Log(1-p)
+			if needed < float64(iterations) {
+				iterations = int(math.Ceil(needed))
+			}
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	optimize(1, true)
+	best.Iterations = k
+	best.Inliers = make([]bool, n)
+	for _, i := range bestInliers {
+		best.Inliers[i] = true
+	}
+	return best
+}
+
+// LineModel fits the line a x + b y + c = 0 to points, with params
+// (a, b, c) and a² + b² = 1. The residual is the distance to the line.
+type LineModel struct {
+	Points []Point2D
+}
+
+func (l *LineModel) Len() int        { return len(l.Points) }
+func (l *LineModel) MinSamples() int { return 2 }
+
+func (l *LineModel) Fit(sample []int) ([]float64, bool) {
+	return l.Refit(sample)
+}
+
+// Refit fits the line by total least squares.
+func (l *LineModel) Refit(inliers []int) ([]float64, bool) {
+	if len(inliers) < 2 {
+		return nil, false
+	}
+	var mx, my float64
+	for _, i := range inliers {
+		mx += l.Points[i].X
+		my += l.Points[i].Y
+	}
+	mx /= float64(len(inliers))
+	my /= float64(len(inliers))
+	var sxx, syy, sxy float64
+	for _, i := range inliers {
+		dx, dy := l.Points[i].X-mx, l.Points[i].Y-my
+		sxx += dx * dx
+		syy += dy * dy
+		sxy += dx * dy
+	}
+	if sxx+syy == 0 {
+		return nil, false
+	}
+	//The normal is the eigenvector of the scatter matrix with the smallest
+	//eigenvalue
+	_, vectors := symmetricEigen([][]float64{{sxx, sxy}, {sxy, syy}})
+	a, b := vectors[0][0], vectors[0][1]
+	return []float64{a, b, -a*mx - b*my}, true
+}
+
+func (l *LineModel) Residual(params []float64, i int) float64 {
+	p := l.Points[i]
+	return math.Abs(params[0]*p.X + params[1]*p.Y + params[2])
+}
+
+// CircleModel fits a circle to points, with params (cx, cy, r). The
+// residual is the distance to the circle.
+type CircleModel struct {
+	Points []Point2D
+}
+
+func (c *CircleModel) Len() int        { return len(c.Points) }
+func (c *CircleModel) MinSamples() int { return 3 }
+
+func (c *CircleModel) Fit(sample []int) ([]float64, bool) {
+	return c.Refit(sample)
+}
+
+// Refit fits the circle x² + y² + D x + E y + F = 0 by algebraic least
+// squares.
+func (c *CircleModel) Refit(inliers []int) ([]float64, bool) {
+	if len(inliers) < 3 {
+		return nil, false
+	}
+	a := [][]float64{make([]float64, 3), make([]float64, 3), make([]float64, 3)}
+	b := make([]float64, 3)
+	for _, i := range inliers {
+		p := c.Points[i]
+		row := []float64{p.X, p.Y, 1}
+		z := -(p.X*p.X + p.Y*p.Y)
+		for r := range row {
+			for k := range row {
+				a[r][k] += row[r] * row[k]
+			}
+			b[r] += row[r] * z
+		}
+	}
+	x, ok := solveLinear(a, b)
+	if !ok {
+		return nil, false
+	}
+	cx, cy := -x[0]/2, -x[1]/2
+	r2 := cx*cx + cy*cy - x[2]
+	if r2 <= 0 {
+		return nil, false
+	}
+	return []float64{cx, cy, math.Sqrt(r2)}, true
+}
+
+func (c *CircleModel) Residual(params []float64, i int) float64 {
+	p := c.Points[i]
+	return math.Abs(math.Hypot(p.X-params[0], p.Y-params[1]) - params[2])
+}
+
+// AffineModel fits the affine transform mapping Src[i] to Dst[i], with
+// params (a, b, c, d, e, f) such that x' = a x + b y + c and
+// y' = d x + e y + f. The residual is the transfer error.
+type AffineModel struct {
+	Src, Dst []Point2D
+}
+
+func (a *AffineModel) Len() int        { return len(a.Src) }
+func (a *AffineModel) MinSamples() int { return 3 }
+
+func (a *AffineModel) Fit(sample []int) ([]float64, bool) {
+	return a.Refit(sample)
+}
+
+// Refit fits the transform by linear least squares.
+func (a *AffineModel) Refit(inliers []int) ([]float64, bool) {
+	if len(inliers) < 3 {
+		return nil, false
+	}
+	params := make([]float64, 6)
+	for k := 0; k < 2; k++ {
+		m := [][]float64{make([]float64, 3), make([]float64, 3), make([]float64, 3)}
+		b := make([]float64, 3)
+		for _, i := range inliers {
+			row := []float64{a.Src[i].X, a.Src[i].Y, 1}
+			z := a.Dst[i].X
+			if k == 1 {
+				z = a.Dst[i].Y
+			}
+			for r := range row {
+				for c := range row {
+					m[r][c] += row[r] * row[c]
+				}
+				b[r] += row[r] * z
+			}
+		}
+		x, ok := solveLinear(m, b)
+		if !ok {
+			return nil, false
+		}
+		copy(params[3*k:], x)
+	}
+	return params, true
+}
+
+func (a *AffineModel) Residual(params []float64, i int) float64 {
+	s, d := a.Src[i], a.Dst[i]
+	x := params[0]*s.X + params[1]*s.Y + params[2]
+	y := params[3]*s.X + params[4]*s.Y + params[5]
+	return math.Hypot(x-d.X, y-d.Y)
+}
+
+// HomographyModel fits the homography mapping Src[i] to Dst[i], with the
+// nine params of the 3x3 matrix in row-major order. The residual is the
+// transfer error.
+type HomographyModel struct {
+	Src, Dst []Point2D
+}
+
+func (h *HomographyModel) Len() int        { return len(h.Src) }
+func (h *HomographyModel) MinSamples() int { return 4 }
+
+func (h *HomographyModel) Fit(sample []int) ([]float64, bool) {
+	return h.Refit(sample)
+}
+
+// Refit fits the homography with the normalized direct linear transform of
+// EstimateHomography.
+func (h *HomographyModel) Refit(inliers []int) ([]float64, bool) {
+	H, ok := estimateHomography(h.Src, h.Dst, inliers)
+	if !ok {
+		return nil, false
+	}
+	return H[:], true
+}
+
+func (h *HomographyModel) Residual(params []float64, i int) float64 {
+	s, d := h.Src[i], h.Dst[i]
+	w := params[6]*s.X + params[7]*s.Y + params[8]
+	if w == 0 {
+		return math.Inf(1)
+	}
+	x := (params[0]*s.X + params[1]*s.Y + params[2]) / w
+	y := (params[3]*s.X + params[4]*s.Y + params[5]) / w
+	return math.Hypot(x-d.X, y-d.Y)
+}
@@ -0,0 +1,131 @@
+package vision
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// withOutliers returns n points given by f at random parameters in [0, 1)
+// with gaussian noise, followed by m uniformly distributed outliers.
+func withOutliers(n, m int, noise float64, f func(t float64) Point2D) []Point2D {
+	rnd := rand.New(rand.NewSource(1))
+	points := make([]Point2D, 0, n+m)
+	for i := 0; i < n; i++ {
+		p := f(rnd.Float64())
+		p.X += rnd.NormFloat64() * noise
+		p.Y += rnd.NormFloat64() * noise
+		points = append(points, p)
+	}
+	for i := 0; i < m; i++ {
+		points = append(points, Point2D{rnd.Float64() * 100, rnd.Float64() * 100})
+	}
+	return points
+}
+
+func TestRANSACLine(t *testing.T) {
+	points := withOutliers(60, 40, 0.2, func(t float64) Point2D {
+		return Point2D{100 * t, 10 + 0.5*100*t}
+	})
+	for _, method := range []RANSACMethod{MethodRANSAC, MethodMSAC, MethodLORANSAC} {
+		result := RANSAC(&LineModel{points}, RANSACOptions{Method: method, Threshold: 1, Seed: 1})
+		if result == nil {
+			t.Fatalf("RANSAC(%d) = nil", method)
+		}
+		a, b, c := result.Params[0], result.Params[1], result.Params[2]
+		if slope, intercept := -a/b, -c/b; math.Abs(slope-0.5) > 0.01 || math.Abs(intercept-10) > 0.5 {
+			t.Errorf("RANSAC(%d) line y = %.3fx + %.3f, want y = 0.5x + 10", method, slope, intercept)
+		}
+		for i := 0; i < 60; i++ {
+			if !result.Inliers[i] {
+				t.Errorf("RANSAC(%d) point %d is not an inlier", method, i)
+			}
+		}
+		if result.NumInliers < 60 || result.NumInliers > 70 {
+			t.Errorf("RANSAC(%d) %d inliers, want about 60", method, result.NumInliers)
+		}
+	}
+}
+
+func TestRANSACDeterministic(t *testing.T) {
+	points := withOutliers(30, 30, 0.5, func(t float64) Point2D {
+		return Point2D{100 * t, 50}
+	})
+	opts := RANSACOptions{Method: MethodMSAC, Threshold: 2, Seed: 7}
+	r1 := RANSAC(&LineModel{points}, opts)
+	r2 := RANSAC(&LineModel{points}, opts)
+	if r1.Iterations != r2.Iterations || r1.Cost != r2.Cost {
+		t.Errorf("RANSAC() is not deterministic for a fixed seed")
+	}
+	if RANSAC(&LineModel{points[:1]}, opts) != nil {
+		t.Errorf("RANSAC() with too few points is not nil")
+	}
+}
+
+func TestRANSACCircle(t *testing.T) {
+	points := withOutliers(50, 30, 0.1, func(t float64) Point2D {
+		return Point2D{40 + 20*math.Cos(2*math.Pi*t), 60 + 20*math.Sin(2*math.Pi*t)}
+	})
+	result := RANSAC(&CircleModel{points}, RANSACOptions{Method: MethodLORANSAC, Threshold: 0.5, Seed: 1})
+	if result == nil {
+		t.Fatal("RANSAC() = nil")
+	}
+	want := []float64{40, 60, 20}
+	for i, w := range want {
+		if math.Abs(result.Params[i]-w) > 0.1 {
+			t.Errorf("circle params = %v, want %v", result.Params, want)
+			break
+		}
+	}
+}
+
+func TestRANSACAffine(t *testing.T) {
+	want := []float64{0.9, -0.3, 5, 0.2, 1.1, -3}
+	src := withOutliers(80, 0, 0, func(t float64) Point2D {
+		return Point2D{100 * t, 100 * math.Mod(7*t, 1)}
+	})
+	dst := make([]Point2D, len(src))
+	for i, p := range src {
+		dst[i] = Point2D{want[0]*p.X + want[1]*p.Y + want[2], want[3]*p.X + want[4]*p.Y + want[5]}
+		if i%4 == 0 {
+			dst[i].X += 30
+		}
+	}
+	result := RANSAC(&AffineModel{src, dst}, RANSACOptions{Method: MethodMSAC, Threshold: 0.5, Seed: 1})
+	if result == nil || result.NumInliers != 60 {
+		t.Fatalf("RANSAC() = %+v, want 60 inliers", result)
+	}
+	for i, w := range want {
+		if math.Abs(result.Params[i]-w) > 1e-6 {
+			t.Errorf("affine params = %v, want %v", result.Params, want)
+			break
+		}
+	}
+}
+
+func TestRANSACHomography(t *testing.T) {
+	want := []float64{1.2, 0.1, 10, -0.05, 0.9, 20, 0.001, -0.0005, 1}
+	src := withOutliers(50, 0, 0, func(t float64) Point2D {
+		return Point2D{200 * t, 200 * math.Mod(13*t, 1)}
+	})
+	model := &HomographyModel{Src: src, Dst: make([]Point2D, len(src))}
+	for i, p := range src {
+		w := want[6]*p.X + want[7]*p.Y + want[8]
+		model.Dst[i] = Point2D{
+			(want[0]*p.X + want[1]*p.Y + want[2]) / w,
+			(want[3]*p.X + want[4]*p.Y + want[5]) / w,
+		}
+		if i%5 == 0 {
+			model.Dst[i].Y -= 25
+		}
+	}
+	result := RANSAC(model, RANSACOptions{Threshold: 1, Seed: 1})
+	if result == nil || result.NumInliers != 40 {
+		t.Fatalf("RANSAC() = %+v, want 40 inliers", result)
+	}
+	for i := range src {
+		if i%5 != 0 && model.Residual(result.Params, i) > 1e-3 {
+			t.Errorf("transfer error of point %d = %g", i, model.Residual(result.Params, i))
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"sync"
+)
+
+// contribution is the weighted list of source pixels of an output pixel
+// along one axis.
+type contribution struct {
+	index  []int
+	weight []float64
+}
+
+// contributions computes the separable resampling weights from n source
+// pixels to m output pixels. When downscaling, the kernel is stretched by
+// the inverse scale so it also acts as the antialiasing prefilter.
+func contributions(n, m int, interpolation Interpolation) []contribution {
+	scale := float64(m) / float64(n)
+	out := make([]contribution, m)
+	for i := range out {
+		c := &out[i]
+		//Pixel centers are aligned, so the output pixel i covers the source
+		//interval [i / scale, (i + 1) / scale)
+		center := (float64(i)+0.5)/scale - 0.5
+		switch {
+		case interpolation == InterpolationNearest:
+			c.index = []int{min(int(math.Floor(center+0.5)), n-1)}
+			c.weight = []float64{1}
+			continue
+		case interpolation == InterpolationArea && scale < 1:
+			lo, hi := float64(i)/scale, float64(i+1)/scale
+			for j := int(math.Floor(lo)); float64(j) < hi; j++ {
+				overlap := math.Min(hi, float64(j+1)) - math.Max(lo, float64(j))
+				if overlap > 0 {
+					c.index = append(c.index, min(j, n-1))
+					c.weight = append(c.weight, overlap)
+				}
+			}
+		default:
+			support := float64(interpolation.radius())
+			stretch := 1.
+			if scale < 1 {
+				stretch = 1 / scale
+			}
+			support *= stretch
+			for j := int(math.Floor(center - support)); float64(j) <= center+support; j++ {
+				w := interpolation.weight((float64(j) - center) / stretch)
+				if w != 0 {
+					c.index = append(c.index, borderIndex(j, n, BorderReplicate))
+					c.weight = append(c.weight, w)
+				}
+			}
+		}
+		sum := 0.
+		for _, w := range c.weight {
+			sum += w
+		}
+		for k := range c.weight {
+			c.weight[k] /= sum
+		}
+	}
+	return out
+}
+
+// Resize scales the image to w-by-h pixels. Downscaling prefilters the image
+// with the stretched interpolation kernel, or averages the covered pixels
+// with InterpolationArea, so it does not alias. The output is an *image.Gray
+// for grayscale images and an *image.RGBA otherwise. It returns nil if the
+// image or the size is empty.
+func Resize(img image.Image, w, h int, interpolation Interpolation) image.Image {
+	if img == nil || w <= 0 || h <= 0 || img.Bounds().Empty() {
+		return nil
+	}
+	s := newSampler(img, WarpOptions{})
+	ch := s.channels
+	cx := contributions(s.w, w, interpolation)
+	cy := contributions(s.h, h, interpolation)
+
+	//Resample rows and then columns
+	tmp := make([]float64, w*s.h*ch)
+	out, pix := s.image(w, h)
+	wg := sync.WaitGroup{}
+	for y := 0; y < s.h; y++ {
+		wg.Add(1)
+		go func(y int) {
+			for x, c := range cx {
+				o := (y*w + x) * ch
+				for k, j := range c.index {
+					for i := 0; i < ch; i++ {
+						tmp[o+i] += c.weight[k] * float64(s.pix[(y*s.w+j)*ch+i])
+					}
+				}
+			}
+			wg.Done()
+		}(y)
+	}
+	wg.Wait()
+	for y, c := range cy {
+		wg.Add(1)
+		go func(y int, c contribution) {
+			var sum [4]float64
+			for x := 0; x < w; x++ {
+				sum = [4]float64{}
+				for k, j := range c.index {
+					for i := 0; i < ch; i++ {
+						sum[i] += c.weight[k] * tmp[(j*w+x)*ch+i]
+					}
+				}
+				o := (y*w + x) * ch
+				for i := 0; i < ch; i++ {
+					pix[o+i] = uint8(clamp(math.Floor(sum[i]+0.5), 0, 255))
+				}
+				if ch == 4 {
+					//Keep the color premultiplied
+					for i := 0; i < 3; i++ {
+						pix[o+i] = uint8(min(int(pix[o+i]), int(pix[o+3])))
+					}
+				}
+			}
+			wg.Done()
+		}(y, c)
+	}
+	wg.Wait()
+	return out
+}
+
+// Rotate rotates the image counterclockwise by angle degrees about its
+// center. With expand the output grows to contain the whole rotated image,
+// otherwise it keeps the input size and the corners are cropped. Uncovered
+// pixels are sampled according to opts, and rotations by multiples of 90
+// degrees with expand are exact.
+func Rotate(img image.Image, angle float64, expand bool, opts WarpOptions) image.Image {
+	if img == nil || img.Bounds().Empty() {
+		return nil
+	}
+	if expand && angle == math.Trunc(angle) && int(angle)%90 == 0 {
+		switch modulo(int(angle), 360) {
+		case 90:
+			return Rotate90(img)
+		case 180:
+			return Rotate180(img)
+		case 270:
+			return Rotate270(img)
+		}
+		return permute(img, false, func(x, y, w, h int) (int, int) { return x, y })
+	}
+	b := img.Bounds()
+	w, h := float64(b.Dx()), float64(b.Dy())
+	θ := angle * math.Pi / 180
+	cos, sin := math.Cos(θ), math.Sin(θ)
+	size := b.Size()
+	if expand {
+		size.X = int(math.Ceil(math.Abs(w*cos) + math.Abs(h*sin) - 1e-9))
+		size.Y = int(math.Ceil(math.Abs(w*sin) + math.Abs(h*cos) - 1e-9))
+	}
+	//Rotate about the source center into the output center, with the y axis
+	//pointing down
+	cx, cy := (w-1)/2, (h-1)/2
+	ox, oy := float64(size.X-1)/2, float64(size.Y-1)/2
+	a := Affine{
+		cos, sin, ox - cos*cx - sin*cy,
+		-sin, cos, oy + sin*cx - cos*cy,
+	}
+	return WarpAffine(img, a, size, opts)
+}
+
+// permute builds an image whose pixel (x, y) is the source pixel
+// source(x, y, w, h), where w and h are the source size. With transpose the
+// output size is the transposed source size.
+func permute(img image.Image, transpose bool, source func(x, y, w, h int) (int, int)) image.Image {
+	if img == nil {
+		return nil
+	}
+	s := newSampler(img, WarpOptions{})
+	w, h := s.w, s.h
+	if transpose {
+		w, h = h, w
+	}
+	out, pix := s.image(w, h)
+	ch := s.channels
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sx, sy := source(x, y, s.w, s.h)
+			o, i := (y*w+x)*ch, (sy*s.w+sx)*ch
+			copy(pix[o:o+ch], s.pix[i:i+ch])
+		}
+	}
+	return out
+}
+
+// Rotate90 rotates the image counterclockwise by 90 degrees.
+func Rotate90(img image.Image) image.Image {
+	return permute(img, true, func(x, y, w, h int) (int, int) { return w - 1 - y, x })
+}
+
+// Rotate180 rotates the image by 180 degrees.
+func Rotate180(img image.Image) image.Image {
+	return permute(img, false, func(x, y, w, h int) (int, int) { return w - 1 - x, h - 1 - y })
+}
+
+// Rotate270 rotates the image clockwise by 90 degrees.
+func Rotate270(img image.Image) image.Image {
+	return permute(img, true, func(x, y, w, h int) (int, int) { return y, h - 1 - x })
+}
+
+// FlipH mirrors the image horizontally.
+func FlipH(img image.Image) image.Image {
+	return permute(img, false, func(x, y, w, h int) (int, int) { return w - 1 - x, y })
+}
+
+// FlipV mirrors the image vertically.
+func FlipV(img image.Image) image.Image {
+	return permute(img, false, func(x, y, w, h int) (int, int) { return x, h - 1 - y })
+}
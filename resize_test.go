@@ -0,0 +1,111 @@
+package vision
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestResize(t *testing.T) {
+	//A constant image stays constant for every interpolation
+	gray := image.NewGray(image.Rect(0, 0, 20, 10))
+	for i := range gray.Pix {
+		gray.Pix[i] = 100
+	}
+	interpolations := []Interpolation{InterpolationNearest, InterpolationBilinear, InterpolationBicubic, InterpolationLanczos, InterpolationArea}
+	for _, interpolation := range interpolations {
+		for _, size := range []image.Point{{7, 3}, {20, 10}, {45, 31}} {
+			out := Resize(gray, size.X, size.Y, interpolation).(*image.Gray)
+			if !out.Bounds().Size().Eq(size) {
+				t.Fatalf("Resize(%d) size = %v, want %v", interpolation, out.Bounds().Size(), size)
+			}
+			for i, v := range out.Pix {
+				if v != 100 {
+					t.Fatalf("Resize(%d) to %v at %d = %d, want 100", interpolation, size, i, v)
+				}
+			}
+		}
+	}
+
+	//Halving with area averages 2x2 blocks
+	out := Resize(noiseGray(8, 8), 4, 4, InterpolationArea).(*image.Gray)
+	src := noiseGray(8, 8)
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			sum := int(src.GrayAt(2*x, 2*y).Y) + int(src.GrayAt(2*x+1, 2*y).Y) +
+				int(src.GrayAt(2*x, 2*y+1).Y) + int(src.GrayAt(2*x+1, 2*y+1).Y)
+			if got, want := float64(out.GrayAt(x, y).Y), float64(sum)/4; math.Abs(got-want) > 0.5 {
+				t.Errorf("area at (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestResizeAntialias(t *testing.T) {
+	//A one pixel checkerboard downscaled by 4 must average to gray instead
+	//of aliasing to black or white
+	gray := image.NewGray(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			gray.Pix[y*64+x] = uint8(255 * ((x + y) % 2))
+		}
+	}
+	for _, interpolation := range []Interpolation{InterpolationBilinear, InterpolationBicubic, InterpolationLanczos, InterpolationArea} {
+		out := Resize(gray, 16, 16, interpolation).(*image.Gray)
+		for i, v := range out.Pix {
+			if math.Abs(float64(v)-127.5) > 8 {
+				t.Fatalf("Resize(%d) at %d = %d, want about 128", interpolation, i, v)
+			}
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	rgba := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for i := range rgba.Pix {
+		rgba.Pix[i] = uint8(i)
+	}
+	at := func(img image.Image, x, y int) color.RGBA {
+		return img.(*image.RGBA).RGBAAt(x, y)
+	}
+	//Pixel (2, 0) is the top right corner
+	corner := rgba.RGBAAt(2, 0)
+	cases := []struct {
+		name string
+		img  image.Image
+		size image.Point
+		at   image.Point
+	}{
+		{"Rotate90", Rotate90(rgba), image.Pt(2, 3), image.Pt(0, 0)},
+		{"Rotate180", Rotate180(rgba), image.Pt(3, 2), image.Pt(0, 1)},
+		{"Rotate270", Rotate270(rgba), image.Pt(2, 3), image.Pt(1, 2)},
+		{"FlipH", FlipH(rgba), image.Pt(3, 2), image.Pt(0, 0)},
+		{"FlipV", FlipV(rgba), image.Pt(3, 2), image.Pt(2, 1)},
+		{"Rotate(90)", Rotate(rgba, 90, true, WarpOptions{}), image.Pt(2, 3), image.Pt(0, 0)},
+		{"Rotate(-90)", Rotate(rgba, -90, true, WarpOptions{}), image.Pt(2, 3), image.Pt(1, 2)},
+	}
+	for _, c := range cases {
+		if size := c.img.Bounds().Size(); !size.Eq(c.size) {
+			t.Errorf("%s size = %v, want %v", c.name, size, c.size)
+			continue
+		}
+		if got := at(c.img, c.at.X, c.at.Y); got != corner {
+			t.Errorf("%s corner at %v = %v, want %v", c.name, c.at, got, corner)
+		}
+	}
+
+	//An arbitrary rotation through the warp agrees with the exact one
+	gray := noiseGray(9, 9)
+	exact := Rotate90(gray).(*image.Gray)
+	warped := Rotate(gray, 90+1e-9, false, WarpOptions{Interpolation: InterpolationBilinear}).(*image.Gray)
+	for i := range exact.Pix {
+		if d := int(exact.Pix[i]) - int(warped.Pix[i]); d < -1 || d > 1 {
+			t.Fatalf("Rotate(90) at %d = %d, want %d", i, warped.Pix[i], exact.Pix[i])
+		}
+	}
+	expanded := Rotate(gray, 45, true, WarpOptions{})
+	if size := expanded.Bounds().Size(); size.X != 13 || size.Y != 13 {
+		t.Errorf("Rotate(45) expanded size = %v, want 13x13", size)
+	}
+}
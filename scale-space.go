@@ -0,0 +1,241 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// BlobDetector is the scale-space detector that found a blob.
+type BlobDetector int
+
+const (
+	// DetectorLoG is BlobLoG.
+	DetectorLoG BlobDetector = iota
+	// DetectorDoG is BlobDoG.
+	DetectorDoG
+	// DetectorDoH is BlobDoH.
+	DetectorDoH
+)
+
+// ScaleBlob is a blob found by a scale-space detector. X and Y are the
+// coordinates of its center, Sigma is the standard deviation of the kernel
+// that detected it, Response is the value of the normalized detector at the
+// blob and Detector is the detector that found it.
+type ScaleBlob struct {
+	X, Y     float64
+	Sigma    float64
+	Response float64
+	Detector BlobDetector
+}
+
+// Radius returns the approximate radius of the blob, which is Sigma√2 for
+// the Laplacian detectors and Sigma for the determinant of the Hessian.
+func (b ScaleBlob) Radius() float64 {
+	if b.Detector == DetectorDoH {
+		return b.Sigma
+	}
+	return b.Sigma * math.Sqrt2
+}
+
+// BlobLoG detects bright blobs on a dark background as the local maxima of
+// the scale-normalized Laplacian of gaussian -σ²∇²G, evaluated at numSigma
+// standard deviations evenly spaced between minSigma and maxSigma. The image
+// is scaled to [0, 1], so a threshold around 0.1 is usual. Blobs overlapping
+// a larger blob by more than the overlap fraction of their area are removed.
+func BlobLoG(gray *image.Gray, minSigma, maxSigma float64, numSigma int, threshold, overlap float64) []ScaleBlob {
+	sigmas := linearSigmas(minSigma, maxSigma, numSigma)
+	base := unitPlane(gray)
+	stack := make([]plane, len(sigmas))
+	for i, σ := range sigmas {
+		l := plane{values: gaussianBlur(base.values, base.w, base.h, σ), w: base.w, h: base.h}
+		stack[i] = plane{values: make([]float64, base.w*base.h), w: base.w, h: base.h}
+		for y := 0; y < base.h; y++ {
+			for x := 0; x < base.w; x++ {
+				lxx, lyy, _ := l.hessian(x, y)
+				stack[i].values[y*base.w+x] = -σ * σ * (lxx + lyy)
+			}
+		}
+	}
+	return scaleSpaceBlobs(stack, sigmas, DetectorLoG, threshold, overlap)
+}
+
+// BlobDoG detects bright blobs on a dark background as the local maxima of
+// the difference of gaussians, an approximation of the Laplacian of gaussian.
+// The standard deviations grow geometrically from minSigma by sigmaRatio, 1.6
+// being the usual ratio, until they exceed maxSigma. The image is scaled to
+// [0, 1], so a threshold around 0.1 is usual. Blobs overlapping a larger blob
+// by more than the overlap fraction of their area are removed.
+func BlobDoG(gray *image.Gray, minSigma, maxSigma, sigmaRatio, threshold, overlap float64) []ScaleBlob {
+	if sigmaRatio <= 1 {
+		sigmaRatio = 1.6
+	}
+	sigmas := []float64{minSigma}
+	for sigmas[len(sigmas)-1] < maxSigma {
+		sigmas = append(sigmas, sigmas[len(sigmas)-1]*sigmaRatio)
+	}
+	sigmas = append(sigmas, sigmas[len(sigmas)-1]*sigmaRatio)
+	base := unitPlane(gray)
+	blurred := make([][]float64, len(sigmas))
+	for i, σ := range sigmas {
+		blurred[i] = gaussianBlur(base.values, base.w, base.h, σ)
+	}
+	//Normalizing by 1/(k - 1) makes the difference approximate σ²∇²G
+	stack := make([]plane, len(sigmas)-1)
+	for i := range stack {
+		stack[i] = plane{values: make([]float64, base.w*base.h), w: base.w, h: base.h}
+		for j := range stack[i].values {
+			stack[i].values[j] = (blurred[i][j] - blurred[i+1][j]) / (sigmaRatio - 1)
+		}
+	}
+	return scaleSpaceBlobs(stack, sigmas[:len(stack)], DetectorDoG, threshold, overlap)
+}
+
+// BlobDoH detects blobs as the local maxima of the scale-normalized
+// determinant of the Hessian σ⁴(LxxLyy - Lxy²), evaluated at numSigma
+// standard deviations evenly spaced between minSigma and maxSigma. Unlike the
+// Laplacian detectors, it finds both bright and dark blobs. The image is
+// scaled to [0, 1], so a threshold around 0.01 is usual. Blobs overlapping a
+// larger blob by more than the overlap fraction of their area are removed.
+func BlobDoH(gray *image.Gray, minSigma, maxSigma float64, numSigma int, threshold, overlap float64) []ScaleBlob {
+	sigmas := linearSigmas(minSigma, maxSigma, numSigma)
+	base := unitPlane(gray)
+	stack := make([]plane, len(sigmas))
+	for i, σ := range sigmas {
+		l := plane{values: gaussianBlur(base.values, base.w, base.h, σ), w: base.w, h: base.h}
+		stack[i] = plane{values: make([]float64, base.w*base.h), w: base.w, h: base.h}
+		s4 := σ * σ * σ * σ
+		for y := 0; y < base.h; y++ {
+			for x := 0; x < base.w; x++ {
+				lxx, lyy, lxy := l.hessian(x, y)
+				stack[i].values[y*base.w+x] = s4 * (lxx*lyy - lxy*lxy)
+			}
+		}
+	}
+	return scaleSpaceBlobs(stack, sigmas, DetectorDoH, threshold, overlap)
+}
+
+// linearSigmas returns n standard deviations evenly spaced from a to b.
+func linearSigmas(a, b float64, n int) []float64 {
+	if n < 2 || b <= a {
+		return []float64{a}
+	}
+	sigmas := make([]float64, n)
+	for i := range sigmas {
+		sigmas[i] = a + (b-a)*float64(i)/float64(n-1)
+	}
+	return sigmas
+}
+
+// unitPlane converts a grayscale image to a plane with values in [0, 1].
+func unitPlane(gray *image.Gray) plane {
+	p := grayPlane(gray)
+	for i := range p.values {
+		p.values[i] /= 255
+	}
+	return p
+}
+
+// hessian returns the second derivatives of the plane at (x, y) by central
+// differences, extending the plane at its borders.
+func (p plane) hessian(x, y int) (lxx, lyy, lxy float64) {
+	at := func(x, y int) float64 {
+		return p.values[min(max(y, 0), p.h-1)*p.w+min(max(x, 0), p.w-1)]
+	}
+	c := at(x, y)
+	lxx = at(x+1, y) - 2*c + at(x-1, y)
+	lyy = at(x, y+1) - 2*c + at(x, y-1)
+	lxy = (at(x+1, y+1) - at(x-1, y+1) - at(x+1, y-1) + at(x-1, y-1)) / 4
+	return
+}
+
+// scaleSpaceBlobs finds the maxima above the threshold over the 3x3x3
+// neighborhoods of the scale-space stack of the detector and prunes the
+// overlapping ones.
+func scaleSpaceBlobs(stack []plane, sigmas []float64, detector BlobDetector, threshold, overlap float64) []ScaleBlob {
+	blobs := make([]ScaleBlob, 0)
+	if len(stack) == 0 {
+		return blobs
+	}
+	w, h := stack[0].w, stack[0].h
+	for s := range stack {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				v := stack[s].values[y*w+x]
+				if v <= threshold {
+					continue
+				}
+				maximum := true
+				for k := max(s-1, 0); k <= min(s+1, len(stack)-1) && maximum; k++ {
+					for j := max(y-1, 0); j <= min(y+1, h-1) && maximum; j++ {
+						for i := max(x-1, 0); i <= min(x+1, w-1); i++ {
+							if stack[k].values[j*w+i] > v {
+								maximum = false
+								break
+							}
+						}
+					}
+				}
+				if maximum {
+					blobs = append(blobs, ScaleBlob{X: float64(x), Y: float64(y), Sigma: sigmas[s], Response: v, Detector: detector})
+				}
+			}
+		}
+	}
+	return pruneBlobs(blobs, overlap)
+}
+
+// pruneBlobs removes the smaller blob of every pair whose overlap exceeds
+// the given fraction of the area of the smaller one, and returns the
+// remaining blobs sorted by decreasing response.
+func pruneBlobs(blobs []ScaleBlob, overlap float64) []ScaleBlob {
+	sort.SliceStable(blobs, func(i, j int) bool { return blobs[i].X < blobs[j].X })
+	maxRadius := 0.
+	for _, b := range blobs {
+		maxRadius = math.Max(maxRadius, b.Radius())
+	}
+	removed := make([]bool, len(blobs))
+	for i := range blobs {
+		for j := i + 1; j < len(blobs) && blobs[j].X-blobs[i].X <= 2*maxRadius; j++ {
+			if removed[i] || removed[j] {
+				continue
+			}
+			if circleOverlap(blobs[i], blobs[j]) <= overlap {
+				continue
+			}
+			if blobs[i].Sigma > blobs[j].Sigma {
+				removed[j] = true
+			} else {
+				removed[i] = true
+			}
+		}
+	}
+	out := make([]ScaleBlob, 0, len(blobs))
+	for i, b := range blobs {
+		if !removed[i] {
+			out = append(out, b)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Response > out[j].Response })
+	return out
+}
+
+// circleOverlap returns the area of the intersection of the circles of two
+// blobs divided by the area of the smaller circle.
+func circleOverlap(a, b ScaleBlob) float64 {
+	r1, r2 := a.Radius(), b.Radius()
+	if r1 > r2 {
+		r1, r2 = r2, r1
+	}
+	d := math.Hypot(a.X-b.X, a.Y-b.Y)
+	switch {
+	case d >= r1+r2:
+		return 0
+	case d <= r2-r1:
+		return 1
+	}
+	a1 := r1 * r1 * math.Acos((d*d+r1*r1-r2*r2)/(2*d*r1))
+	a2 := r2 * r2 * math.Acos((d*d+r2*r2-r1*r1)/(2*d*r2))
+	a3 := 0.5 * math.Sqrt((-d+r1+r2)*(d+r1-r2)*(d-r1+r2)*(d+r1+r2))
+	return (a1 + a2 - a3) / (math.Pi * r1 * r1)
+}
@@ -0,0 +1,65 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestScaleSpaceBlobs(t *testing.T) {
+	//Two bright disks of radii 5 and 11 on a dark background
+	gray := image.NewGray(image.Rect(0, 0, 100, 60))
+	disks := []struct{ x, y, r int }{{25, 30, 5}, {65, 30, 11}}
+	for _, d := range disks {
+		for y := d.y - d.r; y <= d.y+d.r; y++ {
+			for x := d.x - d.r; x <= d.x+d.r; x++ {
+				if dot(x-d.x, y-d.y) <= d.r*d.r {
+					gray.Pix[y*100+x] = 255
+				}
+			}
+		}
+	}
+	detectors := []struct {
+		name  string
+		blobs []ScaleBlob
+	}{
+		{"BlobLoG", BlobLoG(gray, 2, 12, 21, 0.1, 0.5)},
+		{"BlobDoG", BlobDoG(gray, 2, 12, 1.2, 0.1, 0.5)},
+		{"BlobDoH", BlobDoH(gray, 2, 12, 21, 0.01, 0.5)},
+	}
+	for _, d := range detectors {
+		for _, disk := range disks {
+			found := false
+			for _, b := range d.blobs {
+				if math.Hypot(b.X-float64(disk.x), b.Y-float64(disk.y)) <= 1 &&
+					math.Abs(b.Radius()-float64(disk.r)) <= 0.35*float64(disk.r) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("%s() missed the disk %v in %v", d.name, disk, d.blobs)
+			}
+		}
+	}
+}
+
+func TestCircleOverlap(t *testing.T) {
+	a := ScaleBlob{X: 0, Y: 0, Sigma: 1}
+	if got := circleOverlap(a, ScaleBlob{X: 10, Sigma: 1}); got != 0 {
+		t.Errorf("disjoint overlap = %v", got)
+	}
+	if got := circleOverlap(a, ScaleBlob{X: 0.1, Sigma: 3}); got != 1 {
+		t.Errorf("contained overlap = %v", got)
+	}
+	if got := circleOverlap(a, a); math.Abs(got-1) > 1e-9 {
+		t.Errorf("self overlap = %v", got)
+	}
+	//The radius of a blob of the determinant of the Hessian is its sigma
+	if got := circleOverlap(a, ScaleBlob{X: 2.5, Sigma: 1}); got == 0 {
+		t.Errorf("overlap of Laplacian blobs = %v", got)
+	}
+	doh := ScaleBlob{Sigma: 1, Detector: DetectorDoH}
+	if got := circleOverlap(doh, ScaleBlob{X: 2.5, Sigma: 1, Detector: DetectorDoH}); got != 0 {
+		t.Errorf("overlap of Hessian blobs = %v", got)
+	}
+}
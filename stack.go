@@ -2,34 +2,49 @@ package vision
 
 import "image"
 
+// Stack splits the image into tiles of the size of R, in row-major order
+// from the top left corner of its bounds. Tiles exceeding the bounds are
+// shifted back inside them, so every tile has the size of R when the image
+// is at least as large.
 func Stack(I image.RGBA, R image.Rectangle) (O []image.Image) {
+	frames := stackFrames(I.Bounds(), R)
+	O = make([]image.Image, len(frames))
+	for i, frame := range frames {
+		O[i] = I.SubImage(frame)
+	}
+	return
+}
+
+// stackFrames returns the tiles of size R covering the bounds b in row-major
+// order. Tiles exceeding b are shifted back inside it.
+func stackFrames(b image.Rectangle, R image.Rectangle) (frames []image.Rectangle) {
 	m, n := 0, 0
 	w, h := R.Dx(), R.Dy()
-	if I.Bounds().Dy()%h == 0 {
-		m = int(I.Bounds().Dy() / h)
+	if b.Dy()%h == 0 {
+		m = int(b.Dy() / h)
 	} else {
-		m = int(I.Bounds().Dy()/h) + 1
+		m = int(b.Dy()/h) + 1
 	}
-	if I.Bounds().Dx()%w == 0 {
-		n = int(I.Bounds().Dx() / w)
+	if b.Dx()%w == 0 {
+		n = int(b.Dx() / w)
 	} else {
-		n = int(I.Bounds().Dx()/w) + 1
+		n = int(b.Dx()/w) + 1
 	}
-	O = make([]image.Image, m*n)
+	frames = make([]image.Rectangle, m*n)
 	var frame, intersec image.Rectangle
 	for c := 0; c < n; c++ {
 		for r := 0; r < m; r++ {
-			frame = image.Rect(c*w, r*h, (c+1)*w, (r+1)*h)
-			intersec = I.Bounds().Intersect(frame)
+			frame = image.Rect(c*w, r*h, (c+1)*w, (r+1)*h).Add(b.Min)
+			intersec = b.Intersect(frame)
 			if !intersec.Empty() {
-				if frame.Max.X > I.Bounds().Max.X {
+				if frame.Max.X > b.Max.X {
 					frame = frame.Sub(image.Pt(frame.Dx()-intersec.Dx(), 0))
 				}
-				if frame.Max.Y > I.Bounds().Max.Y {
+				if frame.Max.Y > b.Max.Y {
 					frame = frame.Sub(image.Pt(0, frame.Dy()-intersec.Dy()))
 				}
 			}
-			O[r*n+c] = I.SubImage(frame)
+			frames[r*n+c] = frame
 
 		}
 	}
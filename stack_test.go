@@ -22,3 +22,21 @@ func TestStack(t *testing.T) {
 		io.SavePNG(O[i], fmt.Sprintf("/home/joaowiciuk/Imagens/lenna.jpg (%d).png", i))
 	}
 }
+
+func TestStackOrigin(t *testing.T) {
+	//The tiles start at the top left corner of the bounds, not at the origin
+	img := image.NewRGBA(image.Rect(10, 20, 30, 30))
+	O := Stack(*img, image.Rect(0, 0, 8, 8))
+	if len(O) != 6 {
+		t.Fatalf("Stack() returned %d tiles, want 6", len(O))
+	}
+	expected := []image.Rectangle{
+		image.Rect(10, 20, 18, 28), image.Rect(18, 20, 26, 28), image.Rect(22, 20, 30, 28),
+		image.Rect(10, 22, 18, 30), image.Rect(18, 22, 26, 30), image.Rect(22, 22, 30, 30),
+	}
+	for i := range O {
+		if O[i].Bounds() != expected[i] {
+			t.Errorf("tile %d: expected %v, got %v", i, expected[i], O[i].Bounds())
+		}
+	}
+}
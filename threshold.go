@@ -3,6 +3,7 @@ package vision
 import (
 	"image"
 	"image/draw"
+	"math"
 )
 
 func Threshold(img *image.Image, level uint8) (out *image.Gray) {
@@ -18,3 +19,277 @@ func Threshold(img *image.Image, level uint8) (out *image.Gray) {
 	}
 	return
 }
+
+// binarize thresholds a grayscale image at a global level. Pixels above the
+// level become white and the others black.
+func binarize(gray *image.Gray, level uint8) *image.Gray {
+	b := gray.Bounds()
+	out := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for i, v := range grayValues(gray) {
+		if v > level {
+			out.Pix[i] = 255
+		}
+	}
+	return out
+}
+
+// histogram returns the 256 bins histogram of a grayscale image.
+func histogram(gray *image.Gray) (h [256]int) {
+	for _, v := range grayValues(gray) {
+		h[v]++
+	}
+	return
+}
+
+// Otsu thresholds the image at the level that maximizes the between-class
+// variance of its histogram, as described in
+// N. Otsu, A Threshold Selection Method from Gray-Level Histograms,
+// IEEE Transactions on Systems, Man, and Cybernetics, 9 (1979), pp. 62–66.
+// https://doi.org/10.1109/TSMC.1979.4310076
+func Otsu(gray *image.Gray) (out *image.Gray, level uint8) {
+	_, levels := MultiOtsu(gray, 2)
+	level = levels[0]
+	return binarize(gray, level), level
+}
+
+// MultiOtsu splits the histogram into the given number of classes maximizing
+// the between-class variance and returns the classes - 1 chosen levels. The
+// output image maps the classes to evenly spaced gray levels from black to
+// white, with a pixel belonging to class k when it is above the level k - 1
+// and not above the level k.
+func MultiOtsu(gray *image.Gray, classes int) (out *image.Gray, levels []uint8) {
+	if classes < 2 {
+		classes = 2
+	}
+	if classes > 256 {
+		classes = 256
+	}
+	h := histogram(gray)
+
+	//Cumulative zeroth and first order moments
+	var p, s [257]float64
+	for i, c := range h {
+		p[i+1] = p[i] + float64(c)
+		s[i+1] = s[i] + float64(i*c)
+	}
+	variance := func(a, b int) float64 {
+		w := p[b+1] - p[a]
+		if w == 0 {
+			return 0
+		}
+		m := s[b+1] - s[a]
+		return m * m / w
+	}
+
+	//Dynamic programming over the last level of each class:
+	//best[k][i] is the score of splitting [0, i] into k+1 classes
+	best := make([][256]float64, classes)
+	from := make([][256]int, classes)
+	for i := 0; i < 256; i++ {
+		best[0][i] = variance(0, i)
+	}
+	for k := 1; k < classes; k++ {
+		for i := k; i < 256; i++ {
+			best[k][i] = math.Inf(-1)
+			for j := k - 1; j < i; j++ {
+				if v := best[k-1][j] + variance(j+1, i); v > best[k][i] {
+					best[k][i] = v
+					from[k][i] = j
+				}
+			}
+		}
+	}
+	levels = make([]uint8, classes-1)
+	i := 255
+	for k := classes - 1; k > 0; k-- {
+		i = from[k][i]
+		levels[k-1] = uint8(i)
+	}
+
+	b := gray.Bounds()
+	out = image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	var lut [256]uint8
+	for v := range lut {
+		k := 0
+		for k < len(levels) && uint8(v) > levels[k] {
+			k++
+		}
+		lut[v] = uint8(k * 255 / (classes - 1))
+	}
+	for i, v := range grayValues(gray) {
+		out.Pix[i] = lut[v]
+	}
+	return
+}
+
+// Triangle thresholds the image with the triangle method, which chooses the
+// level farthest from the line joining the histogram peak to its farthest
+// non-empty end, as described in
+// G. W. Zack, W. E. Rogers and S. A. Latt, Automatic measurement of sister chromatid exchange frequency,
+// Journal of Histochemistry & Cytochemistry, 25 (1977), pp. 741–753.
+// https://doi.org/10.1177/25.7.70454
+func Triangle(gray *image.Gray) (out *image.Gray, level uint8) {
+	h := histogram(gray)
+	first, last, peak := -1, 0, 0
+	for i, c := range h {
+		if c > 0 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+		if c > h[peak] {
+			peak = i
+		}
+	}
+	if first < 0 || first == last {
+		return binarize(gray, uint8(peak)), uint8(peak)
+	}
+
+	//Work on the longest side of the peak, flipping the histogram if needed
+	flip := peak-first > last-peak
+	at := func(i int) float64 {
+		if flip {
+			return float64(h[255-i])
+		}
+		return float64(h[i])
+	}
+	start, end := peak, last
+	if flip {
+		start, end = 255-peak, 255-first
+	}
+	dx, dy := float64(end-start), -at(start)
+	best, bestDist := start, 0.
+	for i := start + 1; i <= end; i++ {
+		//Distance from (i, h[i]) to the line through (start, h[start]) and (end, 0), up to a constant
+		d := dy*float64(i-end) - dx*at(i)
+		if d > bestDist {
+			best, bestDist = i, d
+		}
+	}
+	if flip {
+		best = 255 - best
+	}
+	level = uint8(best)
+	return binarize(gray, level), level
+}
+
+// Kapur thresholds the image at the level that maximizes the sum of the
+// entropies of the background and foreground histograms, as described in
+// J. N. Kapur, P. K. Sahoo and A. K. C. Wong, A new method for gray-level picture thresholding using the entropy of the histogram,
+// Computer Vision, Graphics, and Image Processing, 29 (1985), pp. 273–285.
+// https://doi.org/10.1016/0734-189X(85)90125-2
+func Kapur(gray *image.Gray) (out *image.Gray, level uint8) {
+	h := histogram(gray)
+	total := 0.
+	for _, c := range h {
+		total += float64(c)
+	}
+	if total == 0 {
+		return binarize(gray, 0), 0
+	}
+
+	//Cumulative probability and cumulative p*ln(p)
+	var p, e [257]float64
+	for i, c := range h {
+		pi := float64(c) / total
+		p[i+1] = p[i] + pi
+		e[i+1] = e[i]
+		if pi > 0 {
+			e[i+1] += pi * math.Log(pi)
+		}
+	}
+	best := math.Inf(-1)
+	for t := 0; t < 255; t++ {
+		pb, pf := p[t+1], 1-p[t+1]
+		if pb <= 0 || pf <= 0 {
+			continue
+		}
+		hb := math.Log(pb) - e[t+1]/pb
+		hf := math.Log(pf) - (e[256]-e[t+1])/pf
+		if hb+hf > best {
+			best = hb + hf
+			level = uint8(t)
+		}
+	}
+	return binarize(gray, level), level
+}
+
+// localThreshold binarizes the image against a per pixel level given by f,
+// which receives the mean and the standard deviation of the window of the
+// given size centered at each pixel. It returns the binary image and the
+// levels used.
+func localThreshold(gray *image.Gray, window int, f func(mean, std float64) float64) (out, levels *image.Gray) {
+	b := gray.Bounds()
+	w, h := b.Dx(), b.Dy()
+	values := grayValues(gray)
+	ii := NewIntegralImage(gray)
+	r := window / 2
+	out = image.NewGray(image.Rect(0, 0, w, h))
+	levels = image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := b.Min.Add(image.Pt(x, y))
+			win := image.Rect(p.X-r, p.Y-r, p.X+r+1, p.Y+r+1)
+			t := f(ii.Mean(win), math.Sqrt(ii.Variance(win)))
+			levels.Pix[y*w+x] = uint8(clamp(math.Floor(t+0.5), 0, 255))
+			if float64(values[y*w+x]) > t {
+				out.Pix[y*w+x] = 255
+			}
+		}
+	}
+	return
+}
+
+// Niblack thresholds each pixel at m + k*s, where m and s are the mean and
+// standard deviation of the window of the given size centered at it. A k of
+// -0.2 is the usual choice for dark text on a light background.
+func Niblack(gray *image.Gray, window int, k float64) (out, levels *image.Gray) {
+	return localThreshold(gray, window, func(mean, std float64) float64 {
+		return mean + k*std
+	})
+}
+
+// Sauvola thresholds each pixel at m * (1 + k * (s/r - 1)), where m and s are
+// the mean and standard deviation of the window of the given size centered at
+// it and r is the dynamic range of the standard deviation, as described in
+// J. Sauvola and M. Pietikäinen, Adaptive document image binarization,
+// Pattern Recognition, 33 (2000), pp. 225–236.
+// https://doi.org/10.1016/S0031-3203(99)00055-2
+// Usual values are k = 0.2 and r = 128.
+func Sauvola(gray *image.Gray, window int, k, r float64) (out, levels *image.Gray) {
+	return localThreshold(gray, window, func(mean, std float64) float64 {
+		return mean * (1 + k*(std/r-1))
+	})
+}
+
+// AdaptiveMean thresholds each pixel at the mean of the window of the given
+// size centered at it minus the constant c.
+func AdaptiveMean(gray *image.Gray, window int, c float64) (out, levels *image.Gray) {
+	return localThreshold(gray, window, func(mean, std float64) float64 {
+		return mean - c
+	})
+}
+
+// AdaptiveGaussian thresholds each pixel at the gaussian weighted mean of its
+// neighborhood, with standard deviation σ, minus the constant c.
+func AdaptiveGaussian(gray *image.Gray, σ, c float64) (out, levels *image.Gray) {
+	b := gray.Bounds()
+	w, h := b.Dx(), b.Dy()
+	values := grayValues(gray)
+	mean := make([]float64, w*h)
+	for i, v := range values {
+		mean[i] = float64(v)
+	}
+	mean = gaussianBlur(mean, w, h, σ)
+	out = image.NewGray(image.Rect(0, 0, w, h))
+	levels = image.NewGray(image.Rect(0, 0, w, h))
+	for i, v := range values {
+		t := mean[i] - c
+		levels.Pix[i] = uint8(clamp(math.Floor(t+0.5), 0, 255))
+		if float64(v) > t {
+			out.Pix[i] = 255
+		}
+	}
+	return
+}
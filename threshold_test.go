@@ -1,6 +1,7 @@
 package vision
 
 import (
+	"image"
 	"testing"
 
 	"github.com/anthonynsimon/bild/imgio"
@@ -11,3 +12,97 @@ func TestThreshold(t *testing.T) {
 	output := Threshold(&img, uint8(75))
 	_ = imgio.Save("examples/input_0_sel_threshold.png", output, imgio.PNGEncoder())
 }
+
+// bimodal returns an image whose left half is dark and right half bright,
+// with some noise on both halves.
+func bimodal() *image.Gray {
+	gray := image.NewGray(image.Rect(0, 0, 64, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 64; x++ {
+			v := 40 + (x*7+y*3)%20
+			if x >= 32 {
+				v += 150
+			}
+			gray.Pix[y*64+x] = uint8(v)
+		}
+	}
+	return gray
+}
+
+func TestGlobalThresholds(t *testing.T) {
+	gray := bimodal()
+	methods := []struct {
+		name string
+		f    func(*image.Gray) (*image.Gray, uint8)
+	}{
+		{"Otsu", Otsu},
+		{"Triangle", Triangle},
+		{"Kapur", Kapur},
+	}
+	for _, m := range methods {
+		t.Run(m.name, func(t *testing.T) {
+			out, level := m.f(gray)
+			if level < 59 || level >= 190 {
+				t.Fatalf("%s() level = %d, want in [59, 190)", m.name, level)
+			}
+			if out.GrayAt(0, 0).Y != 0 || out.GrayAt(63, 31).Y != 255 {
+				t.Errorf("%s() did not separate the halves", m.name)
+			}
+		})
+	}
+}
+
+func TestMultiOtsu(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 30, 1))
+	for x := range gray.Pix {
+		gray.Pix[x] = uint8(20 + 100*(x/10) + x%3)
+	}
+	out, levels := MultiOtsu(gray, 3)
+	if len(levels) != 2 || levels[0] < 22 || levels[0] >= 120 || levels[1] < 122 || levels[1] >= 220 {
+		t.Fatalf("MultiOtsu() levels = %v", levels)
+	}
+	for x, want := range []uint8{0, 127, 255} {
+		if got := out.Pix[x*10]; got != want {
+			t.Errorf("MultiOtsu() class %d = %d, want %d", x, got, want)
+		}
+	}
+}
+
+func TestLocalThresholds(t *testing.T) {
+	//Dark squares over a bright background with a strong illumination ramp
+	gray := image.NewGray(image.Rect(0, 0, 90, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 90; x++ {
+			v := 100 + x
+			if x%30 >= 10 && x%30 < 20 && y >= 10 && y < 20 {
+				v -= 80
+			}
+			gray.Pix[y*90+x] = uint8(v)
+		}
+	}
+	methods := []struct {
+		name string
+		f    func(*image.Gray) (*image.Gray, *image.Gray)
+	}{
+		{"Niblack", func(g *image.Gray) (*image.Gray, *image.Gray) { return Niblack(g, 15, -0.2) }},
+		{"Sauvola", func(g *image.Gray) (*image.Gray, *image.Gray) { return Sauvola(g, 15, 0.2, 128) }},
+		{"AdaptiveMean", func(g *image.Gray) (*image.Gray, *image.Gray) { return AdaptiveMean(g, 15, 5) }},
+		{"AdaptiveGaussian", func(g *image.Gray) (*image.Gray, *image.Gray) { return AdaptiveGaussian(g, 4, 5) }},
+	}
+	for _, m := range methods {
+		t.Run(m.name, func(t *testing.T) {
+			out, levels := m.f(gray)
+			if !levels.Bounds().Eq(gray.Bounds()) {
+				t.Fatalf("%s() levels bounds = %v", m.name, levels.Bounds())
+			}
+			for _, x := range []int{15, 45, 75} {
+				if out.GrayAt(x, 15).Y != 0 {
+					t.Errorf("%s() square at x = %d is not black", m.name, x)
+				}
+				if out.GrayAt(x, 4).Y != 255 {
+					t.Errorf("%s() background at x = %d is not white", m.name, x)
+				}
+			}
+		})
+	}
+}
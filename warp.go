@@ -0,0 +1,333 @@
+package vision
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+
+	"github.com/joaowiciuk/matrix"
+)
+
+// Interpolation selects how pixels are sampled at non integer coordinates.
+type Interpolation int
+
+const (
+	// InterpolationNearest takes the nearest pixel.
+	InterpolationNearest Interpolation = iota
+
+	// InterpolationBilinear interpolates linearly between the 2x2 nearest
+	// pixels.
+	InterpolationBilinear
+
+	// InterpolationBicubic uses the cubic convolution kernel of
+	// R. Keys, Cubic convolution interpolation for digital image processing,
+	// IEEE Transactions on Acoustics, Speech, and Signal Processing, 29 (1981), pp. 1153–1160.
+	// https://doi.org/10.1109/TASSP.1981.1163711
+	// over the 4x4 nearest pixels.
+	InterpolationBicubic
+
+	// InterpolationLanczos uses the Lanczos kernel with a = 3 over the 6x6
+	// nearest pixels.
+	InterpolationLanczos
+
+	// InterpolationArea averages the pixels covered by each output pixel
+	// when downscaling with Resize and is bilinear otherwise.
+	InterpolationArea
+)
+
+// radius returns the number of pixels the interpolation kernel reaches on
+// each side of a sample.
+func (interpolation Interpolation) radius() int {
+	switch interpolation {
+	case InterpolationBilinear, InterpolationArea:
+		return 1
+	case InterpolationBicubic:
+		return 2
+	case InterpolationLanczos:
+		return 3
+	}
+	return 0
+}
+
+// weight evaluates the interpolation kernel at the distance t.
+func (interpolation Interpolation) weight(t float64) float64 {
+	t = math.Abs(t)
+	switch interpolation {
+	case InterpolationBilinear, InterpolationArea:
+		return math.Max(1-t, 0)
+	case InterpolationBicubic:
+		const a = -0.5
+		switch {
+		case t < 1:
+			return ((a+2)*t-(a+3))*t*t + 1
+		case t < 2:
+			return ((a*t-5*a)*t+8*a)*t - 4*a
+		}
+		return 0
+	case InterpolationLanczos:
+		const a = 3
+		switch {
+		case t == 0:
+			return 1
+		case t < a:
+			return a * math.Sin(math.Pi*t) * math.Sin(math.Pi*t/a) / (math.Pi * math.Pi * t * t)
+		}
+		return 0
+	}
+	if t < 0.5 {
+		return 1
+	}
+	return 0
+}
+
+// Affine is the 2x3 affine transform mapping (x, y) to
+// (A[0] x + A[1] y + A[2], A[3] x + A[4] y + A[5]). Its layout matches the
+// params of AffineModel.
+type Affine [6]float64
+
+// Apply transforms a point.
+func (a Affine) Apply(p Point2D) Point2D {
+	return Point2D{a[0]*p.X + a[1]*p.Y + a[2], a[3]*p.X + a[4]*p.Y + a[5]}
+}
+
+// Invert returns the inverse transform, or false if it is singular.
+func (a Affine) Invert() (Affine, bool) {
+	det := a[0]*a[4] - a[1]*a[3]
+	if det == 0 {
+		return Affine{}, false
+	}
+	i0, i1, i3, i4 := a[4]/det, -a[1]/det, -a[3]/det, a[0]/det
+	return Affine{i0, i1, -i0*a[2] - i1*a[5], i3, i4, -i3*a[2] - i4*a[5]}, true
+}
+
+// Homography is the 3x3 projective transform in row-major order mapping
+// (x, y) to ((H[0] x + H[1] y + H[2]) / w, (H[3] x + H[4] y + H[5]) / w) with
+// w = H[6] x + H[7] y + H[8]. Its layout matches the params of
+// HomographyModel.
+type Homography [9]float64
+
+// Apply transforms a point. Points mapped to infinity have infinite
+// coordinates.
+func (h Homography) Apply(p Point2D) Point2D {
+	w := h[6]*p.X + h[7]*p.Y + h[8]
+	if w == 0 {
+		return Point2D{math.Inf(1), math.Inf(1)}
+	}
+	return Point2D{(h[0]*p.X + h[1]*p.Y + h[2]) / w, (h[3]*p.X + h[4]*p.Y + h[5]) / w}
+}
+
+// Invert returns the inverse transform, or false if it is singular.
+func (h Homography) Invert() (Homography, bool) {
+	//Adjugate over determinant
+	c0 := h[4]*h[8] - h[5]*h[7]
+	c1 := h[5]*h[6] - h[3]*h[8]
+	c2 := h[3]*h[7] - h[4]*h[6]
+	det := h[0]*c0 + h[1]*c1 + h[2]*c2
+	if det == 0 {
+		return Homography{}, false
+	}
+	inv := Homography{
+		c0, h[2]*h[7] - h[1]*h[8], h[1]*h[5] - h[2]*h[4],
+		c1, h[0]*h[8] - h[2]*h[6], h[2]*h[3] - h[0]*h[5],
+		c2, h[1]*h[6] - h[0]*h[7], h[0]*h[4] - h[1]*h[3],
+	}
+	for i := range inv {
+		inv[i] /= det
+	}
+	return inv, true
+}
+
+// WarpOptions configures the sampling of WarpAffine, WarpPerspective and
+// Remap. Fill is the color of the pixels outside the image under
+// BorderConstant; a nil Fill is transparent black.
+type WarpOptions struct {
+	Interpolation Interpolation
+	Border        Border
+	Fill          color.Color
+}
+
+// sampler reads an image with interleaved 8 bit channels at real
+// coordinates, where the pixel (x, y) is centered at (x, y).
+type sampler struct {
+	pix      []uint8
+	w, h     int
+	channels int
+	fill     [4]float64
+	opts     WarpOptions
+}
+
+// newSampler prepares an image for sampling. Grayscale images are sampled
+// as a single channel and any other image as premultiplied RGBA.
+func newSampler(img image.Image, opts WarpOptions) *sampler {
+	b := img.Bounds()
+	s := &sampler{w: b.Dx(), h: b.Dy(), opts: opts}
+	fill := opts.Fill
+	if fill == nil {
+		fill = color.Transparent
+	}
+	switch img.ColorModel() {
+	case color.GrayModel, color.Gray16Model:
+		gray, ok := img.(*image.Gray)
+		if !ok {
+			gray = image.NewGray(b)
+			draw.Draw(gray, b, img, b.Min, draw.Src)
+		}
+		s.pix, s.channels = grayValues(gray), 1
+		s.fill[0] = float64(color.GrayModel.Convert(fill).(color.Gray).Y)
+	default:
+		rgba := image.NewRGBA(image.Rect(0, 0, s.w, s.h))
+		draw.Draw(rgba, rgba.Rect, img, b.Min, draw.Src)
+		s.pix, s.channels = rgba.Pix, 4
+		c := color.RGBAModel.Convert(fill).(color.RGBA)
+		s.fill = [4]float64{float64(c.R), float64(c.G), float64(c.B), float64(c.A)}
+	}
+	return s
+}
+
+// image returns a new image of the sampler type with the given size.
+func (s *sampler) image(w, h int) (image.Image, []uint8) {
+	if s.channels == 1 {
+		gray := image.NewGray(image.Rect(0, 0, w, h))
+		return gray, gray.Pix
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	return rgba, rgba.Pix
+}
+
+// at samples every channel at (x, y) into out.
+func (s *sampler) at(x, y float64, out []uint8) {
+	var sum [4]float64
+	if math.IsNaN(x) || math.IsNaN(y) || math.IsInf(x, 0) || math.IsInf(y, 0) {
+		for c := 0; c < s.channels; c++ {
+			out[c] = uint8(s.fill[c])
+		}
+		return
+	}
+	r := s.opts.Interpolation.radius()
+	var x0, y0 int
+	var wx, wy [6]float64
+	if r == 0 {
+		x0, y0 = int(math.Floor(x+0.5)), int(math.Floor(y+0.5))
+		wx[0], wy[0] = 1, 1
+		r = 1
+	} else {
+		x0, y0 = int(math.Floor(x))-r+1, int(math.Floor(y))-r+1
+		var sx, sy float64
+		for k := 0; k < 2*r; k++ {
+			wx[k] = s.opts.Interpolation.weight(x - float64(x0+k))
+			wy[k] = s.opts.Interpolation.weight(y - float64(y0+k))
+			sx += wx[k]
+			sy += wy[k]
+		}
+		for k := 0; k < 2*r; k++ {
+			wx[k] /= sx
+			wy[k] /= sy
+		}
+	}
+	for j := 0; j < 2*r; j++ {
+		if wy[j] == 0 {
+			continue
+		}
+		yy := borderIndex(y0+j, s.h, s.opts.Border)
+		for i := 0; i < 2*r; i++ {
+			wgt := wx[i] * wy[j]
+			if wgt == 0 {
+				continue
+			}
+			xx := borderIndex(x0+i, s.w, s.opts.Border)
+			if xx < 0 || yy < 0 {
+				for c := 0; c < s.channels; c++ {
+					sum[c] += wgt * s.fill[c]
+				}
+				continue
+			}
+			o := (yy*s.w + xx) * s.channels
+			for c := 0; c < s.channels; c++ {
+				sum[c] += wgt * float64(s.pix[o+c])
+			}
+		}
+	}
+	for c := 0; c < s.channels; c++ {
+		out[c] = uint8(clamp(math.Floor(sum[c]+0.5), 0, 255))
+	}
+	if s.channels == 4 {
+		//Keep the color premultiplied
+		for c := 0; c < 3; c++ {
+			out[c] = uint8(min(int(out[c]), int(out[3])))
+		}
+	}
+}
+
+// warp builds a w-by-h image whose pixel (x, y) is the source sampled at
+// source(x, y).
+func warp(img image.Image, w, h int, opts WarpOptions, source func(x, y int) Point2D) image.Image {
+	if img == nil || w <= 0 || h <= 0 {
+		return nil
+	}
+	s := newSampler(img, opts)
+	if s.w == 0 || s.h == 0 {
+		return nil
+	}
+	out, pix := s.image(w, h)
+	wg := sync.WaitGroup{}
+	for y := 0; y < h; y++ {
+		//Proccess lines concurrently
+		wg.Add(1)
+		go func(y int) {
+			for x := 0; x < w; x++ {
+				p := source(x, y)
+				o := (y*w + x) * s.channels
+				s.at(p.X, p.Y, pix[o:o+s.channels])
+			}
+			wg.Done()
+		}(y)
+	}
+	wg.Wait()
+	return out
+}
+
+// WarpAffine applies the affine transform, which maps source pixels to
+// destination pixels, and returns a destination image of the given size.
+// Coordinates are relative to the image bounds and pixel centers lie at
+// integer coordinates. The output is an *image.Gray for grayscale images and
+// an *image.RGBA otherwise. It returns nil if the transform is singular.
+func WarpAffine(img image.Image, a Affine, size image.Point, opts WarpOptions) image.Image {
+	inv, ok := a.Invert()
+	if !ok {
+		return nil
+	}
+	return warp(img, size.X, size.Y, opts, func(x, y int) Point2D {
+		return inv.Apply(Point2D{float64(x), float64(y)})
+	})
+}
+
+// WarpPerspective applies the projective transform, which maps source pixels
+// to destination pixels, and returns a destination image of the given size,
+// with the same conventions as WarpAffine.
+func WarpPerspective(img image.Image, h Homography, size image.Point, opts WarpOptions) image.Image {
+	inv, ok := h.Invert()
+	if !ok {
+		return nil
+	}
+	return warp(img, size.X, size.Y, opts, func(x, y int) Point2D {
+		return inv.Apply(Point2D{float64(x), float64(y)})
+	})
+}
+
+// Remap returns an image of the size of the maps whose pixel (x, y) is the
+// input sampled at (mapX[y][x], mapY[y][x]), with the same conventions as
+// WarpAffine. It returns nil if the maps have different sizes.
+func Remap(img image.Image, mapX, mapY *matrix.Matrix, opts WarpOptions) image.Image {
+	if mapX == nil || mapY == nil {
+		return nil
+	}
+	rows, cols := mapX.Size()
+	if r, c := mapY.Size(); r != rows || c != cols {
+		return nil
+	}
+	return warp(img, cols, rows, opts, func(x, y int) Point2D {
+		return Point2D{(*mapX)[y][x], (*mapY)[y][x]}
+	})
+}
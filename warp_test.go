@@ -0,0 +1,99 @@
+package vision
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/joaowiciuk/matrix"
+)
+
+func TestWarpAffine(t *testing.T) {
+	gray := noiseGray(32, 24)
+	interpolations := []Interpolation{InterpolationNearest, InterpolationBilinear, InterpolationBicubic, InterpolationLanczos}
+	for _, interpolation := range interpolations {
+		//Integer translations are exact for every kernel
+		opts := WarpOptions{Interpolation: interpolation, Fill: color.Gray{Y: 7}}
+		out := WarpAffine(gray, Affine{1, 0, 3, 0, 1, -2}, image.Pt(32, 24), opts).(*image.Gray)
+		for y := 0; y < 24; y++ {
+			for x := 0; x < 32; x++ {
+				want := uint8(7)
+				if x-3 >= 0 && y+2 < 24 {
+					want = gray.GrayAt(x-3, y+2).Y
+				}
+				if got := out.GrayAt(x, y).Y; got != want {
+					t.Fatalf("interpolation %d at (%d, %d) = %d, want %d", interpolation, x, y, got, want)
+				}
+			}
+		}
+	}
+	if WarpAffine(gray, Affine{1, 2, 0, 2, 4, 0}, image.Pt(4, 4), WarpOptions{}) != nil {
+		t.Errorf("WarpAffine() with singular transform is not nil")
+	}
+}
+
+func TestWarpInterpolation(t *testing.T) {
+	//A linear ramp is reproduced by the bilinear and bicubic kernels
+	gray := image.NewGray(image.Rect(0, 0, 16, 1))
+	for x := range gray.Pix {
+		gray.Pix[x] = uint8(10 * x)
+	}
+	half := Affine{1, 0, -0.5, 0, 1, 0}
+	for _, interpolation := range []Interpolation{InterpolationBilinear, InterpolationBicubic} {
+		opts := WarpOptions{Interpolation: interpolation, Border: BorderReplicate}
+		out := WarpAffine(gray, half, image.Pt(16, 1), opts).(*image.Gray)
+		for x := 3; x < 13; x++ {
+			if got, want := out.Pix[x], uint8(10*x+5); got != want {
+				t.Errorf("interpolation %d at %d = %d, want %d", interpolation, x, got, want)
+			}
+		}
+	}
+}
+
+func TestWarpPerspective(t *testing.T) {
+	h := Homography{1.1, 0.05, 2, -0.03, 0.95, 1, 0.001, 0.0005, 1}
+	inv, ok := h.Invert()
+	if !ok {
+		t.Fatal("Invert() failed")
+	}
+	p := Point2D{12, 7}
+	if q := inv.Apply(h.Apply(p)); math.Hypot(q.X-p.X, q.Y-p.Y) > 1e-9 {
+		t.Errorf("round trip of %v = %v", p, q)
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	for i := range rgba.Pix {
+		rgba.Pix[i] = 255
+	}
+	out := WarpPerspective(rgba, h, image.Pt(60, 50), WarpOptions{Interpolation: InterpolationBilinear}).(*image.RGBA)
+	if c := out.RGBAAt(20, 15); c != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("inside pixel = %v, want white", c)
+	}
+	if c := out.RGBAAt(58, 48); c != (color.RGBA{}) {
+		t.Errorf("outside pixel = %v, want transparent", c)
+	}
+}
+
+func TestRemap(t *testing.T) {
+	gray := noiseGray(10, 8)
+	//Flip horizontally through the maps and wrap around vertically
+	mapX, mapY := matrix.New(8, 10), matrix.New(8, 10)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 10; x++ {
+			(*mapX)[y][x] = float64(9 - x)
+			(*mapY)[y][x] = float64(y + 8)
+		}
+	}
+	out := Remap(gray, mapX, mapY, WarpOptions{Border: BorderWrap}).(*image.Gray)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 10; x++ {
+			if got, want := out.GrayAt(x, y).Y, gray.GrayAt(9-x, y).Y; got != want {
+				t.Fatalf("Remap() at (%d, %d) = %d, want %d", x, y, got, want)
+			}
+		}
+	}
+	if Remap(gray, mapX, matrix.New(2, 2), WarpOptions{}) != nil {
+		t.Errorf("Remap() with different map sizes is not nil")
+	}
+}
@@ -0,0 +1,284 @@
+package vision
+
+import (
+	"image"
+	"math"
+)
+
+// WatershedLine is the label assigned by Watershed to the pixels that
+// separate two different basins.
+const WatershedLine = 0
+
+// neighborhood returns the offsets of the neighbors of a pixel under the
+// given connectivity.
+func neighborhood(connectivity Connectivity) []image.Point {
+	if connectivity == Connectivity4 {
+		return []image.Point{{0, -1}, {-1, 0}, {1, 0}, {0, 1}}
+	}
+	return []image.Point{
+		{-1, -1}, {0, -1}, {1, -1},
+		{-1, 0}, {1, 0},
+		{-1, 1}, {0, 1}, {1, 1},
+	}
+}
+
+// Watershed performs the marker-controlled watershed segmentation of the
+// relief image, as described in
+// F. Meyer, Topographic distance and watershed lines,
+// Signal Processing, 38 (1994), pp. 113–125.
+// https://doi.org/10.1016/0165-1684(94)90060-4
+//
+// The relief is usually the gradient magnitude returned by Grad or an
+// inverted DistanceTransform of a binary image. The markers are a label
+// image of the same size in which each seed region has a distinct non-zero
+// label and every other pixel is zero. If markers is nil, the regional minima
+// of the relief are used as seeds.
+//
+// The output is a label image with the bounds of the relief where each pixel
+// holds the label of the basin it was flooded from. Pixels where two basins
+// meet are labeled WatershedLine, as are pixels that can not be reached from
+// any marker. It returns nil if the markers and the relief differ in size.
+func Watershed(relief *image.Gray, markers *image.Gray16, connectivity Connectivity) *image.Gray16 {
+	b := relief.Bounds()
+	if markers == nil {
+		markers = RegionalMinima(relief, connectivity)
+	}
+	if !markers.Bounds().Size().Eq(b.Size()) {
+		return nil
+	}
+	w, h := b.Dx(), b.Dy()
+	level := grayValues(relief)
+	labels := make([]int, w*h)
+	mb := markers.Bounds()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			labels[y*w+x] = int(markers.Gray16At(mb.Min.X+x, mb.Min.Y+y).Y)
+		}
+	}
+
+	//Hierarchical queue with one FIFO per gray level
+	var queue [256][]int
+	queued := make([]bool, w*h)
+	current := 0
+	push := func(i, l int) {
+		if l < current {
+			l = current
+		}
+		queue[l] = append(queue[l], i)
+		queued[i] = true
+	}
+	offsets := neighborhood(connectivity)
+	neighbors := func(i int, visit func(j int)) {
+		x, y := i%w, i/w
+		for _, o := range offsets {
+			nx, ny := x+o.X, y+o.Y
+			if nx < 0 || nx >= w || ny < 0 || ny >= h {
+				continue
+			}
+			visit(ny*w + nx)
+		}
+	}
+
+	//Initialize the queue with the unlabeled neighbors of the markers
+	for i, l := range labels {
+		if l == 0 {
+			continue
+		}
+		queued[i] = true
+		neighbors(i, func(j int) {
+			if labels[j] == 0 && !queued[j] {
+				push(j, int(level[j]))
+			}
+		})
+	}
+
+	//Flood
+	const line = -1
+	for ; current < 256; current++ {
+		for len(queue[current]) > 0 {
+			i := queue[current][0]
+			queue[current] = queue[current][1:]
+			label := 0
+			neighbors(i, func(j int) {
+				switch {
+				case labels[j] <= 0:
+				case label == 0:
+					label = labels[j]
+				case label != labels[j]:
+					label = line
+				}
+			})
+			if label == 0 || label == line {
+				labels[i] = line
+				continue
+			}
+			labels[i] = label
+			neighbors(i, func(j int) {
+				if labels[j] == 0 && !queued[j] {
+					push(j, int(level[j]))
+				}
+			})
+		}
+	}
+
+	out := image.NewGray16(b)
+	for i, l := range labels {
+		if l < 0 {
+			l = WatershedLine
+		}
+		out.Pix[2*i] = uint8(l >> 8)
+		out.Pix[2*i+1] = uint8(l)
+	}
+	return out
+}
+
+// RegionalMinima returns a label image in which every regional minimum of
+// the input image, that is, every connected plateau with no lower neighbor,
+// has a distinct label starting at 1. All other pixels are zero. The output
+// has the bounds of the input.
+func RegionalMinima(gray *image.Gray, connectivity Connectivity) *image.Gray16 {
+	b := gray.Bounds()
+	w, h := b.Dx(), b.Dy()
+	level := grayValues(gray)
+	offsets := neighborhood(connectivity)
+	visited := make([]bool, w*h)
+	labels := make([]int, w*h)
+	count := 0
+	plateau := make([]int, 0)
+	for start := range level {
+		if visited[start] {
+			continue
+		}
+		//Collect the plateau containing start and check if it is a minimum
+		plateau = append(plateau[:0], start)
+		visited[start] = true
+		minimum := true
+		for k := 0; k < len(plateau); k++ {
+			i := plateau[k]
+			x, y := i%w, i/w
+			for _, o := range offsets {
+				nx, ny := x+o.X, y+o.Y
+				if nx < 0 || nx >= w || ny < 0 || ny >= h {
+					continue
+				}
+				j := ny*w + nx
+				if level[j] < level[i] {
+					minimum = false
+				} else if level[j] == level[i] && !visited[j] {
+					visited[j] = true
+					plateau = append(plateau, j)
+				}
+			}
+		}
+		if !minimum || count == math.MaxUint16 {
+			continue
+		}
+		count++
+		for _, i := range plateau {
+			labels[i] = count
+		}
+	}
+	out := image.NewGray16(b)
+	for i, l := range labels {
+		out.Pix[2*i] = uint8(l >> 8)
+		out.Pix[2*i+1] = uint8(l)
+	}
+	return out
+}
+
+// DistanceTransform computes the exact euclidean distance from each white
+// pixel of a binary image to the nearest black pixel, as described in
+// P. F. Felzenszwalb and D. P. Huttenlocher, Distance Transforms of Sampled Functions,
+// Theory of Computing, 8 (2012), pp. 415–428.
+// https://doi.org/10.4086/toc.2012.v008a019
+//
+// Distances are rounded and saturated at 255, and the output has the bounds
+// of the input. Inverting the result
+// (255 - d) gives a relief whose basins are the centers of the objects, which
+// is the usual input to Watershed for separating touching blobs.
+func DistanceTransform(binary *image.Gray) *image.Gray {
+	b := binary.Bounds()
+	w, h := b.Dx(), b.Dy()
+	inf := float64(w*w + h*h + 1)
+	level := grayValues(binary)
+	d := make([]float64, w*h)
+	for i, v := range level {
+		if v == 255 {
+			d[i] = inf
+		}
+	}
+
+	//Transform columns and then rows
+	n := max(w, h)
+	f := make([]float64, n)
+	out := make([]float64, n)
+	v := make([]int, n)
+	z := make([]float64, n+1)
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			f[y] = d[y*w+x]
+		}
+		squaredDistance1D(f[:h], out, v, z)
+		for y := 0; y < h; y++ {
+			d[y*w+x] = out[y]
+		}
+	}
+	for y := 0; y < h; y++ {
+		copy(f, d[y*w:(y+1)*w])
+		squaredDistance1D(f[:w], out, v, z)
+		copy(d[y*w:(y+1)*w], out[:w])
+	}
+
+	gray := image.NewGray(b)
+	for i := range d {
+		gray.Pix[i] = uint8(clamp(math.Floor(math.Sqrt(d[i])+0.5), 0, 255))
+	}
+	return gray
+}
+
+// squaredDistance1D computes the lower envelope of the parabolas rooted at
+// (q, f[q]) and stores it in d. The v and z slices are working buffers.
+func squaredDistance1D(f, d []float64, v []int, z []float64) {
+	n := len(f)
+	if n == 0 {
+		return
+	}
+	k := 0
+	v[0] = 0
+	z[0] = math.Inf(-1)
+	z[1] = math.Inf(1)
+	for q := 1; q < n; q++ {
+		s := ((f[q] + float64(q*q)) - (f[v[k]] + float64(v[k]*v[k]))) / float64(2*q-2*v[k])
+		for s <= z[k] {
+			k--
+			s = ((f[q] + float64(q*q)) - (f[v[k]] + float64(v[k]*v[k]))) / float64(2*q-2*v[k])
+		}
+		k++
+		v[k] = q
+		z[k] = s
+		z[k+1] = math.Inf(1)
+	}
+	k = 0
+	for q := 0; q < n; q++ {
+		for z[k+1] < float64(q) {
+			k++
+		}
+		d[q] = float64((q-v[k])*(q-v[k])) + f[v[k]]
+	}
+}
+
+// grayValues returns the pixels of a grayscale image as a contiguous slice
+// in row-major order, regardless of its stride and origin.
+func grayValues(gray *image.Gray) []uint8 {
+	b := gray.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if gray.Stride == w {
+		return gray.Pix[:w*h]
+	}
+	values := make([]uint8, w*h)
+	for y := 0; y < h; y++ {
+		i := gray.PixOffset(b.Min.X, b.Min.Y+y)
+		copy(values[y*w:(y+1)*w], gray.Pix[i:i+w])
+	}
+	return values
+}
@@ -0,0 +1,103 @@
+package vision
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// touchingDisks returns a binary image with two overlapping white disks.
+func touchingDisks() *image.Gray {
+	gray := image.NewGray(image.Rect(0, 0, 60, 40))
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 60; x++ {
+			if dot(x-20, y-20) <= 144 || dot(x-40, y-20) <= 144 {
+				gray.Pix[y*60+x] = 255
+			}
+		}
+	}
+	return gray
+}
+
+func TestWatershed(t *testing.T) {
+	binary := touchingDisks()
+	dist := DistanceTransform(binary)
+	if got := dist.GrayAt(20, 20).Y; got != 12 {
+		t.Fatalf("DistanceTransform() at disk center = %d, want 12", got)
+	}
+	relief := image.NewGray(dist.Bounds())
+	for i, v := range dist.Pix {
+		relief.Pix[i] = 255 - v
+	}
+	markers := image.NewGray16(binary.Bounds())
+	markers.SetGray16(20, 20, color.Gray16{Y: 1})
+	markers.SetGray16(40, 20, color.Gray16{Y: 2})
+	markers.SetGray16(0, 0, color.Gray16{Y: 3})
+
+	for _, connectivity := range []Connectivity{Connectivity4, Connectivity8} {
+		labels := Watershed(relief, markers, connectivity)
+		if l := labels.Gray16At(15, 20).Y; l != 1 {
+			t.Errorf("left disk label = %d, want 1", l)
+		}
+		if l := labels.Gray16At(45, 20).Y; l != 2 {
+			t.Errorf("right disk label = %d, want 2", l)
+		}
+		if l := labels.Gray16At(2, 1).Y; l != 3 {
+			t.Errorf("background label = %d, want 3", l)
+		}
+		lines := 0
+		for x := 28; x <= 32; x++ {
+			if labels.Gray16At(x, 20).Y == WatershedLine {
+				lines++
+			}
+		}
+		if lines == 0 {
+			t.Errorf("no watershed line between the disks")
+		}
+	}
+	//A subimage keeps its bounds, and mismatched markers give nil
+	sub := relief.SubImage(image.Rect(10, 5, 50, 35)).(*image.Gray)
+	labels := Watershed(sub, nil, Connectivity8)
+	if labels.Bounds() != sub.Bounds() {
+		t.Errorf("Watershed() bounds = %v, want %v", labels.Bounds(), sub.Bounds())
+	}
+	if l1, l2 := labels.Gray16At(20, 20).Y, labels.Gray16At(40, 20).Y; l1 == WatershedLine || l2 == WatershedLine || l1 == l2 {
+		t.Errorf("Watershed() on a subimage labels the disks %d and %d", l1, l2)
+	}
+	if DistanceTransform(binary.SubImage(sub.Bounds()).(*image.Gray)).GrayAt(20, 20).Y != 12 {
+		t.Errorf("DistanceTransform() on a subimage moved the disk center")
+	}
+	if Watershed(relief, markers.SubImage(sub.Bounds()).(*image.Gray16), Connectivity8) != nil {
+		t.Errorf("Watershed() with markers of another size should be nil")
+	}
+}
+
+func TestRegionalMinima(t *testing.T) {
+	gray := &image.Gray{
+		Rect:   image.Rect(0, 0, 5, 3),
+		Stride: 5,
+		Pix: []uint8{
+			9, 9, 9, 9, 9,
+			9, 1, 9, 2, 2,
+			9, 9, 9, 9, 3,
+		},
+	}
+	minima := RegionalMinima(gray, Connectivity4)
+	want := []uint16{
+		0, 0, 0, 0, 0,
+		0, 1, 0, 2, 2,
+		0, 0, 0, 0, 0,
+	}
+	for i, w := range want {
+		if got := minima.Gray16At(i%5, i/5).Y; got != w {
+			t.Errorf("RegionalMinima() at %d = %d, want %d", i, got, w)
+		}
+	}
+	labels := Watershed(gray, nil, Connectivity4)
+	if l := labels.Gray16At(0, 0).Y; l != 1 && l != WatershedLine {
+		t.Errorf("Watershed() corner label = %d", l)
+	}
+	if l := labels.Gray16At(4, 2).Y; l != 2 {
+		t.Errorf("Watershed() label = %d, want 2", l)
+	}
+}